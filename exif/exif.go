@@ -0,0 +1,829 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// Define errors.
+var (
+	ErrInvalidHeader   = errors.New("invalid tiff header")
+	ErrInvalidIfd      = errors.New("invalid ifd structure")
+	ErrInvalidTagValue = errors.New("tag value doesn't match tag type")
+	ErrTooLarge        = errors.New("exif data exceeds maximum size")
+)
+
+// Header of EXIF data in JPEG APP1 segment.
+var ExifHeader = []byte("Exif\x00\x00")
+
+// TIFF field type.
+type DataType uint16
+
+const (
+	TypeByte      DataType = 1
+	TypeAscii     DataType = 2
+	TypeShort     DataType = 3
+	TypeLong      DataType = 4
+	TypeRational  DataType = 5
+	TypeSByte     DataType = 6
+	TypeUndefined DataType = 7
+	TypeSShort    DataType = 8
+	TypeSLong     DataType = 9
+	TypeSRational DataType = 10
+	TypeFloat     DataType = 11
+	TypeDouble    DataType = 12
+	TypeIfd       DataType = 13 // Offset to sub-IFD, same layout as LONG.
+)
+
+// Size of one value in bytes, 0 if type is unknown.
+func (t DataType) Size() int {
+	switch t {
+	case TypeByte, TypeAscii, TypeSByte, TypeUndefined:
+		return 1
+	case TypeShort, TypeSShort:
+		return 2
+	case TypeLong, TypeSLong, TypeFloat, TypeIfd:
+		return 4
+	case TypeRational, TypeSRational, TypeDouble:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// IFD kind.
+type IfdType int
+
+const (
+	Ifd0       IfdType = iota // Primary image.
+	Ifd1                      // Thumbnail.
+	ExifIfd                   // Exif private tags.
+	GpsIfd                    // GPS tags.
+	InteropIfd                // Interoperability tags.
+)
+
+func (t IfdType) String() string {
+	switch t {
+	case Ifd0:
+		return "IFD0"
+	case Ifd1:
+		return "IFD1"
+	case ExifIfd:
+		return "Exif"
+	case GpsIfd:
+		return "GPS"
+	case InteropIfd:
+		return "Interop"
+	default:
+		return "Unknown"
+	}
+}
+
+// Unsigned rational.
+type Rational struct {
+	Numerator   uint32
+	Denominator uint32
+}
+
+// Signed rational.
+type SRational struct {
+	Numerator   int32
+	Denominator int32
+}
+
+// Single tag.
+//
+// `Value` holds the decoded value, its Go type depends on `Type`:
+//
+//	TypeByte      []uint8
+//	TypeAscii     string (without trailing NUL)
+//	TypeShort     []uint16
+//	TypeLong      []uint32
+//	TypeRational  []Rational
+//	TypeSByte     []int8
+//	TypeUndefined []byte
+//	TypeSShort    []int16
+//	TypeSLong     []int32
+//	TypeSRational []SRational
+//	TypeFloat     []float32
+//	TypeDouble    []float64
+//	TypeIfd       []uint32
+type Tag struct {
+	Id    uint16
+	Type  DataType
+	Value any
+}
+
+// Single IFD.
+type Ifd struct {
+	Tags map[uint16]*Tag
+}
+
+// Parsed EXIF data.
+type Exif struct {
+	ByteOrder binary.ByteOrder
+	Ifds      map[IfdType]*Ifd
+	Thumbnail []byte // JPEG thumbnail referenced by IFD1, nil if not present.
+}
+
+// Create empty EXIF data.
+func New(order binary.ByteOrder) *Exif {
+	return &Exif{
+		ByteOrder: order,
+		Ifds:      make(map[IfdType]*Ifd),
+	}
+}
+
+// Get tag from IFD.
+func (e *Exif) Get(ifd IfdType, id uint16) (*Tag, bool) {
+	dir, ok := e.Ifds[ifd]
+	if !ok {
+		return nil, false
+	}
+	tag, ok := dir.Tags[id]
+	return tag, ok
+}
+
+// Set tag in IFD, replacing existing one.
+func (e *Exif) Set(ifd IfdType, tag *Tag) {
+	dir, ok := e.Ifds[ifd]
+	if !ok {
+		dir = &Ifd{Tags: make(map[uint16]*Tag)}
+		e.Ifds[ifd] = dir
+	}
+	dir.Tags[tag.Id] = tag
+}
+
+// Delete tag from IFD.
+func (e *Exif) Delete(ifd IfdType, id uint16) {
+	if dir, ok := e.Ifds[ifd]; ok {
+		delete(dir.Tags, id)
+	}
+}
+
+// Delete whole IFD.
+//
+// Deleting IFD1 also removes the thumbnail.
+func (e *Exif) DeleteIfd(ifd IfdType) {
+	delete(e.Ifds, ifd)
+	if ifd == Ifd1 {
+		e.Thumbnail = nil
+	}
+}
+
+// Get orientation from IFD0, returns 1 (normal) if tag is missing or invalid.
+func (e *Exif) Orientation() int {
+	tag, ok := e.Get(Ifd0, TagOrientation)
+	if !ok {
+		return 1
+	}
+	v, ok := tag.Int(0)
+	if !ok || v < 1 || v > 8 {
+		return 1
+	}
+	return int(v)
+}
+
+// Set orientation in IFD0.
+func (e *Exif) SetOrientation(orientation int) {
+	e.Set(Ifd0, NewShortTag(TagOrientation, uint16(orientation)))
+}
+
+// Create ASCII tag.
+func NewAsciiTag(id uint16, value string) *Tag {
+	return &Tag{Id: id, Type: TypeAscii, Value: value}
+}
+
+// Create SHORT tag.
+func NewShortTag(id uint16, value ...uint16) *Tag {
+	return &Tag{Id: id, Type: TypeShort, Value: value}
+}
+
+// Create LONG tag.
+func NewLongTag(id uint16, value ...uint32) *Tag {
+	return &Tag{Id: id, Type: TypeLong, Value: value}
+}
+
+// Create RATIONAL tag.
+func NewRationalTag(id uint16, value ...Rational) *Tag {
+	return &Tag{Id: id, Type: TypeRational, Value: value}
+}
+
+// Create UNDEFINED tag.
+func NewUndefinedTag(id uint16, value []byte) *Tag {
+	return &Tag{Id: id, Type: TypeUndefined, Value: value}
+}
+
+// Get value count of tag.
+func (tag *Tag) Count() int {
+	switch v := tag.Value.(type) {
+	case string:
+		return len(v) + 1 // Including trailing NUL.
+	case []uint8:
+		return len(v)
+	case []uint16:
+		return len(v)
+	case []uint32:
+		return len(v)
+	case []Rational:
+		return len(v)
+	case []int8:
+		return len(v)
+	case []int16:
+		return len(v)
+	case []int32:
+		return len(v)
+	case []SRational:
+		return len(v)
+	case []float32:
+		return len(v)
+	case []float64:
+		return len(v)
+	default:
+		return 0
+	}
+}
+
+// Get n-th value of an integer tag (BYTE, SHORT, LONG and signed variants).
+func (tag *Tag) Int(n int) (int64, bool) {
+	switch v := tag.Value.(type) {
+	case []uint8:
+		if tag.Type == TypeByte && n < len(v) {
+			return int64(v[n]), true
+		}
+	case []uint16:
+		if n < len(v) {
+			return int64(v[n]), true
+		}
+	case []uint32:
+		if n < len(v) {
+			return int64(v[n]), true
+		}
+	case []int8:
+		if n < len(v) {
+			return int64(v[n]), true
+		}
+	case []int16:
+		if n < len(v) {
+			return int64(v[n]), true
+		}
+	case []int32:
+		if n < len(v) {
+			return int64(v[n]), true
+		}
+	}
+	return 0, false
+}
+
+// Get n-th value of a numeric tag as float, rationals are divided.
+func (tag *Tag) Float(n int) (float64, bool) {
+	switch v := tag.Value.(type) {
+	case []Rational:
+		if n < len(v) && v[n].Denominator != 0 {
+			return float64(v[n].Numerator) / float64(v[n].Denominator), true
+		}
+		return 0, false
+	case []SRational:
+		if n < len(v) && v[n].Denominator != 0 {
+			return float64(v[n].Numerator) / float64(v[n].Denominator), true
+		}
+		return 0, false
+	case []float32:
+		if n < len(v) {
+			return float64(v[n]), true
+		}
+		return 0, false
+	case []float64:
+		if n < len(v) {
+			return v[n], true
+		}
+		return 0, false
+	}
+	i, ok := tag.Int(n)
+	return float64(i), ok
+}
+
+// Get string value of ASCII tag.
+func (tag *Tag) Ascii() (string, bool) {
+	v, ok := tag.Value.(string)
+	return v, ok
+}
+
+// Parse EXIF data.
+//
+// Input can be either TIFF structure, or APP1 payload starting with "Exif\0\0".
+func Parse(data []byte) (*Exif, error) {
+
+	data = bytes.TrimPrefix(data, ExifHeader) // Remove APP1 header if exists.
+
+	if len(data) < 8 {
+		return nil, ErrInvalidHeader
+	}
+
+	// Check byte order.
+	var order binary.ByteOrder
+	switch string(data[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, ErrInvalidHeader
+	}
+
+	// Check magic number.
+	if order.Uint16(data[2:4]) != 42 {
+		return nil, ErrInvalidHeader
+	}
+
+	p := &parser{
+		data:    data,
+		order:   order,
+		visited: make(map[uint32]bool),
+		exif:    New(order),
+	}
+
+	// Parse IFD0, which links to IFD1.
+	ifd1_offset, err := p.parseIfd(Ifd0, order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+	if ifd1_offset != 0 {
+		_, err = p.parseIfd(Ifd1, ifd1_offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.exif, nil
+}
+
+// Internal parser state.
+type parser struct {
+	data    []byte
+	order   binary.ByteOrder
+	visited map[uint32]bool // Visited IFD offsets, to prevent loops.
+	exif    *Exif
+}
+
+// Parse IFD at offset, returns offset of next IFD.
+func (p *parser) parseIfd(ifd_type IfdType, offset uint32) (uint32, error) {
+
+	// Check for loop.
+	if p.visited[offset] {
+		return 0, ErrInvalidIfd
+	}
+	p.visited[offset] = true
+
+	// Read entry count.
+	if uint64(offset)+2 > uint64(len(p.data)) {
+		return 0, ErrInvalidIfd
+	}
+	entry_count := int(p.order.Uint16(p.data[offset:]))
+	entries_start := int(offset) + 2
+	if entries_start+entry_count*12+4 > len(p.data) {
+		return 0, ErrInvalidIfd
+	}
+
+	dir := &Ifd{Tags: make(map[uint16]*Tag)}
+	p.exif.Ifds[ifd_type] = dir
+
+	var thumbnail_offset, thumbnail_length int64 = -1, -1
+
+	for i := 0; i < entry_count; i++ {
+		entry := p.data[entries_start+i*12 : entries_start+(i+1)*12]
+		id := p.order.Uint16(entry[0:2])
+		data_type := DataType(p.order.Uint16(entry[2:4]))
+		count := p.order.Uint32(entry[4:8])
+
+		// Unknown type, should be ignored by readers (TIFF 6.0 section 2).
+		if data_type.Size() == 0 {
+			continue
+		}
+
+		// Locate value bytes.
+		raw_value, err := p.valueBytes(data_type, count, entry[8:12])
+		if err != nil {
+			return 0, err
+		}
+
+		tag := &Tag{Id: id, Type: data_type, Value: decodeValue(p.order, data_type, raw_value)}
+
+		// Follow pointer tags.
+		if isPointerTag(ifd_type, id) {
+			pointer, ok := tag.Int(0)
+			if !ok {
+				return 0, ErrInvalidIfd
+			}
+
+			switch id {
+			case TagExifIfdPointer:
+				_, err = p.parseIfd(ExifIfd, uint32(pointer))
+			case TagGpsIfdPointer:
+				_, err = p.parseIfd(GpsIfd, uint32(pointer))
+			case TagInteropIfdPointer:
+				_, err = p.parseIfd(InteropIfd, uint32(pointer))
+			case TagJpegInterchangeFormat:
+				thumbnail_offset = pointer
+			case TagJpegInterchangeFormatLength:
+				thumbnail_length = pointer
+			}
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		dir.Tags[id] = tag
+	}
+
+	// Extract thumbnail, drop it if it is outside data.
+	if thumbnail_offset >= 0 && thumbnail_length >= 0 && thumbnail_offset+thumbnail_length <= int64(len(p.data)) {
+		thumbnail := make([]byte, thumbnail_length)
+		copy(thumbnail, p.data[thumbnail_offset:thumbnail_offset+thumbnail_length])
+		p.exif.Thumbnail = thumbnail
+	}
+
+	next_offset := p.order.Uint32(p.data[entries_start+entry_count*12:])
+	return next_offset, nil
+}
+
+// Get raw bytes of tag value.
+//
+// Values up to 4 bytes are stored in the entry, larger values are stored at offset.
+func (p *parser) valueBytes(data_type DataType, count uint32, value_field []byte) ([]byte, error) {
+
+	size := data_type.Size()
+
+	total := uint64(size) * uint64(count)
+	if total <= 4 {
+		return value_field[:total], nil
+	}
+
+	offset := uint64(p.order.Uint32(value_field))
+	if offset+total > uint64(len(p.data)) {
+		return nil, ErrInvalidIfd
+	}
+	return p.data[offset : offset+total], nil
+}
+
+// Decode raw value bytes to typed value.
+func decodeValue(order binary.ByteOrder, data_type DataType, raw []byte) any {
+
+	switch data_type {
+	case TypeAscii:
+		return string(bytes.TrimRight(raw, "\x00"))
+	case TypeByte:
+		return append([]uint8{}, raw...)
+	case TypeSByte:
+		v := make([]int8, len(raw))
+		for i := range v {
+			v[i] = int8(raw[i])
+		}
+		return v
+	case TypeShort:
+		v := make([]uint16, len(raw)/2)
+		for i := range v {
+			v[i] = order.Uint16(raw[i*2:])
+		}
+		return v
+	case TypeSShort:
+		v := make([]int16, len(raw)/2)
+		for i := range v {
+			v[i] = int16(order.Uint16(raw[i*2:]))
+		}
+		return v
+	case TypeLong, TypeIfd:
+		v := make([]uint32, len(raw)/4)
+		for i := range v {
+			v[i] = order.Uint32(raw[i*4:])
+		}
+		return v
+	case TypeSLong:
+		v := make([]int32, len(raw)/4)
+		for i := range v {
+			v[i] = int32(order.Uint32(raw[i*4:]))
+		}
+		return v
+	case TypeRational:
+		v := make([]Rational, len(raw)/8)
+		for i := range v {
+			v[i] = Rational{order.Uint32(raw[i*8:]), order.Uint32(raw[i*8+4:])}
+		}
+		return v
+	case TypeSRational:
+		v := make([]SRational, len(raw)/8)
+		for i := range v {
+			v[i] = SRational{int32(order.Uint32(raw[i*8:])), int32(order.Uint32(raw[i*8+4:]))}
+		}
+		return v
+	case TypeFloat:
+		v := make([]float32, len(raw)/4)
+		for i := range v {
+			v[i] = math.Float32frombits(order.Uint32(raw[i*4:]))
+		}
+		return v
+	case TypeDouble:
+		v := make([]float64, len(raw)/8)
+		for i := range v {
+			v[i] = math.Float64frombits(order.Uint64(raw[i*8:]))
+		}
+		return v
+	default: // Undefined.
+		return append([]byte{}, raw...)
+	}
+}
+
+// Encode typed value to raw bytes, returns value count.
+func encodeValue(order binary.ByteOrder, tag *Tag) ([]byte, uint32, error) {
+
+	buf := bytes.NewBuffer([]byte{})
+
+	switch v := tag.Value.(type) {
+	case string:
+		if tag.Type != TypeAscii {
+			return nil, 0, ErrInvalidTagValue
+		}
+		buf.WriteString(v)
+		buf.WriteByte('\x00')
+		return buf.Bytes(), uint32(len(v) + 1), nil
+	case []uint8:
+		if tag.Type != TypeByte && tag.Type != TypeUndefined {
+			return nil, 0, ErrInvalidTagValue
+		}
+		return v, uint32(len(v)), nil
+	case []int8:
+		if tag.Type != TypeSByte {
+			return nil, 0, ErrInvalidTagValue
+		}
+		for _, x := range v {
+			buf.WriteByte(byte(x))
+		}
+		return buf.Bytes(), uint32(len(v)), nil
+	case []uint16:
+		if tag.Type != TypeShort {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []int16:
+		if tag.Type != TypeSShort {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []uint32:
+		if tag.Type != TypeLong && tag.Type != TypeIfd {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []int32:
+		if tag.Type != TypeSLong {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []Rational:
+		if tag.Type != TypeRational {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []SRational:
+		if tag.Type != TypeSRational {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []float32:
+		if tag.Type != TypeFloat {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	case []float64:
+		if tag.Type != TypeDouble {
+			return nil, 0, ErrInvalidTagValue
+		}
+		binary.Write(buf, order, v)
+		return buf.Bytes(), uint32(len(v)), nil
+	default:
+		return nil, 0, ErrInvalidTagValue
+	}
+}
+
+// Encoded IFD entry.
+type encodedEntry struct {
+	id        uint16
+	data_type DataType
+	count     uint32
+	value     []byte
+	pointer   *uint32 // Pointer to fill into value field, for pointer tags.
+}
+
+// Encoded IFD.
+type encodedIfd struct {
+	entries []*encodedEntry
+	offset  uint32
+}
+
+// Size of IFD including out-of-line values.
+func (dir *encodedIfd) size() uint32 {
+	size := uint32(2 + len(dir.entries)*12 + 4)
+	for _, entry := range dir.entries {
+		if len(entry.value) > 4 {
+			size += uint32(len(entry.value)+1) &^ 1 // Values start on word boundary.
+		}
+	}
+	return size
+}
+
+// Serialize EXIF data to TIFF structure.
+//
+// Tags are written in ascending order, pointer tags and thumbnail offsets are regenerated.
+// NOTE: Offsets inside MakerNote are not relocated, same as most EXIF editors.
+func (e *Exif) Bytes() ([]byte, error) {
+
+	order := e.ByteOrder
+	if order == nil {
+		order = binary.BigEndian
+	}
+
+	// Offsets of linked structures, filled after layout.
+	var exif_offset, gps_offset, interop_offset, thumbnail_offset, thumbnail_length uint32
+	thumbnail_length = uint32(len(e.Thumbnail))
+
+	// Check which IFDs are needed.
+	has_ifd := func(ifd IfdType) bool {
+		dir, ok := e.Ifds[ifd]
+		return ok && len(dir.Tags) > 0
+	}
+	has_interop := has_ifd(InteropIfd)
+	has_exif := has_ifd(ExifIfd) || has_interop
+	has_gps := has_ifd(GpsIfd)
+	has_ifd1 := has_ifd(Ifd1) || e.Thumbnail != nil
+
+	// Encode IFDs.
+	encode := func(ifd IfdType, pointers map[uint16]*uint32) (*encodedIfd, error) {
+		dir := &encodedIfd{}
+		if src, ok := e.Ifds[ifd]; ok {
+			for id, tag := range src.Tags {
+				if isPointerTag(ifd, id) {
+					continue
+				}
+				value, count, err := encodeValue(order, tag)
+				if err != nil {
+					return nil, err
+				}
+				dir.entries = append(dir.entries, &encodedEntry{id: id, data_type: tag.Type, count: count, value: value})
+			}
+		}
+		for id, pointer := range pointers {
+			dir.entries = append(dir.entries, &encodedEntry{id: id, data_type: TypeLong, count: 1, value: make([]byte, 4), pointer: pointer})
+		}
+		sort.Slice(dir.entries, func(i, j int) bool {
+			return dir.entries[i].id < dir.entries[j].id
+		})
+		return dir, nil
+	}
+
+	// Build IFD list in output order.
+	ifd0_pointers := map[uint16]*uint32{}
+	if has_exif {
+		ifd0_pointers[TagExifIfdPointer] = &exif_offset
+	}
+	if has_gps {
+		ifd0_pointers[TagGpsIfdPointer] = &gps_offset
+	}
+	ifd0, err := encode(Ifd0, ifd0_pointers)
+	if err != nil {
+		return nil, err
+	}
+	layout := []*encodedIfd{ifd0}
+
+	var exif_ifd, interop_ifd, gps_ifd, ifd1 *encodedIfd
+	if has_exif {
+		exif_pointers := map[uint16]*uint32{}
+		if has_interop {
+			exif_pointers[TagInteropIfdPointer] = &interop_offset
+		}
+		exif_ifd, err = encode(ExifIfd, exif_pointers)
+		if err != nil {
+			return nil, err
+		}
+		layout = append(layout, exif_ifd)
+	}
+	if has_interop {
+		interop_ifd, err = encode(InteropIfd, nil)
+		if err != nil {
+			return nil, err
+		}
+		layout = append(layout, interop_ifd)
+	}
+	if has_gps {
+		gps_ifd, err = encode(GpsIfd, nil)
+		if err != nil {
+			return nil, err
+		}
+		layout = append(layout, gps_ifd)
+	}
+	if has_ifd1 {
+		ifd1_pointers := map[uint16]*uint32{}
+		if e.Thumbnail != nil {
+			ifd1_pointers[TagJpegInterchangeFormat] = &thumbnail_offset
+			ifd1_pointers[TagJpegInterchangeFormatLength] = &thumbnail_length
+		}
+		ifd1, err = encode(Ifd1, ifd1_pointers)
+		if err != nil {
+			return nil, err
+		}
+		layout = append(layout, ifd1)
+	}
+
+	// Calculate offsets.
+	offset := uint64(8)
+	for _, dir := range layout {
+		dir.offset = uint32(offset)
+		offset += uint64(dir.size())
+	}
+	if offset+uint64(len(e.Thumbnail)) > math.MaxUint32 {
+		return nil, ErrTooLarge
+	}
+	thumbnail_offset = uint32(offset)
+	if exif_ifd != nil {
+		exif_offset = exif_ifd.offset
+	}
+	if interop_ifd != nil {
+		interop_offset = interop_ifd.offset
+	}
+	if gps_ifd != nil {
+		gps_offset = gps_ifd.offset
+	}
+
+	// Write header.
+	buf := bytes.NewBuffer(make([]byte, 0, offset+uint64(len(e.Thumbnail))))
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	binary.Write(buf, order, uint16(42))
+	binary.Write(buf, order, uint32(8)) // IFD0 offset.
+
+	// Write IFDs.
+	for _, dir := range layout {
+
+		binary.Write(buf, order, uint16(len(dir.entries)))
+
+		data_offset := dir.offset + uint32(2+len(dir.entries)*12+4) // Out-of-line values follow the entries.
+		data := bytes.NewBuffer([]byte{})
+
+		for _, entry := range dir.entries {
+			binary.Write(buf, order, entry.id)
+			binary.Write(buf, order, uint16(entry.data_type))
+			binary.Write(buf, order, entry.count)
+
+			switch {
+			case entry.pointer != nil:
+				binary.Write(buf, order, *entry.pointer)
+			case len(entry.value) <= 4:
+				value_field := make([]byte, 4)
+				copy(value_field, entry.value)
+				buf.Write(value_field)
+			default:
+				binary.Write(buf, order, data_offset+uint32(data.Len()))
+				data.Write(entry.value)
+				if len(entry.value)%2 != 0 { // Pad to word boundary.
+					data.WriteByte(0)
+				}
+			}
+		}
+
+		// Link IFD0 to IFD1.
+		if dir == ifd0 && ifd1 != nil {
+			binary.Write(buf, order, ifd1.offset)
+		} else {
+			binary.Write(buf, order, uint32(0))
+		}
+
+		buf.Write(data.Bytes())
+	}
+
+	// Write thumbnail.
+	buf.Write(e.Thumbnail)
+
+	return buf.Bytes(), nil
+}
+
+// Serialize EXIF data to JPEG APP1 payload, with "Exif\0\0" header.
+func (e *Exif) App1Bytes() ([]byte, error) {
+	tiff, err := e.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, ExifHeader...), tiff...), nil
+}
@@ -0,0 +1,86 @@
+package exif
+
+// IFD0/IFD1 tags (TIFF).
+const (
+	TagImageWidth                  uint16 = 0x0100
+	TagImageLength                 uint16 = 0x0101
+	TagBitsPerSample               uint16 = 0x0102
+	TagCompression                 uint16 = 0x0103
+	TagImageDescription            uint16 = 0x010E
+	TagMake                        uint16 = 0x010F
+	TagModel                       uint16 = 0x0110
+	TagOrientation                 uint16 = 0x0112
+	TagXResolution                 uint16 = 0x011A
+	TagYResolution                 uint16 = 0x011B
+	TagResolutionUnit              uint16 = 0x0128
+	TagSoftware                    uint16 = 0x0131
+	TagDateTime                    uint16 = 0x0132
+	TagArtist                      uint16 = 0x013B
+	TagJpegInterchangeFormat       uint16 = 0x0201
+	TagJpegInterchangeFormatLength uint16 = 0x0202
+	TagYCbCrPositioning            uint16 = 0x0213
+	TagCopyright                   uint16 = 0x8298
+	TagExifIfdPointer              uint16 = 0x8769
+	TagGpsIfdPointer               uint16 = 0x8825
+)
+
+// Exif IFD tags.
+const (
+	TagExposureTime          uint16 = 0x829A
+	TagFNumber               uint16 = 0x829D
+	TagExposureProgram       uint16 = 0x8822
+	TagIsoSpeedRatings       uint16 = 0x8827
+	TagExifVersion           uint16 = 0x9000
+	TagDateTimeOriginal      uint16 = 0x9003
+	TagDateTimeDigitized     uint16 = 0x9004
+	TagOffsetTime            uint16 = 0x9010
+	TagShutterSpeedValue     uint16 = 0x9201
+	TagApertureValue         uint16 = 0x9202
+	TagFlash                 uint16 = 0x9209
+	TagFocalLength           uint16 = 0x920A
+	TagMakerNote             uint16 = 0x927C
+	TagUserComment           uint16 = 0x9286
+	TagColorSpace            uint16 = 0xA001
+	TagPixelXDimension       uint16 = 0xA002
+	TagPixelYDimension       uint16 = 0xA003
+	TagInteropIfdPointer     uint16 = 0xA005
+	TagFocalLengthIn35mmFilm uint16 = 0xA405
+	TagImageUniqueId         uint16 = 0xA420
+	TagCameraOwnerName       uint16 = 0xA430
+	TagBodySerialNumber      uint16 = 0xA431
+	TagLensMake              uint16 = 0xA433
+	TagLensModel             uint16 = 0xA434
+	TagLensSerialNumber      uint16 = 0xA435
+)
+
+// GPS IFD tags.
+const (
+	TagGpsVersionId    uint16 = 0x0000
+	TagGpsLatitudeRef  uint16 = 0x0001
+	TagGpsLatitude     uint16 = 0x0002
+	TagGpsLongitudeRef uint16 = 0x0003
+	TagGpsLongitude    uint16 = 0x0004
+	TagGpsAltitudeRef  uint16 = 0x0005
+	TagGpsAltitude     uint16 = 0x0006
+	TagGpsTimeStamp    uint16 = 0x0007
+	TagGpsDateStamp    uint16 = 0x001D
+)
+
+// Interoperability IFD tags.
+const (
+	TagInteropIndex uint16 = 0x0001
+)
+
+// Pointer tags, which are managed by the serializer and never stored in `Ifd.Tags`.
+func isPointerTag(ifd IfdType, id uint16) bool {
+	switch ifd {
+	case Ifd0:
+		return id == TagExifIfdPointer || id == TagGpsIfdPointer
+	case Ifd1:
+		return id == TagJpegInterchangeFormat || id == TagJpegInterchangeFormatLength
+	case ExifIfd:
+		return id == TagInteropIfdPointer
+	default:
+		return false
+	}
+}
@@ -0,0 +1,188 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// Create EXIF data covering all IFDs and value types.
+func createTestExif(order binary.ByteOrder) *Exif {
+	e := New(order)
+
+	e.Set(Ifd0, NewAsciiTag(TagMake, "Camera Maker"))
+	e.Set(Ifd0, NewAsciiTag(TagModel, "X")) // Short enough to be stored in entry.
+	e.Set(Ifd0, NewShortTag(TagOrientation, 6))
+	e.Set(Ifd0, NewRationalTag(TagXResolution, Rational{72, 1}))
+	e.Set(Ifd0, &Tag{Id: 0x9999, Type: TypeSRational, Value: []SRational{{-1, 3}, {5, -7}}})
+	e.Set(Ifd0, &Tag{Id: 0x9998, Type: TypeFloat, Value: []float32{1.5}})
+	e.Set(Ifd0, &Tag{Id: 0x9997, Type: TypeDouble, Value: []float64{-2.25}})
+	e.Set(Ifd0, &Tag{Id: 0x9996, Type: TypeSByte, Value: []int8{-1, 2}})
+	e.Set(Ifd0, &Tag{Id: 0x9995, Type: TypeSShort, Value: []int16{-300}})
+	e.Set(Ifd0, &Tag{Id: 0x9994, Type: TypeSLong, Value: []int32{-70000, 70000}})
+	e.Set(Ifd0, &Tag{Id: 0x9993, Type: TypeByte, Value: []uint8{1, 2, 3, 4, 5}})
+
+	e.Set(ExifIfd, NewAsciiTag(TagDateTimeOriginal, "2024:04:13 12:34:56"))
+	e.Set(ExifIfd, NewLongTag(TagPixelXDimension, 4032))
+	e.Set(ExifIfd, NewUndefinedTag(TagExifVersion, []byte("0232")))
+
+	e.Set(InteropIfd, NewAsciiTag(TagInteropIndex, "R98"))
+
+	e.Set(GpsIfd, &Tag{Id: TagGpsVersionId, Type: TypeByte, Value: []uint8{2, 3, 0, 0}})
+	e.Set(GpsIfd, NewAsciiTag(TagGpsLatitudeRef, "N"))
+	e.Set(GpsIfd, NewRationalTag(TagGpsLatitude, Rational{25, 1}, Rational{2, 1}, Rational{1234, 100}))
+
+	e.Set(Ifd1, NewShortTag(TagCompression, 6))
+	e.Thumbnail = []byte("\xFF\xD8fake thumbnail\xFF\xD9")
+
+	return e
+}
+
+func TestExifRoundTrip(t *testing.T) {
+
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+
+		original := createTestExif(order)
+		raw, err := original.Bytes()
+		if err != nil {
+			t.Fatalf("[%v] Failed to serialize: %v", order, err)
+		}
+
+		parsed, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("[%v] Failed to parse: %v", order, err)
+		}
+
+		if parsed.ByteOrder != order {
+			t.Errorf("[%v] Byte order mismatch", order)
+		}
+		if !reflect.DeepEqual(parsed.Ifds, original.Ifds) {
+			for ifd, dir := range original.Ifds {
+				for id, tag := range dir.Tags {
+					got, _ := parsed.Get(ifd, id)
+					if !reflect.DeepEqual(got, tag) {
+						t.Errorf("[%v] %v tag %04X mismatch: %+v != %+v", order, ifd, id, got, tag)
+					}
+				}
+			}
+			t.Fatalf("[%v] IFDs mismatch", order)
+		}
+		if !bytes.Equal(parsed.Thumbnail, original.Thumbnail) {
+			t.Errorf("[%v] Thumbnail mismatch", order)
+		}
+
+		// Serialization should be stable.
+		again, err := parsed.Bytes()
+		if err != nil {
+			t.Fatalf("[%v] Failed to serialize: %v", order, err)
+		}
+		if !bytes.Equal(again, raw) {
+			t.Errorf("[%v] Serialization is not stable", order)
+		}
+	}
+}
+
+func TestExifParseLittleEndian(t *testing.T) {
+
+	// IFD0 with Orientation and Make, Make value is stored after IFD.
+	raw := []byte{
+		'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, // Header.
+		0x02, 0x00, // 2 entries.
+		0x0F, 0x01, 0x02, 0x00, 0x06, 0x00, 0x00, 0x00, 0x26, 0x00, 0x00, 0x00, // Make, ASCII, 6, offset 38.
+		0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, // Orientation, SHORT, 1, 8.
+		0x00, 0x00, 0x00, 0x00, // No next IFD.
+		'C', 'a', 'n', 'o', 'n', 0x00,
+	}
+
+	// Parse with APP1 header.
+	parsed, err := Parse(append([]byte("Exif\x00\x00"), raw...))
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	tag, ok := parsed.Get(Ifd0, TagOrientation)
+	if !ok {
+		t.Fatalf("Orientation not found")
+	}
+	if v, _ := tag.Int(0); v != 8 {
+		t.Errorf("Expected orientation 8, got %v", v)
+	}
+
+	tag, ok = parsed.Get(Ifd0, TagMake)
+	if !ok {
+		t.Fatalf("Make not found")
+	}
+	if v, _ := tag.Ascii(); v != "Canon" {
+		t.Errorf("Expected Canon, got %q", v)
+	}
+}
+
+func TestExifParseInvalid(t *testing.T) {
+
+	cases := [][]byte{
+		nil,
+		[]byte("XX\x00\x2A\x00\x00\x00\x08"), // Invalid byte order.
+		[]byte("MM\x00\x2B\x00\x00\x00\x08"), // Invalid magic number.
+		[]byte("MM\x00\x2A\x00\x00\x00\x40"), // IFD outside data.
+		[]byte("MM\x00\x2A\x00\x00\x00\x08\x00\x00\x00\x00\x00\x08"), // IFD1 loops back to IFD0.
+	}
+
+	for i, raw := range cases {
+		_, err := Parse(raw)
+		if err == nil {
+			t.Errorf("[%d] Expected error", i)
+		}
+	}
+}
+
+func TestExifThumbnailOutsideData(t *testing.T) {
+
+	raw, err := createTestExif(binary.BigEndian).Bytes()
+	if err != nil {
+		t.Fatalf("Failed to serialize: %v", err)
+	}
+
+	// Make IFD1 thumbnail length overrun the data.
+	entry := make([]byte, 8)
+	binary.BigEndian.PutUint16(entry[0:2], TagJpegInterchangeFormatLength)
+	binary.BigEndian.PutUint16(entry[2:4], uint16(TypeLong))
+	binary.BigEndian.PutUint32(entry[4:8], 1)
+	pos := bytes.Index(raw, entry)
+	if pos == -1 {
+		t.Fatalf("Thumbnail length entry not found")
+	}
+	binary.BigEndian.PutUint32(raw[pos+8:pos+12], uint32(len(raw)))
+
+	parsed, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if parsed.Thumbnail != nil {
+		t.Errorf("Expected thumbnail to be dropped, got %d bytes", len(parsed.Thumbnail))
+	}
+
+	// Other IFDs are still parsed.
+	if tag, ok := parsed.Get(Ifd0, TagOrientation); !ok {
+		t.Errorf("Orientation not found")
+	} else if v, _ := tag.Int(0); v != 6 {
+		t.Errorf("Expected orientation 6, got %v", v)
+	}
+	if _, ok := parsed.Get(ExifIfd, TagDateTimeOriginal); !ok {
+		t.Errorf("DateTimeOriginal not found")
+	}
+	if _, ok := parsed.Get(GpsIfd, TagGpsLatitude); !ok {
+		t.Errorf("GPS latitude not found")
+	}
+}
+
+func TestExifTagTypeMismatch(t *testing.T) {
+
+	e := New(binary.BigEndian)
+	e.Set(Ifd0, &Tag{Id: TagOrientation, Type: TypeLong, Value: []uint16{1}})
+
+	_, err := e.Bytes()
+	if err != ErrInvalidTagValue {
+		t.Errorf("Expected ErrInvalidTagValue, got: %v", err)
+	}
+}
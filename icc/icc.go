@@ -1,9 +1,25 @@
 package icc
 
+import "errors"
+
+// Define errors.
+var (
+	ErrInvalidProfile = errors.New("invalid icc profile")
+)
+
 type IccEmbedder interface {
 	EmbedIccProfile(icc_profile []byte) error
 }
 
+type IccExtractor interface {
+	ExtractIccProfile() ([]byte, error)
+}
+
+// Get predefined ICC profile by name.
+func GetProfile(profile_name string) ([]byte, error) {
+	return get_icc_profile(profile_name)
+}
+
 // Embed ICC profile to image.
 func EmbedIccProfile(profile_name string, target IccEmbedder) error {
 
@@ -15,3 +31,20 @@ func EmbedIccProfile(profile_name string, target IccEmbedder) error {
 	err = target.EmbedIccProfile(raw_profile_bytes) // Embed ICC profile to target image.
 	return err
 }
+
+// Extract ICC profile from image.
+//
+// Returns nil if image has no embedded profile.
+func ExtractIccProfile(target IccExtractor) ([]byte, error) {
+
+	raw_profile_bytes, err := target.ExtractIccProfile() // Extract ICC profile from target image.
+	if err != nil || raw_profile_bytes == nil {
+		return nil, err
+	}
+
+	if !validateProfile(raw_profile_bytes) { // Check profile length.
+		return nil, ErrInvalidProfile
+	}
+
+	return raw_profile_bytes, nil
+}
@@ -5,9 +5,7 @@ import (
 	"compress/gzip"
 	"encoding/base64"
 	"encoding/binary"
-	"fmt"
 	"io"
-	"strings"
 )
 
 // Predefined Display-P3 ICC profile compressed and base64 encoded.
@@ -122,37 +120,16 @@ func decodeProfile(encoded_profile string) ([]byte, error) {
 	return decoded_buf.Bytes(), nil
 }
 
-// Get profile by name.
+// Get profile by name from default registry.
 func get_icc_profile(profile_name string) ([]byte, error) {
-	var err error = nil
-	var raw_profile []byte = nil
-
-	switch strings.ToUpper(profile_name) {
-
-	case "SRGB":
-		raw_profile, err = decodeProfile(srgb)
-
-	case "DISPLAY P3":
-		raw_profile, err = decodeProfile(display_p3)
-
-	case "DCI P3":
-		raw_profile, err = decodeProfile(dci_p3)
-
-	case "ADOBE RGB":
-		raw_profile, err = decodeProfile(adobe_rgb)
-
-	case "ROMM RGB":
-		raw_profile, err = decodeProfile(romm_rgb)
-
-	default:
-		err = fmt.Errorf("profile not found")
-	}
-
-	return raw_profile, err
+	return DefaultRegistry.Get(profile_name)
 }
 
 // Validate profile using length (0~3 bytes, big endian)
 func validateProfile(raw_profile []byte) bool {
+	if len(raw_profile) < 4 {
+		return false
+	}
 	length := binary.BigEndian.Uint32(raw_profile[0:4])
 	return uint32(len(raw_profile)) == length
 }
@@ -0,0 +1,505 @@
+package icc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Define errors.
+var (
+	ErrInvalidTagType = errors.New("invalid icc tag type")
+)
+
+// Profile/device class signatures.
+const (
+	ClassInput      = "scnr"
+	ClassDisplay    = "mntr"
+	ClassOutput     = "prtr"
+	ClassLink       = "link"
+	ClassAbstract   = "abst"
+	ClassColorSpace = "spac"
+	ClassNamedColor = "nmcl"
+)
+
+// Rendering intent.
+type RenderingIntent uint32
+
+const (
+	IntentPerceptual           RenderingIntent = 0
+	IntentRelativeColorimetric RenderingIntent = 1
+	IntentSaturation           RenderingIntent = 2
+	IntentAbsoluteColorimetric RenderingIntent = 3
+)
+
+func (intent RenderingIntent) String() string {
+	switch intent {
+	case IntentPerceptual:
+		return "perceptual"
+	case IntentRelativeColorimetric:
+		return "relative colorimetric"
+	case IntentSaturation:
+		return "saturation"
+	case IntentAbsoluteColorimetric:
+		return "absolute colorimetric"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint32(intent))
+	}
+}
+
+const profileHeaderSize = 128 // Fixed header size.
+
+// Profile version, stored as BCD in the header.
+type ProfileVersion struct {
+	Major  uint8
+	Minor  uint8
+	Bugfix uint8
+}
+
+func (v ProfileVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Bugfix)
+}
+
+// CIE XYZ tristimulus value.
+type XYZNumber struct {
+	X, Y, Z float64
+}
+
+// ICC profile header.
+type ProfileHeader struct {
+	Size               uint32
+	PreferredCmm       string
+	Version            ProfileVersion
+	DeviceClass        string // One of `Class*` signatures.
+	ColorSpace         string // Data colour space, e.g. "RGB ", "CMYK", "GRAY".
+	Pcs                string // Profile connection space, "XYZ " or "Lab ".
+	CreationDate       time.Time
+	Platform           string
+	Flags              uint32
+	DeviceManufacturer string
+	DeviceModel        uint32
+	DeviceAttributes   uint64
+	RenderingIntent    RenderingIntent
+	Illuminant         XYZNumber // PCS illuminant, should be D50.
+	Creator            string
+	ProfileId          [16]byte // MD5 of the profile, zero if not computed.
+}
+
+// Tag table entry.
+type TagEntry struct {
+	Signature string
+	Offset    uint32
+	Size      uint32
+}
+
+// Tone reproduction curve, decoded from `curv` or `para` tag.
+type ToneCurve struct {
+	Type         string    // "curv" or "para".
+	Table        []uint16  // Sampled curve of `curv` type, empty means identity.
+	Gamma        float64   // Gamma of `curv` type with single entry.
+	FunctionType uint16    // Function type of `para` type.
+	Params       []float64 // Parameters of `para` type (g, a, b, c, d, e, f).
+}
+
+// Parsed ICC profile.
+type Profile struct {
+	Header ProfileHeader
+	Tags   []TagEntry
+
+	// Decoded common tags, nil or empty if not present.
+	Description         string      // desc
+	Copyright           string      // cprt
+	WhitePoint          *XYZNumber  // wtpt
+	RedColorant         *XYZNumber  // rXYZ
+	GreenColorant       *XYZNumber  // gXYZ
+	BlueColorant        *XYZNumber  // bXYZ
+	RedTRC              *ToneCurve  // rTRC
+	GreenTRC            *ToneCurve  // gTRC
+	BlueTRC             *ToneCurve  // bTRC
+	ChromaticAdaptation *[9]float64 // chad, 3x3 matrix in row-major order.
+
+	raw []byte // Raw profile bytes.
+}
+
+// Convert s15Fixed16Number to float.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536.0
+}
+
+// Convert 4 bytes signature to string.
+func signature(b []byte) string {
+	return string(b[0:4])
+}
+
+// Decode dateTimeNumber.
+func decodeDateTime(b []byte) time.Time {
+	return time.Date(
+		int(binary.BigEndian.Uint16(b[0:2])),
+		time.Month(binary.BigEndian.Uint16(b[2:4])),
+		int(binary.BigEndian.Uint16(b[4:6])),
+		int(binary.BigEndian.Uint16(b[6:8])),
+		int(binary.BigEndian.Uint16(b[8:10])),
+		int(binary.BigEndian.Uint16(b[10:12])),
+		0, time.UTC)
+}
+
+// Parse profile header and tag table.
+func ParseProfile(raw_profile []byte) (*Profile, error) {
+
+	// Check profile length.
+	if len(raw_profile) < profileHeaderSize+4 || !validateProfile(raw_profile) {
+		return nil, ErrInvalidProfile
+	}
+
+	// Check profile file signature.
+	if signature(raw_profile[36:40]) != "acsp" {
+		return nil, ErrInvalidProfile
+	}
+
+	profile := &Profile{raw: raw_profile}
+
+	// Parse header.
+	header := &profile.Header
+	header.Size = binary.BigEndian.Uint32(raw_profile[0:4])
+	header.PreferredCmm = signature(raw_profile[4:8])
+	header.Version = ProfileVersion{
+		Major:  raw_profile[8],
+		Minor:  raw_profile[9] >> 4,
+		Bugfix: raw_profile[9] & 0x0F,
+	}
+	header.DeviceClass = signature(raw_profile[12:16])
+	header.ColorSpace = signature(raw_profile[16:20])
+	header.Pcs = signature(raw_profile[20:24])
+	header.CreationDate = decodeDateTime(raw_profile[24:36])
+	header.Platform = signature(raw_profile[40:44])
+	header.Flags = binary.BigEndian.Uint32(raw_profile[44:48])
+	header.DeviceManufacturer = signature(raw_profile[48:52])
+	header.DeviceModel = binary.BigEndian.Uint32(raw_profile[52:56])
+	header.DeviceAttributes = binary.BigEndian.Uint64(raw_profile[56:64])
+	header.RenderingIntent = RenderingIntent(binary.BigEndian.Uint32(raw_profile[64:68]))
+	header.Illuminant = decodeXYZNumber(raw_profile[68:80])
+	header.Creator = signature(raw_profile[80:84])
+	copy(header.ProfileId[:], raw_profile[84:100])
+
+	// Parse tag table.
+	tag_count := int(binary.BigEndian.Uint32(raw_profile[128:132]))
+	if tag_count > (len(raw_profile)-profileHeaderSize-4)/12 { // Tag table overruns the profile.
+		return nil, ErrInvalidProfile
+	}
+
+	profile.Tags = make([]TagEntry, 0, tag_count)
+	for i := 0; i < tag_count; i++ {
+		entry := raw_profile[132+i*12 : 144+i*12]
+		tag := TagEntry{
+			Signature: signature(entry[0:4]),
+			Offset:    binary.BigEndian.Uint32(entry[4:8]),
+			Size:      binary.BigEndian.Uint32(entry[8:12]),
+		}
+
+		// Tag data should be inside the profile.
+		if uint64(tag.Offset)+uint64(tag.Size) > uint64(len(raw_profile)) || tag.Size < 8 {
+			return nil, ErrInvalidProfile
+		}
+		profile.Tags = append(profile.Tags, tag)
+	}
+
+	// Decode common tags.
+	err := profile.decodeCommonTags()
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// Get raw profile bytes.
+func (p *Profile) Bytes() []byte {
+	return p.raw
+}
+
+// Get raw tag data, including the type signature.
+func (p *Profile) TagData(tag_signature string) ([]byte, bool) {
+	for _, tag := range p.Tags {
+		if tag.Signature == tag_signature {
+			return p.raw[tag.Offset : tag.Offset+tag.Size], true
+		}
+	}
+	return nil, false
+}
+
+// Compute profile ID (MD5) as defined in ICC.1:2010 7.2.18.
+//
+// Profile flags, rendering intent and profile ID fields are zeroed before hashing.
+func (p *Profile) ComputeProfileId() [16]byte {
+	buf := make([]byte, len(p.raw))
+	copy(buf, p.raw)
+
+	clear(buf[44:48])  // Profile flags.
+	clear(buf[64:68])  // Rendering intent.
+	clear(buf[84:100]) // Profile ID.
+
+	return md5.Sum(buf)
+}
+
+// Check if profile is a matrix/TRC RGB profile.
+func (p *Profile) IsMatrixShaper() bool {
+	return p.Header.ColorSpace == "RGB " && p.Header.Pcs == "XYZ " &&
+		p.RedColorant != nil && p.GreenColorant != nil && p.BlueColorant != nil &&
+		p.RedTRC != nil && p.GreenTRC != nil && p.BlueTRC != nil
+}
+
+// Decode common tags into profile fields.
+func (p *Profile) decodeCommonTags() error {
+
+	var err error
+
+	// Text tags.
+	if data, ok := p.TagData("desc"); ok {
+		p.Description, err = decodeText(data)
+		if err != nil {
+			return err
+		}
+	}
+	if data, ok := p.TagData("cprt"); ok {
+		p.Copyright, err = decodeText(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	// XYZ tags.
+	xyz_tags := map[string]**XYZNumber{
+		"wtpt": &p.WhitePoint,
+		"rXYZ": &p.RedColorant,
+		"gXYZ": &p.GreenColorant,
+		"bXYZ": &p.BlueColorant,
+	}
+	for tag_signature, field := range xyz_tags {
+		if data, ok := p.TagData(tag_signature); ok {
+			*field, err = decodeXYZType(data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Curve tags.
+	curve_tags := map[string]**ToneCurve{
+		"rTRC": &p.RedTRC,
+		"gTRC": &p.GreenTRC,
+		"bTRC": &p.BlueTRC,
+	}
+	for tag_signature, field := range curve_tags {
+		if data, ok := p.TagData(tag_signature); ok {
+			*field, err = decodeCurve(data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Chromatic adaptation matrix.
+	if data, ok := p.TagData("chad"); ok {
+		if signature(data) != "sf32" || len(data) < 8+9*4 {
+			return ErrInvalidTagType
+		}
+		matrix := new([9]float64)
+		for i := range matrix {
+			matrix[i] = s15Fixed16(data[8+i*4:])
+		}
+		p.ChromaticAdaptation = matrix
+	}
+
+	return nil
+}
+
+// Decode XYZNumber.
+func decodeXYZNumber(b []byte) XYZNumber {
+	return XYZNumber{
+		X: s15Fixed16(b[0:4]),
+		Y: s15Fixed16(b[4:8]),
+		Z: s15Fixed16(b[8:12]),
+	}
+}
+
+// Decode first value of XYZType.
+func decodeXYZType(data []byte) (*XYZNumber, error) {
+	if signature(data) != "XYZ " || len(data) < 20 {
+		return nil, ErrInvalidTagType
+	}
+	xyz := decodeXYZNumber(data[8:20])
+	return &xyz, nil
+}
+
+// Decode textType, textDescriptionType or multiLocalizedUnicodeType.
+//
+// For multiLocalizedUnicodeType, English record is preferred, otherwise the first record is used.
+func decodeText(data []byte) (string, error) {
+	switch signature(data) {
+	case "text":
+		return string(bytes.TrimRight(data[8:], "\x00")), nil
+
+	case "desc":
+		if len(data) < 12 {
+			return "", ErrInvalidTagType
+		}
+		count := binary.BigEndian.Uint32(data[8:12])
+		if uint64(count) > uint64(len(data)-12) {
+			return "", ErrInvalidTagType
+		}
+		return string(bytes.TrimRight(data[12:12+count], "\x00")), nil
+
+	case "mluc":
+		if len(data) < 16 {
+			return "", ErrInvalidTagType
+		}
+		record_count := int(binary.BigEndian.Uint32(data[8:12]))
+		record_size := int(binary.BigEndian.Uint32(data[12:16]))
+		if record_count == 0 {
+			return "", nil
+		}
+		if record_size < 12 || record_count > (len(data)-16)/record_size {
+			return "", ErrInvalidTagType
+		}
+
+		// Find English record.
+		selected := data[16 : 16+record_size]
+		for i := 0; i < record_count; i++ {
+			record := data[16+i*record_size : 16+(i+1)*record_size]
+			if string(record[0:2]) == "en" {
+				selected = record
+				break
+			}
+		}
+
+		length := binary.BigEndian.Uint32(selected[4:8])
+		offset := binary.BigEndian.Uint32(selected[8:12])
+		if uint64(offset)+uint64(length) > uint64(len(data)) || length%2 != 0 {
+			return "", ErrInvalidTagType
+		}
+
+		// Decode UTF-16BE string.
+		utf16_string := make([]uint16, length/2)
+		for i := range utf16_string {
+			utf16_string[i] = binary.BigEndian.Uint16(data[int(offset)+i*2:])
+		}
+		return strings.TrimRight(string(utf16.Decode(utf16_string)), "\x00"), nil
+
+	default:
+		return "", ErrInvalidTagType
+	}
+}
+
+// Parameter count of each parametric curve function type.
+var paraParamCount = []int{1, 3, 4, 5, 7}
+
+// Decode curveType or parametricCurveType.
+func decodeCurve(data []byte) (*ToneCurve, error) {
+
+	if len(data) < 12 {
+		return nil, ErrInvalidTagType
+	}
+
+	switch signature(data) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(data[8:12]))
+		if count > (len(data)-12)/2 {
+			return nil, ErrInvalidTagType
+		}
+
+		curve := &ToneCurve{Type: "curv"}
+		switch count {
+		case 0: // Identity.
+			curve.Gamma = 1
+		case 1: // Gamma in u8Fixed8Number.
+			curve.Gamma = float64(binary.BigEndian.Uint16(data[12:14])) / 256.0
+		default: // Sampled curve.
+			curve.Table = make([]uint16, count)
+			for i := range curve.Table {
+				curve.Table[i] = binary.BigEndian.Uint16(data[12+i*2:])
+			}
+		}
+		return curve, nil
+
+	case "para":
+		function_type := binary.BigEndian.Uint16(data[8:10])
+		if int(function_type) >= len(paraParamCount) {
+			return nil, ErrInvalidTagType
+		}
+		param_count := paraParamCount[function_type]
+		if len(data) < 12+param_count*4 {
+			return nil, ErrInvalidTagType
+		}
+
+		curve := &ToneCurve{Type: "para", FunctionType: function_type, Params: make([]float64, param_count)}
+		for i := range curve.Params {
+			curve.Params[i] = s15Fixed16(data[12+i*4:])
+		}
+		return curve, nil
+
+	default:
+		return nil, ErrInvalidTagType
+	}
+}
+
+// Evaluate curve at x in range [0, 1].
+func (c *ToneCurve) Eval(x float64) float64 {
+
+	x = math.Max(0, math.Min(1, x)) // Clamp input.
+
+	switch c.Type {
+	case "curv":
+		if len(c.Table) == 0 {
+			return math.Pow(x, c.Gamma)
+		}
+
+		// Linear interpolation between samples.
+		pos := x * float64(len(c.Table)-1)
+		idx := int(pos)
+		if idx >= len(c.Table)-1 {
+			return float64(c.Table[len(c.Table)-1]) / 65535.0
+		}
+		frac := pos - float64(idx)
+		return (float64(c.Table[idx])*(1-frac) + float64(c.Table[idx+1])*frac) / 65535.0
+
+	case "para":
+		p := c.Params
+		var y float64
+		switch c.FunctionType {
+		case 0: // Y = X^g
+			y = math.Pow(x, p[0])
+		case 1: // Y = (aX+b)^g for X >= -b/a, else 0
+			if x >= -p[2]/p[1] {
+				y = math.Pow(p[1]*x+p[2], p[0])
+			}
+		case 2: // Y = (aX+b)^g + c for X >= -b/a, else c
+			if x >= -p[2]/p[1] {
+				y = math.Pow(p[1]*x+p[2], p[0]) + p[3]
+			} else {
+				y = p[3]
+			}
+		case 3: // Y = (aX+b)^g for X >= d, else cX
+			if x >= p[4] {
+				y = math.Pow(p[1]*x+p[2], p[0])
+			} else {
+				y = p[3] * x
+			}
+		case 4: // Y = (aX+b)^g + e for X >= d, else cX + f
+			if x >= p[4] {
+				y = math.Pow(p[1]*x+p[2], p[0]) + p[5]
+			} else {
+				y = p[3]*x + p[6]
+			}
+		}
+		return math.Max(0, math.Min(1, y))
+
+	default:
+		return x
+	}
+}
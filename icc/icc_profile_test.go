@@ -0,0 +1,98 @@
+package icc
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParsePresetProfiles(t *testing.T) {
+
+	for _, name := range []string{"sRGB", "Display P3", "DCI P3", "Adobe RGB", "ROMM RGB"} {
+
+		raw_profile, err := get_icc_profile(name)
+		if err != nil {
+			t.Fatalf("Failed to load profile %v: %v", name, err)
+		}
+
+		profile, err := ParseProfile(raw_profile)
+		if err != nil {
+			t.Fatalf("Failed to parse profile %v: %v", name, err)
+		}
+
+		// Check header.
+		if profile.Header.DeviceClass != ClassDisplay {
+			t.Errorf("[%v] Expected display class, got: %q", name, profile.Header.DeviceClass)
+		}
+		if profile.Header.ColorSpace != "RGB " || profile.Header.Pcs != "XYZ " {
+			t.Errorf("[%v] Unexpected colour space: %q -> %q", name, profile.Header.ColorSpace, profile.Header.Pcs)
+		}
+		if math.Abs(profile.Header.Illuminant.X-0.9642) > 0.001 {
+			t.Errorf("[%v] Expected D50 illuminant, got: %v", name, profile.Header.Illuminant)
+		}
+
+		// Check decoded tags.
+		if !profile.IsMatrixShaper() {
+			t.Errorf("[%v] Expected matrix/TRC profile", name)
+		}
+		if profile.Description == "" {
+			t.Errorf("[%v] Expected description", name)
+		}
+
+		// Colorants should add up to the PCS white point.
+		sum_x := profile.RedColorant.X + profile.GreenColorant.X + profile.BlueColorant.X
+		sum_y := profile.RedColorant.Y + profile.GreenColorant.Y + profile.BlueColorant.Y
+		if math.Abs(sum_x-0.9642) > 0.01 || math.Abs(sum_y-1) > 0.01 {
+			t.Errorf("[%v] Colorants don't add up to D50: X=%v Y=%v", name, sum_x, sum_y)
+		}
+
+		// Profile ID should match if it's present.
+		if profile.Header.ProfileId != [16]byte{} && profile.ComputeProfileId() != profile.Header.ProfileId {
+			t.Errorf("[%v] Profile ID mismatch", name)
+		}
+
+		t.Logf("[%v] v%v %q", name, profile.Header.Version, profile.Description)
+	}
+}
+
+func TestProfileCurves(t *testing.T) {
+
+	raw_profile, _ := get_icc_profile("sRGB")
+	profile, err := ParseProfile(raw_profile)
+	if err != nil {
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	if !strings.Contains(profile.Description, "sRGB") {
+		t.Errorf("Unexpected description: %q", profile.Description)
+	}
+
+	// sRGB mid-grey is about 21.4% linear light.
+	if y := profile.RedTRC.Eval(0.5); math.Abs(y-0.214) > 0.002 {
+		t.Errorf("Unexpected sampled curve value: %v", y)
+	}
+
+	raw_profile, _ = get_icc_profile("Display P3")
+	profile, err = ParseProfile(raw_profile)
+	if err != nil {
+		t.Fatalf("Failed to parse profile: %v", err)
+	}
+	if profile.RedTRC.Type != "para" {
+		t.Errorf("Expected parametric curve, got: %v", profile.RedTRC.Type)
+	}
+	if y := profile.RedTRC.Eval(0.5); math.Abs(y-0.214) > 0.002 {
+		t.Errorf("Unexpected parametric curve value: %v", y)
+	}
+	if profile.ChromaticAdaptation == nil {
+		t.Errorf("Expected chromatic adaptation matrix")
+	}
+}
+
+func TestParseInvalidProfile(t *testing.T) {
+
+	for _, raw_profile := range [][]byte{nil, []byte("1234"), make([]byte, 200)} {
+		_, err := ParseProfile(raw_profile)
+		if err != ErrInvalidProfile {
+			t.Errorf("Expected ErrInvalidProfile, got: %v", err)
+		}
+	}
+}
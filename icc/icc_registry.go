@@ -0,0 +1,302 @@
+package icc
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// Define errors.
+var (
+	ErrProfileNotFound  = errors.New("profile not found")
+	ErrInvalidName      = errors.New("invalid profile name")
+	ErrNameConflict     = errors.New("name already used by another profile")
+	ErrUnsupportedClass = errors.New("unsupported profile class")
+)
+
+// Profile registry entry.
+type registryEntry struct {
+	name   string                 // Display name, as registered.
+	raw    []byte                 // Raw profile bytes, nil if not loaded yet.
+	loader func() ([]byte, error) // Lazy loader for predefined profiles.
+}
+
+// Registry of named ICC profiles.
+//
+// Names and aliases are resolved case-insensitively. Registry is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	profiles map[string]*registryEntry // Normalized name -> entry.
+	aliases  map[string]string         // Normalized alias -> normalized name.
+}
+
+// Default registry, which contains predefined profiles.
+var DefaultRegistry = newPresetRegistry()
+
+// Normalize profile name for lookup.
+func normalizeName(name string) string {
+	return strings.ToUpper(strings.TrimSpace(name))
+}
+
+// Create empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		profiles: make(map[string]*registryEntry),
+		aliases:  make(map[string]string),
+	}
+}
+
+// Create registry with predefined profiles.
+func newPresetRegistry() *Registry {
+	r := NewRegistry()
+
+	presets := []struct {
+		name    string
+		encoded string
+		aliases []string
+	}{
+		{"sRGB", srgb, []string{"sRGB IEC61966-2.1"}},
+		{"Display P3", display_p3, []string{"P3", "DisplayP3"}},
+		{"DCI P3", dci_p3, []string{"DCI-P3"}},
+		{"Adobe RGB", adobe_rgb, []string{"AdobeRGB", "Adobe RGB (1998)"}},
+		{"ROMM RGB", romm_rgb, []string{"ProPhoto RGB", "ProPhoto"}},
+	}
+
+	for _, preset := range presets {
+		encoded := preset.encoded
+		key := normalizeName(preset.name)
+		r.profiles[key] = &registryEntry{
+			name:   preset.name,
+			loader: func() ([]byte, error) { return decodeProfile(encoded) },
+		}
+		for _, alias := range preset.aliases {
+			r.aliases[normalizeName(alias)] = key
+		}
+	}
+
+	return r
+}
+
+// Validate raw profile before registration.
+//
+// Besides the length check, header should have valid signature and a supported device class.
+func validateRegisteredProfile(raw_profile []byte) error {
+
+	profile, err := ParseProfile(raw_profile)
+	if err != nil {
+		return err
+	}
+
+	switch profile.Header.DeviceClass {
+	case ClassInput, ClassDisplay, ClassOutput, ClassColorSpace:
+		return nil
+	default:
+		return ErrUnsupportedClass
+	}
+}
+
+// Register profile from bytes.
+//
+// Existing profile with same name is replaced. Aliases can't shadow other profile names.
+func (r *Registry) Register(name string, raw_profile []byte, aliases ...string) error {
+
+	key := normalizeName(name)
+	if key == "" {
+		return ErrInvalidName
+	}
+
+	err := validateRegisteredProfile(raw_profile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Name shouldn't be an alias of other profile.
+	if target, ok := r.aliases[key]; ok && target != key {
+		return ErrNameConflict
+	}
+
+	// Check aliases before modifying registry.
+	for _, alias := range aliases {
+		alias_key := normalizeName(alias)
+		if alias_key == "" {
+			return ErrInvalidName
+		}
+		if _, ok := r.profiles[alias_key]; ok && alias_key != key {
+			return ErrNameConflict
+		}
+	}
+
+	// Copy profile, so caller can't modify registered data.
+	raw := make([]byte, len(raw_profile))
+	copy(raw, raw_profile)
+
+	r.profiles[key] = &registryEntry{name: strings.TrimSpace(name), raw: raw}
+	for _, alias := range aliases {
+		r.aliases[normalizeName(alias)] = key
+	}
+
+	return nil
+}
+
+// Register profile from file.
+func (r *Registry) RegisterFile(name string, path string, aliases ...string) error {
+
+	raw_profile, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return r.Register(name, raw_profile, aliases...)
+}
+
+// Register all profiles matching the glob pattern in file system, e.g. an `embed.FS`.
+//
+// Profile name is the file name without extension. Returns the registered names.
+func (r *Registry) RegisterFS(fsys fs.FS, pattern string) ([]string, error) {
+
+	matches, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := make([]string, 0, len(matches))
+	for _, match := range matches {
+		raw_profile, err := fs.ReadFile(fsys, match)
+		if err != nil {
+			return registered, err
+		}
+
+		base := path.Base(match)
+		name := strings.TrimSuffix(base, path.Ext(base))
+
+		err = r.Register(name, raw_profile)
+		if err != nil {
+			return registered, err
+		}
+		registered = append(registered, name)
+	}
+
+	return registered, nil
+}
+
+// Add alias to registered profile.
+func (r *Registry) Alias(alias string, name string) error {
+
+	alias_key := normalizeName(alias)
+	if alias_key == "" {
+		return ErrInvalidName
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.resolve(name)
+	if !ok {
+		return ErrProfileNotFound
+	}
+	if _, ok := r.profiles[alias_key]; ok && alias_key != key {
+		return ErrNameConflict
+	}
+
+	r.aliases[alias_key] = key
+	return nil
+}
+
+// Remove profile and its aliases from registry.
+func (r *Registry) Unregister(name string) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.resolve(name)
+	if !ok {
+		return
+	}
+
+	delete(r.profiles, key)
+	for alias, target := range r.aliases {
+		if target == key {
+			delete(r.aliases, alias)
+		}
+	}
+}
+
+// Resolve name or alias to normalized profile name.
+// NOTE: Caller should hold the lock.
+func (r *Registry) resolve(name string) (string, bool) {
+	key := normalizeName(name)
+	if _, ok := r.profiles[key]; ok {
+		return key, true
+	}
+	if target, ok := r.aliases[key]; ok {
+		if _, ok := r.profiles[target]; ok {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// Get profile bytes by name or alias.
+func (r *Registry) Get(name string) ([]byte, error) {
+
+	r.mu.RLock()
+	key, ok := r.resolve(name)
+	if !ok {
+		r.mu.RUnlock()
+		return nil, ErrProfileNotFound
+	}
+	entry := r.profiles[key]
+	raw := entry.raw
+	r.mu.RUnlock()
+
+	// Return copy, so caller can't modify registered data.
+	if raw != nil {
+		return slices.Clone(raw), nil
+	}
+
+	// Load predefined profile.
+	raw, err := entry.loader()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	entry.raw = raw
+	r.mu.Unlock()
+
+	return slices.Clone(raw), nil
+}
+
+// List registered profile names, sorted.
+func (r *Registry) Names() []string {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.profiles))
+	for _, entry := range r.profiles {
+		names = append(names, entry.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Embed profile from registry to image.
+func (r *Registry) EmbedIccProfile(profile_name string, target IccEmbedder) error {
+
+	raw_profile_bytes, err := r.Get(profile_name) // Get ICC profile bytes.
+	if err != nil {
+		return err
+	}
+
+	return target.EmbedIccProfile(raw_profile_bytes) // Embed ICC profile to target image.
+}
@@ -0,0 +1,107 @@
+package icc
+
+import (
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+
+	names := DefaultRegistry.Names()
+	if len(names) != 5 {
+		t.Errorf("Expected 5 predefined profiles, got: %v", names)
+	}
+
+	// Lookup should be case-insensitive, and aliases should resolve.
+	for _, name := range []string{"srgb", "SRGB", "display p3", "p3", "ProPhoto RGB"} {
+		_, err := DefaultRegistry.Get(name)
+		if err != nil {
+			t.Errorf("Failed to get profile %q: %v", name, err)
+		}
+	}
+
+	_, err := DefaultRegistry.Get("FOGRA39")
+	if err != ErrProfileNotFound {
+		t.Errorf("Expected ErrProfileNotFound, got: %v", err)
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+
+	raw_profile, _ := get_icc_profile("Adobe RGB")
+
+	r := NewRegistry()
+	err := r.Register("Print Profile", raw_profile, "print", "GRACoL")
+	if err != nil {
+		t.Fatalf("Failed to register profile: %v", err)
+	}
+
+	for _, name := range []string{"print profile", "PRINT", "gracol"} {
+		got, err := r.Get(name)
+		if err != nil {
+			t.Errorf("Failed to get profile %q: %v", name, err)
+		} else if len(got) != len(raw_profile) {
+			t.Errorf("Profile %q mismatch", name)
+		}
+	}
+
+	// Modifying returned profile doesn't affect registry.
+	got, _ := r.Get("print")
+	got[0] ^= 0xFF
+	if again, _ := r.Get("print"); again[0] != raw_profile[0] {
+		t.Errorf("Expected registered profile to be unchanged")
+	}
+
+	// Alias can't shadow other profile.
+	err = r.Register("Another", raw_profile, "Print Profile")
+	if err != ErrNameConflict {
+		t.Errorf("Expected ErrNameConflict, got: %v", err)
+	}
+
+	// Invalid profile is rejected.
+	err = r.Register("Broken", []byte("not a profile"))
+	if err == nil {
+		t.Errorf("Expected error for invalid profile")
+	}
+
+	// Remove profile.
+	r.Unregister("gracol")
+	_, err = r.Get("print")
+	if err != ErrProfileNotFound {
+		t.Errorf("Expected ErrProfileNotFound, got: %v", err)
+	}
+}
+
+func TestRegistryRegisterFS(t *testing.T) {
+
+	srgb_profile, _ := get_icc_profile("sRGB")
+	p3_profile, _ := get_icc_profile("Display P3")
+
+	fsys := fstest.MapFS{
+		"profiles/Web.icc":    {Data: srgb_profile},
+		"profiles/Wide.icc":   {Data: p3_profile},
+		"profiles/readme.txt": {Data: []byte("ignored")},
+	}
+
+	r := NewRegistry()
+	registered, err := r.RegisterFS(fsys, "profiles/*.icc")
+	if err != nil {
+		t.Fatalf("Failed to register profiles: %v", err)
+	}
+
+	if !slices.Equal(registered, []string{"Web", "Wide"}) {
+		t.Errorf("Unexpected registered profiles: %v", registered)
+	}
+	if !slices.Equal(r.Names(), []string{"Web", "Wide"}) {
+		t.Errorf("Unexpected profile names: %v", r.Names())
+	}
+
+	err = r.Alias("browser", "web")
+	if err != nil {
+		t.Fatalf("Failed to add alias: %v", err)
+	}
+	if _, err := r.Get("Browser"); err != nil {
+		t.Errorf("Failed to get profile by alias: %v", err)
+	}
+}
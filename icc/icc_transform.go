@@ -0,0 +1,306 @@
+package icc
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Define errors.
+var (
+	ErrUnsupportedProfile = errors.New("only matrix/trc rgb profiles are supported")
+	ErrUnsupportedIntent  = errors.New("rendering intent not supported")
+	ErrSingularMatrix     = errors.New("profile colorant matrix is not invertible")
+)
+
+// Size of output lookup table, which maps linear light to encoded value.
+const outputLutSize = 4096
+
+// 3x3 matrix in row-major order.
+type matrix3 [9]float64
+
+// Multiply matrix by vector.
+func (m matrix3) apply(x, y, z float64) (float64, float64, float64) {
+	return m[0]*x + m[1]*y + m[2]*z,
+		m[3]*x + m[4]*y + m[5]*z,
+		m[6]*x + m[7]*y + m[8]*z
+}
+
+// Multiply two matrices.
+func (m matrix3) mul(n matrix3) matrix3 {
+	var ret matrix3
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			ret[row*3+col] = m[row*3]*n[col] + m[row*3+1]*n[3+col] + m[row*3+2]*n[6+col]
+		}
+	}
+	return ret
+}
+
+// Invert matrix.
+func (m matrix3) inverse() (matrix3, error) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) -
+		m[1]*(m[3]*m[8]-m[5]*m[6]) +
+		m[2]*(m[3]*m[7]-m[4]*m[6])
+	if math.Abs(det) < 1e-12 {
+		return matrix3{}, ErrSingularMatrix
+	}
+
+	return matrix3{
+		(m[4]*m[8] - m[5]*m[7]) / det,
+		(m[2]*m[7] - m[1]*m[8]) / det,
+		(m[1]*m[5] - m[2]*m[4]) / det,
+		(m[5]*m[6] - m[3]*m[8]) / det,
+		(m[0]*m[8] - m[2]*m[6]) / det,
+		(m[2]*m[3] - m[0]*m[5]) / det,
+		(m[3]*m[7] - m[4]*m[6]) / det,
+		(m[1]*m[6] - m[0]*m[7]) / det,
+		(m[0]*m[4] - m[1]*m[3]) / det,
+	}, nil
+}
+
+// Transform between two matrix/TRC RGB profiles through the XYZ PCS.
+//
+// Source pixels are linearized with source TRC, converted to PCS with source colorant matrix,
+// converted back to destination linear RGB with inverted destination colorant matrix,
+// and finally encoded with inverted destination TRC.
+type Transform struct {
+	Intent RenderingIntent
+
+	input_lut  [3][]float64 // Source TRC, indexed by 16-bit encoded value.
+	matrix     matrix3      // Source linear RGB to destination linear RGB.
+	output_lut [3][]float64 // Inverted destination TRC, indexed by linear value.
+	luminance  [3]float64   // Luminance (Y) of destination primaries, used for gamut mapping.
+}
+
+// Get colorant matrix (linear RGB to PCS XYZ) of profile.
+func colorantMatrix(p *Profile) matrix3 {
+	return matrix3{
+		p.RedColorant.X, p.GreenColorant.X, p.BlueColorant.X,
+		p.RedColorant.Y, p.GreenColorant.Y, p.BlueColorant.Y,
+		p.RedColorant.Z, p.GreenColorant.Z, p.BlueColorant.Z,
+	}
+}
+
+// Get media white point in PCS of profile.
+//
+// For v4 profiles `wtpt` is always D50, the actual white point is recovered with `chad`.
+func mediaWhitePoint(p *Profile) XYZNumber {
+	if p.ChromaticAdaptation != nil {
+		chad := matrix3(*p.ChromaticAdaptation)
+		inverse_chad, err := chad.inverse()
+		if err == nil {
+			x, y, z := inverse_chad.apply(p.Header.Illuminant.X, p.Header.Illuminant.Y, p.Header.Illuminant.Z)
+			return XYZNumber{X: x, Y: y, Z: z}
+		}
+	}
+	if p.WhitePoint != nil {
+		return *p.WhitePoint
+	}
+	return p.Header.Illuminant
+}
+
+// Build input lookup table from curve.
+func buildInputLut(curve *ToneCurve) []float64 {
+	lut := make([]float64, 65536)
+	for i := range lut {
+		lut[i] = curve.Eval(float64(i) / 65535.0)
+	}
+	return lut
+}
+
+// Build inverted lookup table from curve.
+//
+// The curve is sampled, and each linear value is mapped back by binary search and interpolation.
+func buildOutputLut(curve *ToneCurve) []float64 {
+
+	// Sample forward curve.
+	const samples = 4096
+	forward := make([]float64, samples+1)
+	for i := range forward {
+		forward[i] = curve.Eval(float64(i) / samples)
+	}
+	// Force monotonic, inverse of non-monotonic curve is undefined.
+	for i := 1; i < len(forward); i++ {
+		forward[i] = math.Max(forward[i], forward[i-1])
+	}
+
+	lut := make([]float64, outputLutSize+1)
+	for i := range lut {
+		y := float64(i) / outputLutSize
+
+		// Find first sample not less than y.
+		idx := sort.SearchFloat64s(forward, y)
+		switch {
+		case idx == 0:
+			lut[i] = 0
+		case idx >= len(forward):
+			lut[i] = 1
+		default:
+			lo, hi := forward[idx-1], forward[idx]
+			frac := 0.0
+			if hi > lo {
+				frac = (y - lo) / (hi - lo)
+			}
+			lut[i] = (float64(idx-1) + frac) / samples
+		}
+	}
+	return lut
+}
+
+// Create transform between two profiles.
+//
+// Supported intents are relative colorimetric, perceptual and absolute colorimetric.
+// Since matrix/TRC profiles have no perceptual tables, perceptual intent maps out-of-gamut
+// colours by desaturating them towards grey of same luminance instead of clipping each channel.
+func NewTransform(src, dst *Profile, intent RenderingIntent) (*Transform, error) {
+
+	if !src.IsMatrixShaper() || !dst.IsMatrixShaper() {
+		return nil, ErrUnsupportedProfile
+	}
+
+	switch intent {
+	case IntentRelativeColorimetric, IntentPerceptual, IntentAbsoluteColorimetric:
+	default:
+		return nil, ErrUnsupportedIntent
+	}
+
+	src_matrix := colorantMatrix(src)
+	dst_matrix := colorantMatrix(dst)
+	dst_inverse, err := dst_matrix.inverse()
+	if err != nil {
+		return nil, err
+	}
+
+	// PCS adjustment, identity for relative colorimetric.
+	pcs_matrix := matrix3{1, 0, 0, 0, 1, 0, 0, 0, 1}
+	if intent == IntentAbsoluteColorimetric {
+		// Scale by ratio of media white points (ICC.1:2010 Annex D.4).
+		src_white := mediaWhitePoint(src)
+		dst_white := mediaWhitePoint(dst)
+		pcs_matrix = matrix3{
+			src_white.X / dst_white.X, 0, 0,
+			0, src_white.Y / dst_white.Y, 0,
+			0, 0, src_white.Z / dst_white.Z,
+		}
+	}
+
+	transform := &Transform{
+		Intent: intent,
+		matrix: dst_inverse.mul(pcs_matrix).mul(src_matrix),
+		input_lut: [3][]float64{
+			buildInputLut(src.RedTRC),
+			buildInputLut(src.GreenTRC),
+			buildInputLut(src.BlueTRC),
+		},
+		output_lut: [3][]float64{
+			buildOutputLut(dst.RedTRC),
+			buildOutputLut(dst.GreenTRC),
+			buildOutputLut(dst.BlueTRC),
+		},
+		luminance: [3]float64{dst.RedColorant.Y, dst.GreenColorant.Y, dst.BlueColorant.Y},
+	}
+
+	return transform, nil
+}
+
+// Create transform from raw profile bytes.
+func NewTransformFromBytes(src, dst []byte, intent RenderingIntent) (*Transform, error) {
+
+	src_profile, err := ParseProfile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	dst_profile, err := ParseProfile(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTransform(src_profile, dst_profile, intent)
+}
+
+// Encode linear value with output lookup table.
+func (t *Transform) encode(channel int, v float64) float64 {
+	v = math.Max(0, math.Min(1, v))
+	pos := v * outputLutSize
+	idx := int(pos)
+	if idx >= outputLutSize {
+		return t.output_lut[channel][outputLutSize]
+	}
+	frac := pos - float64(idx)
+	return t.output_lut[channel][idx]*(1-frac) + t.output_lut[channel][idx+1]*frac
+}
+
+// Map out-of-gamut colour into gamut by desaturating towards grey of same luminance.
+func (t *Transform) desaturate(r, g, b float64) (float64, float64, float64) {
+
+	y := t.luminance[0]*r + t.luminance[1]*g + t.luminance[2]*b
+	y = math.Max(0, math.Min(1, y))
+
+	// Find largest blend factor which keeps every channel in [0, 1].
+	factor := 1.0
+	for _, v := range []float64{r, g, b} {
+		if v > 1 && v != y {
+			factor = math.Min(factor, (1-y)/(v-y))
+		} else if v < 0 && v != y {
+			factor = math.Min(factor, y/(y-v))
+		}
+	}
+
+	return y + (r-y)*factor, y + (g-y)*factor, y + (b-y)*factor
+}
+
+// Convert a colour in 16-bit encoded RGB.
+func (t *Transform) Convert(r, g, b uint16) (uint16, uint16, uint16) {
+
+	// Linearize.
+	lr, lg, lb := t.input_lut[0][r], t.input_lut[1][g], t.input_lut[2][b]
+
+	// Convert to destination linear RGB.
+	lr, lg, lb = t.matrix.apply(lr, lg, lb)
+
+	// Gamut mapping.
+	if t.Intent == IntentPerceptual && (lr < 0 || lr > 1 || lg < 0 || lg > 1 || lb < 0 || lb > 1) {
+		lr, lg, lb = t.desaturate(lr, lg, lb)
+	}
+
+	// Encode.
+	return uint16(t.encode(0, lr)*65535 + 0.5),
+		uint16(t.encode(1, lg)*65535 + 0.5),
+		uint16(t.encode(2, lb)*65535 + 0.5)
+}
+
+// Convert image pixels.
+//
+// Alpha channel is kept as is. Output is always an 8-bit non-premultiplied RGBA image.
+func (t *Transform) ConvertImage(in image.Image) *image.NRGBA {
+
+	bounds := in.Bounds()
+	out := image.NewNRGBA(bounds.Sub(bounds.Min))
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+
+			// Get non-premultiplied colour.
+			c := color.NRGBA64Model.Convert(in.At(x, y)).(color.NRGBA64)
+
+			r, g, b := t.Convert(c.R, c.G, c.B)
+			out.SetNRGBA(x-bounds.Min.X, y-bounds.Min.Y, color.NRGBA{
+				R: to8Bit(r),
+				G: to8Bit(g),
+				B: to8Bit(b),
+				A: to8Bit(c.A),
+			})
+		}
+	}
+
+	return out
+}
+
+// Round 16-bit value to 8-bit.
+func to8Bit(v uint16) uint8 {
+	return uint8((uint32(v)*255 + 32767) / 65535)
+}
@@ -0,0 +1,130 @@
+package icc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Load predefined profile, fail test if not found.
+func loadTestProfile(t *testing.T, name string) *Profile {
+	raw_profile, err := get_icc_profile(name)
+	if err != nil {
+		t.Fatalf("Failed to load profile %v: %v", name, err)
+	}
+	profile, err := ParseProfile(raw_profile)
+	if err != nil {
+		t.Fatalf("Failed to parse profile %v: %v", name, err)
+	}
+	return profile
+}
+
+// Check if two 8-bit values are close enough.
+func closeTo(a, b uint8, tolerance int) bool {
+	diff := int(a) - int(b)
+	return diff >= -tolerance && diff <= tolerance
+}
+
+func TestTransformIdentity(t *testing.T) {
+
+	srgb := loadTestProfile(t, "sRGB")
+	transform, err := NewTransform(srgb, srgb, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("Failed to create transform: %v", err)
+	}
+
+	for _, v := range []uint16{0, 0x1234, 0x8000, 0xC0C0, 0xFFFF} {
+		r, g, b := transform.Convert(v, v, v)
+		if !closeTo(to8Bit(r), to8Bit(v), 1) || !closeTo(to8Bit(g), to8Bit(v), 1) || !closeTo(to8Bit(b), to8Bit(v), 1) {
+			t.Errorf("Expected %04X, got (%04X, %04X, %04X)", v, r, g, b)
+		}
+	}
+}
+
+func TestTransformSrgbToDisplayP3(t *testing.T) {
+
+	srgb := loadTestProfile(t, "sRGB")
+	p3 := loadTestProfile(t, "Display P3")
+
+	to_p3, err := NewTransform(srgb, p3, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("Failed to create transform: %v", err)
+	}
+
+	// sRGB red is about (234, 51, 35) in Display P3.
+	r, g, b := to_p3.Convert(0xFFFF, 0, 0)
+	if !closeTo(to8Bit(r), 234, 2) || !closeTo(to8Bit(g), 51, 2) || !closeTo(to8Bit(b), 35, 2) {
+		t.Errorf("Unexpected P3 value of sRGB red: (%d, %d, %d)", to8Bit(r), to8Bit(g), to8Bit(b))
+	}
+
+	// Convert back.
+	to_srgb, err := NewTransform(p3, srgb, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("Failed to create transform: %v", err)
+	}
+	r, g, b = to_srgb.Convert(r, g, b)
+	if !closeTo(to8Bit(r), 255, 1) || !closeTo(to8Bit(g), 0, 1) || !closeTo(to8Bit(b), 0, 1) {
+		t.Errorf("Unexpected round-trip value: (%d, %d, %d)", to8Bit(r), to8Bit(g), to8Bit(b))
+	}
+}
+
+func TestTransformPerceptualClip(t *testing.T) {
+
+	srgb := loadTestProfile(t, "sRGB")
+	p3 := loadTestProfile(t, "Display P3")
+
+	clip, _ := NewTransform(p3, srgb, IntentRelativeColorimetric)
+	perceptual, _ := NewTransform(p3, srgb, IntentPerceptual)
+
+	// P3 red is out of sRGB gamut.
+	_, clip_g, _ := clip.Convert(0xFFFF, 0, 0)
+	_, perceptual_g, _ := perceptual.Convert(0xFFFF, 0, 0)
+	if clip_g != 0 {
+		t.Errorf("Expected green channel to be clipped, got: %d", clip_g)
+	}
+	if perceptual_g == 0 {
+		t.Errorf("Expected green channel to be desaturated")
+	}
+
+	// In-gamut colour should be same for both intents.
+	r1, g1, b1 := clip.Convert(0x8000, 0x7000, 0x6000)
+	r2, g2, b2 := perceptual.Convert(0x8000, 0x7000, 0x6000)
+	if r1 != r2 || g1 != g2 || b1 != b2 {
+		t.Errorf("Expected in-gamut colour to be unchanged by gamut mapping")
+	}
+}
+
+func TestTransformImage(t *testing.T) {
+
+	srgb := loadTestProfile(t, "sRGB")
+	adobe := loadTestProfile(t, "Adobe RGB")
+
+	transform, err := NewTransform(srgb, adobe, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("Failed to create transform: %v", err)
+	}
+
+	in := image.NewRGBA(image.Rect(3, 3, 5, 5))
+	in.Set(3, 3, color.RGBA{R: 255, A: 255})
+	in.Set(4, 4, color.RGBA{R: 64, G: 64, B: 64, A: 128}) // Premultiplied.
+
+	out := transform.ConvertImage(in)
+	if out.Bounds() != image.Rect(0, 0, 2, 2) {
+		t.Errorf("Unexpected output bounds: %v", out.Bounds())
+	}
+	if c := out.NRGBAAt(1, 1); c.A != 128 || !closeTo(c.R, c.G, 1) || !closeTo(c.G, c.B, 1) {
+		t.Errorf("Expected grey with alpha kept, got: %v", c)
+	}
+	if c := out.NRGBAAt(0, 0); c.R >= 255 || c.G != 0 {
+		t.Errorf("Expected sRGB red to be inside Adobe RGB gamut, got: %v", c)
+	}
+}
+
+func TestTransformUnsupportedIntent(t *testing.T) {
+
+	srgb := loadTestProfile(t, "sRGB")
+	_, err := NewTransform(srgb, srgb, IntentSaturation)
+	if err != ErrUnsupportedIntent {
+		t.Errorf("Expected ErrUnsupportedIntent, got: %v", err)
+	}
+}
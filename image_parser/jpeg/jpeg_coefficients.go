@@ -0,0 +1,511 @@
+package jpeg_parser
+
+import (
+	"errors"
+	"sort"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrUnsupportedCoding = errors.New("unsupported jpeg coding process, only baseline huffman coding is supported")
+	ErrMissingTable      = errors.New("referenced jpeg table is not defined")
+	ErrMissingScanData   = errors.New("scan has no entropy-coded data")
+)
+
+// Quantized DCT coefficients of one component.
+type ComponentCoefficients struct {
+	BlocksWide int         // Width of block grid, padded to whole MCUs.
+	BlocksHigh int         // Height of block grid, padded to whole MCUs.
+	Blocks     [][64]int16 // Blocks in raster order, coefficients in natural (row-major) order.
+}
+
+// Quantized DCT coefficients of a baseline JPEG image.
+//
+// `Frame.Components` and `Components` are in the same order.
+type Coefficients struct {
+	Frame       *FrameHeader
+	Components  []ComponentCoefficients
+	QuantTables map[uint8]QuantTable
+}
+
+// Get MCU size in pixels.
+func (c *Coefficients) McuSize() (int, int) {
+	max_h, max_v := c.Frame.MaxScale()
+	return 8 * int(max_h), 8 * int(max_v)
+}
+
+// Get number of MCUs in each direction, partial MCUs at the edges included.
+func (c *Coefficients) McuCount() (int, int) {
+	mcu_w, mcu_h := c.McuSize()
+	return (int(c.Frame.Width) + mcu_w - 1) / mcu_w, (int(c.Frame.Height) + mcu_h - 1) / mcu_h
+}
+
+// Get number of blocks of component covering the image, as coded in a non-interleaved scan.
+func (c *Coefficients) componentExtent(index int) (int, int) {
+	max_h, max_v := c.Frame.MaxScale()
+	comp := c.Frame.Components[index]
+	width := (int(c.Frame.Width)*int(comp.HorizontalScale) + int(max_h) - 1) / int(max_h)
+	height := (int(c.Frame.Height)*int(comp.VerticalScale) + int(max_v) - 1) / int(max_v)
+	return (width + 7) / 8, (height + 7) / 8
+}
+
+// Allocate block grids for all components of frame.
+func newCoefficients(frame *FrameHeader) *Coefficients {
+
+	c := &Coefficients{Frame: frame, Components: make([]ComponentCoefficients, len(frame.Components))}
+
+	// A single component is always coded as non-interleaved, sampling factors are irrelevant.
+	if len(frame.Components) == 1 {
+		frame.Components[0].HorizontalScale = 1
+		frame.Components[0].VerticalScale = 1
+	}
+
+	mcus_wide, mcus_high := c.McuCount()
+	for i, comp := range frame.Components {
+		blocks_wide := mcus_wide * int(comp.HorizontalScale)
+		blocks_high := mcus_high * int(comp.VerticalScale)
+		c.Components[i] = ComponentCoefficients{
+			BlocksWide: blocks_wide,
+			BlocksHigh: blocks_high,
+			Blocks:     make([][64]int16, blocks_wide*blocks_high),
+		}
+	}
+
+	return c
+}
+
+// Visit every block coded in scan of given components, in coding order.
+//
+// `mcu` is the index of MCU the block belongs to, and `scan_index` is the index of component in the scan.
+func (c *Coefficients) walkScan(components []int, visit func(mcu int, scan_index int, block *[64]int16) error) error {
+
+	// Non-interleaved scan, each MCU is one block.
+	if len(components) == 1 {
+		comp := &c.Components[components[0]]
+		blocks_wide, blocks_high := c.componentExtent(components[0])
+		for y := 0; y < blocks_high; y++ {
+			for x := 0; x < blocks_wide; x++ {
+				err := visit(y*blocks_wide+x, 0, &comp.Blocks[y*comp.BlocksWide+x])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// Interleaved scan.
+	mcus_wide, mcus_high := c.McuCount()
+	for my := 0; my < mcus_high; my++ {
+		for mx := 0; mx < mcus_wide; mx++ {
+			for scan_index, index := range components {
+				comp := &c.Components[index]
+				h, v := int(c.Frame.Components[index].HorizontalScale), int(c.Frame.Components[index].VerticalScale)
+				for by := 0; by < v; by++ {
+					for bx := 0; bx < h; bx++ {
+						block := &comp.Blocks[(my*v+by)*comp.BlocksWide+mx*h+bx]
+						err := visit(my*mcus_wide+mx, scan_index, block)
+						if err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Find frame component index of each scan component.
+func (c *Coefficients) scanComponents(scan *ScanHeader) ([]int, error) {
+	ret := make([]int, len(scan.Components))
+	for i, scan_comp := range scan.Components {
+		ret[i] = slices.IndexFunc(c.Frame.Components, func(comp FrameComponent) bool {
+			return comp.Id == scan_comp.Id
+		})
+		if ret[i] < 0 {
+			return nil, ErrInvalidScanHeader
+		}
+	}
+	return ret, nil
+}
+
+// Decode one sequential scan into coefficients.
+//
+// Returns number of MCUs decoded completely, blocks of the incomplete MCU are reset to zero on error.
+func (c *Coefficients) decodeScan(scan *ScanHeader, data []byte, dc_tables *[4]*huffDecoder, ac_tables *[4]*huffDecoder, restart_interval int) (int, error) {
+
+	if scan.SpectralStart != 0 || scan.SpectralEnd != 63 || scan.ApproxHigh != 0 || scan.ApproxLow != 0 {
+		return 0, ErrUnsupportedCoding
+	}
+
+	components, err := c.scanComponents(scan)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, scan_comp := range scan.Components {
+		if scan_comp.DCTableId > 3 || scan_comp.ACTableId > 3 || dc_tables[scan_comp.DCTableId] == nil || ac_tables[scan_comp.ACTableId] == nil {
+			return 0, ErrMissingTable
+		}
+	}
+
+	br := &bitReader{data: data}
+	predictors := make([]int32, len(components))
+	last_mcu, restart_count := 0, 0
+
+	err = c.walkScan(components, func(mcu int, scan_index int, block *[64]int16) error {
+
+		// Restart marker between MCUs, predictors are reset.
+		if mcu != last_mcu {
+			last_mcu = mcu
+			if restart_interval > 0 && mcu%restart_interval == 0 {
+				if err := br.restart(restart_count); err != nil {
+					return err
+				}
+				restart_count++
+				clear(predictors)
+			}
+		}
+
+		scan_comp := scan.Components[scan_index]
+		return decodeBlock(br, dc_tables[scan_comp.DCTableId], ac_tables[scan_comp.ACTableId], &predictors[scan_index], block)
+	})
+	if err != nil {
+		// Reset blocks of the incomplete MCU.
+		c.walkScan(components, func(mcu int, scan_index int, block *[64]int16) error {
+			if mcu >= last_mcu {
+				*block = [64]int16{}
+			}
+			return nil
+		})
+		return last_mcu, err
+	}
+
+	return last_mcu + 1, nil
+}
+
+// Decode one block of sequential scan (ITU T.81 Annex F.2.2).
+func decodeBlock(br *bitReader, dc *huffDecoder, ac *huffDecoder, predictor *int32, block *[64]int16) error {
+
+	size, err := br.decodeHuff(dc)
+	if err != nil {
+		return err
+	}
+	if size > 11 {
+		return ErrInvalidHuffCode
+	}
+	diff, err := br.receiveExtend(size)
+	if err != nil {
+		return err
+	}
+	*predictor += diff
+	block[0] = int16(*predictor)
+
+	for k := 1; k < 64; k++ {
+		symbol, err := br.decodeHuff(ac)
+		if err != nil {
+			return err
+		}
+		run, size := int(symbol>>4), symbol&0x0F
+		if size == 0 {
+			if run != 15 { // End of block.
+				break
+			}
+			k += 15 // Zero run length, 16 zeros.
+			continue
+		}
+
+		k += run
+		if k > 63 || size > 10 {
+			return ErrInvalidHuffCode
+		}
+		value, err := br.receiveExtend(size)
+		if err != nil {
+			return err
+		}
+		block[ZigzagToNatural[k]] = int16(value)
+	}
+
+	return nil
+}
+
+// Entropy encoder of sequential scans.
+//
+// With `count` set, symbol frequencies are collected instead of writing bits.
+type scanEncoder struct {
+	count     bool
+	frequency [2][2][256]int     // Class, table, symbol.
+	encoders  [2][2]*huffEncoder // Class, table.
+	writer    bitWriter
+}
+
+// Emit one symbol followed by `size` bits of value.
+func (e *scanEncoder) emit(class uint8, table int, symbol uint8, value int32, size uint8) {
+	if e.count {
+		e.frequency[class][table][symbol]++
+		return
+	}
+	enc := e.encoders[class][table]
+	e.writer.writeBits(uint32(enc.code[symbol]), uint(enc.length[symbol]))
+	if size > 0 {
+		if value < 0 {
+			value-- // Negative values are coded as one's complement.
+		}
+		e.writer.writeBits(uint32(value), uint(size))
+	}
+}
+
+// Encode one block of sequential scan.
+func (e *scanEncoder) encodeBlock(block *[64]int16, table int, predictor *int32) {
+
+	diff := int32(block[0]) - *predictor
+	*predictor = int32(block[0])
+	size := bitSize(diff)
+	e.emit(HuffClassDC, table, size, diff, size)
+
+	run := 0
+	for k := 1; k < 64; k++ {
+		value := int32(block[ZigzagToNatural[k]])
+		if value == 0 {
+			run++
+			continue
+		}
+		for run > 15 { // Zero run length.
+			e.emit(HuffClassAC, table, 0xF0, 0, 0)
+			run -= 16
+		}
+		size := bitSize(value)
+		e.emit(HuffClassAC, table, uint8(run<<4)|size, value, size)
+		run = 0
+	}
+	if run > 0 { // End of block.
+		e.emit(HuffClassAC, table, 0x00, 0, 0)
+	}
+}
+
+// Encode scan of given components, first component uses table 0, others use table 1.
+func (e *scanEncoder) encodeScan(c *Coefficients, components []int) {
+
+	predictors := make([]int32, len(components))
+	c.walkScan(components, func(mcu int, scan_index int, block *[64]int16) error {
+		e.encodeBlock(block, min(components[scan_index], 1), &predictors[scan_index])
+		return nil
+	})
+	e.writer.flush()
+}
+
+// Read quantized DCT coefficients of image.
+//
+// Only sequential Huffman coded 8-bit images (SOF0, SOF1) are supported.
+func (im *JpegImage) ReadCoefficients() (*Coefficients, error) {
+	c, _, err := im.readCoefficients(false)
+	return c, err
+}
+
+// Check if frame can be read as coefficients.
+func isSupportedFrame(frame *FrameHeader) bool {
+	return (frame.Marker == jpegSOF0 || frame.Marker == jpegSOF1) && frame.Precision == 8 && frame.Height != 0
+}
+
+// Read quantized DCT coefficients of image.
+//
+// With `partial` set, entropy-coded data ending early in the last scan is not an error, undecoded MCUs are left zero.
+// Returns number of MCUs decoded in the last scan.
+func (im *JpegImage) readCoefficients(partial bool) (*Coefficients, int, error) {
+
+	frame, err := im.FrameHeader()
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isSupportedFrame(frame) {
+		return nil, 0, ErrUnsupportedCoding
+	}
+
+	quant_tables, err := im.QuantTables()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, comp := range frame.Components {
+		if _, ok := quant_tables[comp.QuantTableId]; !ok {
+			return nil, 0, ErrMissingTable
+		}
+	}
+
+	c := newCoefficients(frame)
+	c.QuantTables = quant_tables
+
+	var dc_tables, ac_tables [4]*huffDecoder
+	restart_interval, decoded := 0, 0
+
+	for i, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok {
+			continue
+		}
+
+		switch seg.SegmentType {
+		case jpegDHT:
+			tables, err := ParseHuffTables(seg)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, table := range tables {
+				dec, err := newHuffDecoder(&table)
+				if err != nil {
+					return nil, 0, err
+				}
+				if table.Class == HuffClassDC {
+					dc_tables[table.Id] = dec
+				} else {
+					ac_tables[table.Id] = dec
+				}
+			}
+
+		case jpegDRI:
+			interval, err := ParseRestartInterval(seg)
+			if err != nil {
+				return nil, 0, err
+			}
+			restart_interval = int(interval)
+
+		case jpegSOS:
+			scan, err := ParseScanHeader(seg)
+			if err != nil {
+				return nil, 0, err
+			}
+			if i+1 >= len(im.Segments) {
+				return nil, 0, ErrMissingScanData
+			}
+			ecs, ok := im.Segments[i+1].(*JpegEcsSegment)
+			if !ok || ecs.Data == nil {
+				return nil, 0, ErrMissingScanData
+			}
+			decoded, err = c.decodeScan(scan, *ecs.Data, &dc_tables, &ac_tables, restart_interval)
+			if err != nil {
+				if partial && i+2 >= len(im.Segments) && (err == ErrTruncatedScan || err == ErrInvalidHuffCode || err == ErrMissingRestart) {
+					return c, decoded, nil
+				}
+				return nil, 0, err
+			}
+		}
+	}
+
+	return c, decoded, nil
+}
+
+// Replace entropy-coded data of image with given coefficients.
+//
+// Coefficients are coded with optimal Huffman tables in as few scans as possible, without restart markers.
+// Segments before the first scan are kept, except Huffman tables, restart interval, quantization tables and frame header,
+// which are rewritten. Segments between scans are dropped, and EOI with any trailing data is kept.
+func (im *JpegImage) WriteCoefficients(c *Coefficients) error {
+
+	// Arrange scans, interleave all components if the MCU is small enough.
+	scans := make([][]int, 0)
+	blocks_per_mcu := 0
+	for _, comp := range c.Frame.Components {
+		blocks_per_mcu += int(comp.HorizontalScale) * int(comp.VerticalScale)
+	}
+	if len(c.Components) == 1 || (len(c.Components) <= 4 && blocks_per_mcu <= 10) {
+		all := make([]int, len(c.Components))
+		for i := range all {
+			all[i] = i
+		}
+		scans = append(scans, all)
+	} else {
+		for i := range c.Components {
+			scans = append(scans, []int{i})
+		}
+	}
+
+	// Collect symbol frequencies, and build optimal tables.
+	counter := &scanEncoder{count: true}
+	for _, components := range scans {
+		counter.encodeScan(c, components)
+	}
+
+	writer := new(scanEncoder)
+	huff_tables := make([]HuffTable, 0, 4)
+	for class := uint8(0); class < 2; class++ {
+		for table := 0; table < min(len(c.Components), 2); table++ {
+			frequency := &counter.frequency[class][table]
+			if !slices.ContainsFunc(frequency[:], func(f int) bool { return f > 0 }) {
+				frequency[0] = 1 // Table without symbol, keep it valid.
+			}
+			spec := buildOptimalHuffTable(class, uint8(table), frequency)
+			writer.encoders[class][table] = newHuffEncoder(&spec)
+			huff_tables = append(huff_tables, spec)
+		}
+	}
+
+	// Encode scans.
+	scan_segments := make([]JpegSegment, 0, len(scans)*2)
+	for _, components := range scans {
+		writer.writer = bitWriter{}
+		writer.encodeScan(c, components)
+
+		header := &ScanHeader{SpectralEnd: 63}
+		for _, index := range components {
+			table := uint8(min(index, 1))
+			header.Components = append(header.Components, ScanComponent{Id: c.Frame.Components[index].Id, DCTableId: table, ACTableId: table})
+		}
+		ecs_data := writer.writer.data
+		scan_segments = append(scan_segments, NewGeneralSegment(jpegSOS, header.Bytes()), &JpegEcsSegment{Data: &ecs_data})
+	}
+
+	// Quantization tables in ascending ID order.
+	quant_tables := make([]QuantTable, 0, len(c.QuantTables))
+	for _, table := range c.QuantTables {
+		quant_tables = append(quant_tables, table)
+	}
+	sort.Slice(quant_tables, func(a, b int) bool { return quant_tables[a].Id < quant_tables[b].Id })
+
+	// Rebuild segment list.
+	segments := make([]JpegSegment, 0, len(im.Segments))
+	quant_written := false
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok {
+			continue
+		}
+		if seg.SegmentType == jpegSOS || seg.SegmentType == jpegEOI {
+			break
+		}
+
+		switch {
+		case seg.SegmentType == jpegDHT, seg.SegmentType == jpegDRI:
+			continue
+		case seg.SegmentType == jpegDQT:
+			if !quant_written {
+				segments = append(segments, NewGeneralSegment(jpegDQT, EncodeQuantTables(quant_tables)))
+				quant_written = true
+			}
+		case isFrameMarker(seg.SegmentType):
+			if !quant_written {
+				segments = append(segments, NewGeneralSegment(jpegDQT, EncodeQuantTables(quant_tables)))
+				quant_written = true
+			}
+			segments = append(segments, NewGeneralSegment(seg.SegmentType, c.Frame.Bytes()))
+			segments = append(segments, NewGeneralSegment(jpegDHT, EncodeHuffTables(huff_tables)))
+		default:
+			segments = append(segments, seg)
+		}
+	}
+	segments = append(segments, scan_segments...)
+
+	// Keep EOI and trailing data.
+	eoi_index := slices.IndexFunc(im.Segments, func(elem JpegSegment) bool {
+		seg, ok := elem.(*JpegGeneralSegment)
+		return ok && seg.SegmentType == jpegEOI
+	})
+	if eoi_index < 0 {
+		segments = append(segments, NewGeneralSegment(jpegEOI, nil))
+	} else {
+		segments = append(segments, im.Segments[eoi_index:]...)
+	}
+
+	im.Segments = segments
+	return nil
+}
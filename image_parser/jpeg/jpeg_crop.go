@@ -0,0 +1,65 @@
+package jpeg_parser
+
+import (
+	"errors"
+	"image"
+)
+
+var (
+	ErrInvalidCropRect = errors.New("crop rectangle is empty or outside of the image")
+)
+
+// Crop coefficients to rectangle, snapped to the MCU grid.
+//
+// The top-left corner is moved up and left to the nearest MCU boundary, the bottom-right corner is kept,
+// since partial MCUs are allowed at the right and bottom edges. Returns the actual cropped rectangle.
+func (c *Coefficients) Crop(rect image.Rectangle) (image.Rectangle, error) {
+
+	bounds := image.Rect(0, 0, int(c.Frame.Width), int(c.Frame.Height))
+	if rect.Empty() || !rect.In(bounds) {
+		return image.Rectangle{}, ErrInvalidCropRect
+	}
+
+	// Snap top-left corner to MCU grid.
+	mcu_w, mcu_h := c.McuSize()
+	rect.Min.X -= rect.Min.X % mcu_w
+	rect.Min.Y -= rect.Min.Y % mcu_h
+
+	mcu_x, mcu_y := rect.Min.X/mcu_w, rect.Min.Y/mcu_h
+	c.Frame.Width, c.Frame.Height = uint16(rect.Dx()), uint16(rect.Dy())
+	mcus_wide, mcus_high := c.McuCount()
+
+	for i := range c.Components {
+		comp := &c.Components[i]
+		h, v := int(c.Frame.Components[i].HorizontalScale), int(c.Frame.Components[i].VerticalScale)
+		blocks_wide, blocks_high := mcus_wide*h, mcus_high*v
+		offset_x, offset_y := mcu_x*h, mcu_y*v
+
+		blocks := make([][64]int16, 0, blocks_wide*blocks_high)
+		for y := offset_y; y < offset_y+blocks_high; y++ {
+			row := comp.Blocks[y*comp.BlocksWide : (y+1)*comp.BlocksWide]
+			blocks = append(blocks, row[offset_x:offset_x+blocks_wide]...)
+		}
+		comp.Blocks, comp.BlocksWide, comp.BlocksHigh = blocks, blocks_wide, blocks_high
+	}
+
+	return rect, nil
+}
+
+// Crop image losslessly to rectangle snapped to the MCU grid, without decoding pixels.
+//
+// Only baseline images are supported, see `ReadCoefficients`. Returns the actual cropped rectangle.
+func (im *JpegImage) Crop(rect image.Rectangle) (image.Rectangle, error) {
+
+	c, err := im.ReadCoefficients()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	rect, err = c.Crop(rect)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return rect, im.WriteCoefficients(c)
+}
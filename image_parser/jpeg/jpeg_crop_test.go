@@ -0,0 +1,52 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestJpegLosslessCrop(t *testing.T) {
+
+	raw_bytes := createTestJpeg(t, 64, 48)
+	src, _ := jpeg.Decode(bytes.NewReader(raw_bytes))
+
+	img := parseTestJpeg(t, raw_bytes)
+	rect, err := img.Crop(image.Rect(20, 18, 50, 40))
+	if err != nil {
+		t.Fatalf("Failed to crop image: %v", err)
+	}
+	if rect != image.Rect(16, 16, 50, 40) {
+		t.Errorf("Expected crop snapped to (16,16)-(50,40), got %v", rect)
+	}
+
+	buf := new(bytes.Buffer)
+	img.WriteTo(buf)
+	out, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to decode cropped image: %v", err)
+	}
+	if out.Bounds().Dx() != rect.Dx() || out.Bounds().Dy() != rect.Dy() {
+		t.Fatalf("Expected %dx%d, got %v", rect.Dx(), rect.Dy(), out.Bounds())
+	}
+
+	max_diff := 0
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			r1, g1, b1, _ := out.At(x, y).RGBA()
+			r2, g2, b2, _ := src.At(rect.Min.X+x, rect.Min.Y+y).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				max_diff = max(max_diff, d, -d)
+			}
+		}
+	}
+	if max_diff > 2 {
+		t.Errorf("Max pixel difference %d", max_diff)
+	}
+
+	if _, err := parseTestJpeg(t, raw_bytes).Crop(image.Rect(60, 0, 70, 10)); err != ErrInvalidCropRect {
+		t.Errorf("Expected ErrInvalidCropRect, got %v", err)
+	}
+}
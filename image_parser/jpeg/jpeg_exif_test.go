@@ -0,0 +1,69 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image/jpeg"
+	"testing"
+)
+
+func TestJpegEmbedExif(t *testing.T) {
+
+	img := parseTestJpeg(t, createTestJpeg(t, 16, 16))
+
+	exif_data, err := img.ExtractExif()
+	if err != nil || exif_data != nil {
+		t.Fatalf("Expected no EXIF data, got: %v, %v", exif_data, err)
+	}
+
+	// XMP segment should be kept.
+	xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>")
+	err = img.AppendAppSegment(1, xmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Embed twice, the second one should replace the first one.
+	err = img.EmbedExif([]byte("MM\x00\x2A\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00"))
+	if err != nil {
+		t.Fatalf("Failed to embed EXIF: %v", err)
+	}
+	test_exif := []byte("II\x2A\x00\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	err = img.EmbedExif(test_exif)
+	if err != nil {
+		t.Fatalf("Failed to embed EXIF: %v", err)
+	}
+
+	exif_data, err = img.ExtractExif()
+	if err != nil {
+		t.Fatalf("Failed to extract EXIF: %v", err)
+	}
+	if !bytes.Equal(exif_data, test_exif) {
+		t.Errorf("EXIF mismatch: %v", exif_data)
+	}
+
+	// Check APP1 segments order.
+	buf := bytes.NewBuffer([]byte{})
+	_, err = img.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	exif_pos := bytes.Index(buf.Bytes(), []byte("Exif\x00\x00"))
+	xmp_pos := bytes.Index(buf.Bytes(), xmp)
+	if exif_pos == -1 || xmp_pos == -1 || exif_pos > xmp_pos {
+		t.Errorf("Expected EXIF before XMP, got positions %d, %d", exif_pos, xmp_pos)
+	}
+	if bytes.Count(buf.Bytes(), []byte("Exif\x00\x00")) != 1 {
+		t.Errorf("Expected exactly one EXIF segment")
+	}
+
+	_, err = jpeg.Decode(buf)
+	if err != nil {
+		t.Errorf("Failed to decode output image: %v", err)
+	}
+
+	// Oversized EXIF.
+	err = img.EmbedExif(make([]byte, 0xFFFF))
+	if err == nil {
+		t.Errorf("Expected error for oversized EXIF")
+	}
+}
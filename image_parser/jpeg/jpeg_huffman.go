@@ -0,0 +1,313 @@
+package jpeg_parser
+
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrInvalidHuffCode = errors.New("invalid huffman code in entropy-coded data")
+	ErrTruncatedScan   = errors.New("entropy-coded data ended unexpectedly")
+	ErrMissingRestart  = errors.New("expected restart marker not found")
+)
+
+// Huffman decoding table, built from table specification (ITU T.81 Annex F.2.2.3).
+type huffDecoder struct {
+	maxcode [17]int32 // Largest code of each length, -1 if no code.
+	mincode [17]int32 // Smallest code of each length.
+	valptr  [17]int   // Index of first symbol of each length.
+	symbols []uint8
+}
+
+// Build decoding table from table specification.
+func newHuffDecoder(table *HuffTable) (*huffDecoder, error) {
+
+	dec := &huffDecoder{symbols: table.Symbols}
+
+	code, k := int32(0), 0
+	for l := 1; l <= 16; l++ {
+		count := int(table.Lengths[l-1])
+		dec.valptr[l] = k
+		dec.mincode[l] = code
+		code += int32(count)
+		k += count
+		if count == 0 {
+			dec.maxcode[l] = -1
+		} else {
+			dec.maxcode[l] = code - 1
+		}
+		if code > 1<<l { // Too many codes for the length.
+			return nil, ErrInvalidHuffTable
+		}
+		code <<= 1
+	}
+	if k > len(table.Symbols) {
+		return nil, ErrInvalidHuffTable
+	}
+
+	return dec, nil
+}
+
+// Huffman encoding table, code and length of every symbol.
+type huffEncoder struct {
+	code   [256]uint16
+	length [256]uint8
+}
+
+// Build encoding table from table specification.
+func newHuffEncoder(table *HuffTable) *huffEncoder {
+
+	enc := new(huffEncoder)
+
+	code, k := uint16(0), 0
+	for l := 1; l <= 16; l++ {
+		for i := 0; i < int(table.Lengths[l-1]); i++ {
+			symbol := table.Symbols[k]
+			enc.code[symbol] = code
+			enc.length[symbol] = uint8(l)
+			code++
+			k++
+		}
+		code <<= 1
+	}
+
+	return enc
+}
+
+// Build optimal Huffman table from symbol frequencies (ITU T.81 Annex K.2).
+func buildOptimalHuffTable(class uint8, id uint8, freq *[256]int) HuffTable {
+
+	// Reserve one code point, so no code consists of all 1 bits.
+	var frequency [257]int
+	copy(frequency[:], freq[:])
+	frequency[256] = 1
+
+	var code_size [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		// Find two least frequent symbols, ties are broken by larger symbol value.
+		c1, c2 := -1, -1
+		for i, f := range frequency {
+			if f == 0 {
+				continue
+			}
+			if c1 < 0 || f <= frequency[c1] {
+				c1 = i
+			}
+		}
+		for i, f := range frequency {
+			if f == 0 || i == c1 {
+				continue
+			}
+			if c2 < 0 || f <= frequency[c2] {
+				c2 = i
+			}
+		}
+		if c2 < 0 { // Only one tree left.
+			break
+		}
+
+		// Merge two trees.
+		frequency[c1] += frequency[c2]
+		frequency[c2] = 0
+
+		code_size[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			code_size[c1]++
+		}
+		others[c1] = c2
+
+		code_size[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			code_size[c2]++
+		}
+	}
+
+	// Count codes of each length.
+	bits := make([]int, 258)
+	max_length := 0
+	for _, size := range code_size {
+		if size > 0 {
+			bits[size]++
+			max_length = max(max_length, size)
+		}
+	}
+
+	// Limit code length to 16 bits.
+	for i := max_length; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+
+	// Remove reserved code point from the longest length.
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+	bits[i]--
+
+	table := HuffTable{Class: class, Id: id}
+	for l := 1; l <= 16; l++ {
+		table.Lengths[l-1] = uint8(bits[l])
+	}
+
+	// Symbols are ordered by code length, then by value.
+	symbols := make([]int, 0)
+	for symbol := 0; symbol < 256; symbol++ {
+		if code_size[symbol] > 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.SliceStable(symbols, func(a, b int) bool {
+		return code_size[symbols[a]] < code_size[symbols[b]]
+	})
+	table.Symbols = make([]uint8, len(symbols))
+	for k, symbol := range symbols {
+		table.Symbols[k] = uint8(symbol)
+	}
+
+	return table
+}
+
+// Bit reader over entropy-coded data, stuffed bytes are removed.
+type bitReader struct {
+	data []byte
+	pos  int
+	acc  uint32
+	bits uint
+}
+
+// Read one byte of entropy-coded data into accumulator.
+func (br *bitReader) fill() error {
+
+	if br.pos >= len(br.data) {
+		return ErrTruncatedScan
+	}
+
+	b := br.data[br.pos]
+	if b == '\xFF' {
+		if br.pos+1 >= len(br.data) || br.data[br.pos+1] != jpegNUL {
+			return ErrTruncatedScan // Reached a marker.
+		}
+		br.pos += 2
+	} else {
+		br.pos++
+	}
+
+	br.acc = br.acc<<8 | uint32(b)
+	br.bits += 8
+	return nil
+}
+
+// Read n (0~16) bits.
+func (br *bitReader) readBits(n uint) (uint32, error) {
+	for br.bits < n {
+		if err := br.fill(); err != nil {
+			return 0, err
+		}
+	}
+	br.bits -= n
+	return (br.acc >> br.bits) & (1<<n - 1), nil
+}
+
+// Decode one Huffman coded symbol.
+func (br *bitReader) decodeHuff(dec *huffDecoder) (uint8, error) {
+	code := int32(0)
+	for l := 1; l <= 16; l++ {
+		bit, err := br.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		code = code<<1 | int32(bit)
+		if code <= dec.maxcode[l] {
+			return dec.symbols[dec.valptr[l]+int(code-dec.mincode[l])], nil
+		}
+	}
+	return 0, ErrInvalidHuffCode
+}
+
+// Read value of given bit size, and extend it to signed value (ITU T.81 Annex F.2.2.1).
+func (br *bitReader) receiveExtend(size uint8) (int32, error) {
+	if size == 0 {
+		return 0, nil
+	}
+	v, err := br.readBits(uint(size))
+	if err != nil {
+		return 0, err
+	}
+	if v < 1<<(size-1) {
+		return int32(v) - (1 << size) + 1, nil
+	}
+	return int32(v), nil
+}
+
+// Discard remaining bits, and consume the expected restart marker.
+func (br *bitReader) restart(index int) error {
+	br.acc, br.bits = 0, 0
+
+	// Skip fill bytes before marker.
+	for br.pos+1 < len(br.data) && br.data[br.pos] == '\xFF' && br.data[br.pos+1] == '\xFF' {
+		br.pos++
+	}
+	if br.pos+1 >= len(br.data) || br.data[br.pos] != '\xFF' || br.data[br.pos+1] != jpegRST0+byte(index%8) {
+		return ErrMissingRestart
+	}
+	br.pos += 2
+	return nil
+}
+
+// Bit writer producing entropy-coded data, with byte stuffing.
+type bitWriter struct {
+	data []byte
+	acc  uint32
+	bits uint
+}
+
+// Write n (0~16) bits.
+func (bw *bitWriter) writeBits(v uint32, n uint) {
+	bw.acc = bw.acc<<n | (v & (1<<n - 1))
+	bw.bits += n
+	for bw.bits >= 8 {
+		bw.bits -= 8
+		b := byte(bw.acc >> bw.bits)
+		bw.data = append(bw.data, b)
+		if b == '\xFF' {
+			bw.data = append(bw.data, jpegNUL)
+		}
+	}
+}
+
+// Pad remaining bits with 1 bits.
+func (bw *bitWriter) flush() {
+	if bw.bits > 0 {
+		bw.writeBits(0x7F, 8-bw.bits)
+	}
+	bw.acc = 0
+}
+
+// Get bit size category of value.
+func bitSize(v int32) uint8 {
+	if v < 0 {
+		v = -v
+	}
+	size := uint8(0)
+	for v > 0 {
+		size++
+		v >>= 1
+	}
+	return size
+}
@@ -0,0 +1,118 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+// Create a small JPEG image in memory.
+func createTestJpeg(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 7), G: uint8(y * 5), B: uint8((x + y) * 3), A: 255})
+		}
+	}
+	buf := bytes.NewBuffer([]byte{})
+	err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90})
+	if err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// Parse JPEG from bytes.
+func parseTestJpeg(t *testing.T, raw_bytes []byte) *JpegImage {
+	img := new(JpegImage)
+	_, err := img.ReadFrom(bytes.NewReader(raw_bytes))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+	return img
+}
+
+// Collect data of all APP2 segments.
+func app2Segments(img *JpegImage) [][]byte {
+	ret := make([][]byte, 0)
+	for _, x := range img.Segments {
+		if seg, ok := x.(*JpegGeneralSegment); ok && seg.SegmentType == '\xE2' {
+			ret = append(ret, *seg.Data)
+		}
+	}
+	return ret
+}
+
+func TestJpegEmbedLargeIcc(t *testing.T) {
+
+	img := parseTestJpeg(t, createTestJpeg(t, 32, 32))
+
+	// Non-ICC APP2 segment should survive.
+	err := img.AppendAppSegment(2, []byte("FPXR\x00test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Profile needs 3 chunks.
+	profile := make([]byte, 150000)
+	for i := range profile {
+		profile[i] = byte(i)
+	}
+
+	// Embed twice, the second profile should replace the first one.
+	err = img.EmbedIccProfile([]byte("placeholder"))
+	if err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+	err = img.EmbedIccProfile(profile)
+	if err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+
+	segments := app2Segments(img)
+	if len(segments) != 4 {
+		t.Fatalf("Expected 4 APP2 segments, got %d", len(segments))
+	}
+	if !bytes.HasPrefix(segments[0], []byte("FPXR")) {
+		t.Errorf("Expected FPXR segment to be kept")
+	}
+
+	// Reassemble chunks.
+	reassembled := []byte{}
+	for i, data := range segments[1:] {
+		if !bytes.HasPrefix(data, []byte("ICC_PROFILE\x00")) {
+			t.Fatalf("Expected ICC chunk, got: %q", data[:12])
+		}
+		if data[12] != byte(i+1) || data[13] != 3 {
+			t.Errorf("Invalid chunk sequence %d/%d", data[12], data[13])
+		}
+		reassembled = append(reassembled, data[14:]...)
+	}
+	if !bytes.Equal(reassembled, profile) {
+		t.Errorf("Reassembled profile mismatch")
+	}
+
+	// Output should still be a valid JPEG.
+	buf := bytes.NewBuffer([]byte{})
+	_, err = img.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	_, err = jpeg.Decode(buf)
+	if err != nil {
+		t.Errorf("Failed to decode output image: %v", err)
+	}
+}
+
+func TestJpegEmbedOversizedIcc(t *testing.T) {
+
+	img := parseTestJpeg(t, createTestJpeg(t, 8, 8))
+
+	err := img.EmbedIccProfile(make([]byte, 65519*255+1))
+	if err != ErrIccProfileTooLarge {
+		t.Errorf("Expected ErrIccProfileTooLarge, got: %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 var (
 	ErrInvalidJpegHeader  = errors.New("invalid jpeg header")
 	ErrInvalidSegmentType = errors.New("invalid jpeg segment type")
+	ErrSegmentTooLarge    = errors.New("jpeg segment exceeds maximum length")
 )
 
 type JpegSegment interface {
@@ -184,6 +185,11 @@ func (seg JpegGeneralSegment) WriteTo(wt io.Writer) (int64, error) {
 
 	var total_written_bytes int64 = 0
 
+	// Segment length is 16-bit.
+	if seg.Length > 0xFFFF {
+		return total_written_bytes, ErrSegmentTooLarge
+	}
+
 	// Write signature high byte.
 	n, err := wt.Write([]byte{'\xFF'})
 	total_written_bytes += int64(n)
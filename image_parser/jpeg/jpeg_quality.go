@@ -0,0 +1,106 @@
+package jpeg_parser
+
+// IJG standard luminance quantization table (ITU T.81 Annex K.1), in natural order.
+var standardLuminanceTable = [64]uint16{
+	16, 11, 10, 16, 24, 40, 51, 61,
+	12, 12, 14, 19, 26, 58, 60, 55,
+	14, 13, 16, 24, 40, 57, 69, 56,
+	14, 17, 22, 29, 51, 87, 80, 62,
+	18, 22, 37, 56, 68, 109, 103, 77,
+	24, 35, 55, 64, 81, 104, 113, 92,
+	49, 64, 78, 87, 103, 121, 120, 101,
+	72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+// IJG standard chrominance quantization table (ITU T.81 Annex K.1), in natural order.
+var standardChrominanceTable = [64]uint16{
+	17, 18, 24, 47, 99, 99, 99, 99,
+	18, 21, 26, 66, 99, 99, 99, 99,
+	24, 26, 56, 99, 99, 99, 99, 99,
+	47, 66, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+}
+
+// Estimated encoder quality of a JPEG image.
+type QualityEstimate struct {
+	Quality    int  // IJG quality (1~100) with the closest tables.
+	IsStandard bool // Tables exactly match IJG tables scaled by `Quality`.
+}
+
+// Scale standard table by IJG quality, the same way as libjpeg with baseline clamping.
+func scaleQuantTable(base *[64]uint16, quality int) [64]uint16 {
+
+	quality = min(max(quality, 1), 100)
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+
+	var ret [64]uint16
+	for i, v := range base {
+		ret[i] = uint16(min(max((int(v)*scale+50)/100, 1), 255))
+	}
+	return ret
+}
+
+// Sum of absolute differences between two tables.
+func quantTableDistance(a *[64]uint16, b *[64]uint16) int {
+	distance := 0
+	for i := range a {
+		if a[i] > b[i] {
+			distance += int(a[i] - b[i])
+		} else {
+			distance += int(b[i] - a[i])
+		}
+	}
+	return distance
+}
+
+// Estimate quality from quantization tables.
+//
+// Table 0 is compared against the luminance table, and table 1 (if present) against the chrominance table.
+// The highest quality with the closest tables is reported, as qualities near 100 can produce identical tables.
+func EstimateQuality(tables map[uint8]QuantTable) (*QualityEstimate, error) {
+
+	luminance, ok := tables[0]
+	if !ok {
+		return nil, ErrSegmentNotFound
+	}
+	luminance_values := luminance.Natural()
+
+	var chrominance_values *[64]uint16
+	if chrominance, ok := tables[1]; ok {
+		values := chrominance.Natural()
+		chrominance_values = &values
+	}
+
+	best_quality, best_distance := 0, -1
+	for quality := 1; quality <= 100; quality++ {
+
+		expected := scaleQuantTable(&standardLuminanceTable, quality)
+		distance := quantTableDistance(&luminance_values, &expected)
+
+		if chrominance_values != nil {
+			expected = scaleQuantTable(&standardChrominanceTable, quality)
+			distance += quantTableDistance(chrominance_values, &expected)
+		}
+
+		if best_distance == -1 || distance <= best_distance {
+			best_quality, best_distance = quality, distance
+		}
+	}
+
+	return &QualityEstimate{Quality: best_quality, IsStandard: best_distance == 0}, nil
+}
+
+// Estimate encoder quality of image from its quantization tables.
+func (im *JpegImage) EstimateQuality() (*QualityEstimate, error) {
+	tables, err := im.QuantTables()
+	if err != nil {
+		return nil, err
+	}
+	return EstimateQuality(tables)
+}
@@ -0,0 +1,52 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestJpegEstimateQuality(t *testing.T) {
+
+	src := image.NewGray(image.Rect(0, 0, 8, 8))
+
+	for _, quality := range []int{10, 25, 50, 75, 90} {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, src, &jpeg.Options{Quality: quality}); err != nil {
+			t.Fatalf("Failed to encode test image: %v", err)
+		}
+
+		estimate, err := parseTestJpeg(t, buf.Bytes()).EstimateQuality()
+		if err != nil {
+			t.Fatalf("Failed to estimate quality: %v", err)
+		}
+		if estimate.Quality != quality || !estimate.IsStandard {
+			t.Errorf("Expected standard quality %d, got %+v", quality, estimate)
+		}
+	}
+}
+
+func TestJpegEstimateNonStandardQuality(t *testing.T) {
+
+	flat := QuantTable{Id: 0}
+	for i := range flat.Values {
+		flat.Values[i] = 8
+	}
+
+	estimate, err := EstimateQuality(map[uint8]QuantTable{0: flat})
+	if err != nil {
+		t.Fatalf("Failed to estimate quality: %v", err)
+	}
+	if estimate.IsStandard {
+		t.Errorf("Expected non-standard tables.")
+	}
+	if estimate.Quality < 80 || estimate.Quality > 95 {
+		t.Errorf("Expected quality around 85~90, got %d", estimate.Quality)
+	}
+
+	if _, err := EstimateQuality(map[uint8]QuantTable{}); err != ErrSegmentNotFound {
+		t.Errorf("Expected ErrSegmentNotFound, got %v", err)
+	}
+}
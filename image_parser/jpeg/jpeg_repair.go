@@ -0,0 +1,150 @@
+package jpeg_parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	ErrUnrepairable = errors.New("jpeg is truncated before any complete scan, can't be repaired")
+)
+
+// Report of truncated JPEG repair.
+type RepairReport struct {
+	Truncated    bool  // Input was truncated and has been repaired.
+	Offset       int64 // Offset where input ended.
+	Progressive  bool  // Image is not baseline, repaired by dropping the incomplete scan.
+	TotalMcus    int   // Number of MCUs in the truncated scan, baseline only.
+	SalvagedMcus int   // Number of MCUs decoded from the truncated scan, baseline only.
+	DroppedScans int   // Number of incomplete scans dropped.
+}
+
+// Read segments of possibly truncated JPEG, reading stops at EOI.
+//
+// Returns segments read so far, with the partial entropy-coded data as the last segment if truncated inside a scan.
+func readTruncatedJpeg(data []byte) ([]JpegSegment, bool, error) {
+
+	ret := make([]JpegSegment, 0)
+	sr := NewSegmentReader(bytes.NewReader(data))
+	seen_scan := false
+
+	for {
+		if sr.InData() {
+			// Entropy-coded data, keep partial data if truncated.
+			buf := bytes.NewBuffer([]byte{})
+			_, err := sr.CopyData(buf)
+			raw_data := buf.Bytes()
+			ret = append(ret, &JpegEcsSegment{Data: &raw_data})
+			if err == io.ErrUnexpectedEOF {
+				return ret, true, nil
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+
+		seg, err := sr.Next()
+		if err == io.EOF { // Ended between segments without EOI.
+			return ret, true, nil
+		}
+		if err == io.ErrUnexpectedEOF { // Truncated inside segment.
+			if !seen_scan {
+				return nil, true, ErrUnrepairable
+			}
+			return ret, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		ret = append(ret, seg)
+		switch seg.(*JpegGeneralSegment).SegmentType {
+		case jpegSOS:
+			seen_scan = true
+		case jpegEOI:
+			return ret, false, nil
+		}
+	}
+}
+
+// Repair truncated JPEG, best-effort.
+//
+// Baseline images are decoded to the last complete MCU, the missing MCUs are filled with zero coefficients (grey),
+// and the image is re-encoded losslessly with EOI appended.
+// Other images, e.g. progressive, are repaired by dropping the incomplete scan and appending EOI.
+// Images which are not truncated are returned as is.
+func Repair(data []byte) ([]byte, *RepairReport, error) {
+
+	report := &RepairReport{Offset: int64(len(data))}
+
+	segments, truncated, err := readTruncatedJpeg(data)
+	if err != nil {
+		return nil, report, err
+	}
+	if !truncated {
+		return data, report, nil
+	}
+	report.Truncated = true
+
+	img := &JpegImage{Segments: segments}
+	frame, err := img.FrameHeader()
+	if err != nil {
+		return nil, report, ErrUnrepairable
+	}
+
+	_, last_is_ecs := segments[len(segments)-1].(*JpegEcsSegment)
+
+	if isSupportedFrame(frame) && last_is_ecs {
+		// Decode what is left, and re-encode.
+		c, decoded, err := img.readCoefficients(true)
+		if err != nil {
+			return nil, report, err
+		}
+		report.SalvagedMcus = decoded
+
+		scan, err := ParseScanHeader(segments[len(segments)-2].(*JpegGeneralSegment))
+		if err != nil {
+			return nil, report, err
+		}
+		components, err := c.scanComponents(scan)
+		if err != nil {
+			return nil, report, err
+		}
+		c.walkScan(components, func(mcu int, scan_index int, block *[64]int16) error {
+			report.TotalMcus = mcu + 1
+			return nil
+		})
+
+		err = img.WriteCoefficients(c)
+		if err != nil {
+			return nil, report, err
+		}
+	} else {
+		// Drop incomplete scan and everything after the last complete scan.
+		report.Progressive = !isSupportedFrame(frame)
+		if last_is_ecs {
+			img.Segments = img.Segments[:len(img.Segments)-2]
+			report.DroppedScans = 1
+		}
+
+		has_scan := false
+		for _, elem := range img.Segments {
+			if seg, ok := elem.(*JpegGeneralSegment); ok && seg.SegmentType == jpegSOS {
+				has_scan = true
+			}
+		}
+		if !has_scan {
+			return nil, report, ErrUnrepairable
+		}
+		img.Segments = append(img.Segments, NewGeneralSegment(jpegEOI, nil))
+	}
+
+	buf := new(bytes.Buffer)
+	_, err = img.WriteTo(buf)
+	if err != nil {
+		return nil, report, err
+	}
+	return buf.Bytes(), report, nil
+}
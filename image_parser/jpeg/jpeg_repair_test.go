@@ -0,0 +1,108 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image/jpeg"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestJpegRepairBaseline(t *testing.T) {
+
+	raw_bytes := createTestJpeg(t, 64, 64)
+	src, _ := jpeg.Decode(bytes.NewReader(raw_bytes))
+
+	// Untouched if not truncated.
+	repaired, report, err := Repair(raw_bytes)
+	if err != nil || report.Truncated || !bytes.Equal(repaired, raw_bytes) {
+		t.Errorf("Expected image to be untouched, got %+v (%v)", report, err)
+	}
+
+	truncated := raw_bytes[:len(raw_bytes)*2/3]
+	if _, err := jpeg.Decode(bytes.NewReader(truncated)); err == nil {
+		t.Fatalf("Expected truncated image to fail decoding")
+	}
+
+	repaired, report, err = Repair(truncated)
+	if err != nil {
+		t.Fatalf("Failed to repair image: %v", err)
+	}
+	if !report.Truncated || report.Progressive || report.TotalMcus != 16 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if report.SalvagedMcus == 0 || report.SalvagedMcus >= report.TotalMcus {
+		t.Errorf("Expected partial salvage, got %d of %d MCUs", report.SalvagedMcus, report.TotalMcus)
+	}
+	if !Validate(repaired).Valid() {
+		t.Errorf("Expected repaired image to be valid: %v", Validate(repaired).Issues)
+	}
+
+	out, err := jpeg.Decode(bytes.NewReader(repaired))
+	if err != nil {
+		t.Fatalf("Failed to decode repaired image: %v", err)
+	}
+	if out.Bounds() != src.Bounds() {
+		t.Errorf("Expected %v, got %v", src.Bounds(), out.Bounds())
+	}
+
+	// First MCU row is salvaged.
+	for x := 0; x < 64; x++ {
+		r1, _, _, _ := out.At(x, 0).RGBA()
+		r2, _, _, _ := src.At(x, 0).RGBA()
+		if d := int(r1>>8) - int(r2>>8); d > 2 || d < -2 {
+			t.Fatalf("Pixel (%d, 0) differs by %d", x, d)
+		}
+	}
+
+	// Last MCU is grey.
+	r, g, b, _ := out.At(63, 63).RGBA()
+	if r>>8 < 120 || r>>8 > 136 || g>>8 < 120 || g>>8 > 136 || b>>8 < 120 || b>>8 > 136 {
+		t.Errorf("Expected grey pixel, got (%d, %d, %d)", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestJpegRepairDropScan(t *testing.T) {
+
+	raw_bytes := createTestJpeg(t, 32, 32)
+	img := parseTestJpeg(t, raw_bytes)
+
+	// Mark the frame as progressive, and repeat the scan.
+	scan_index := 0
+	for i, elem := range img.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if ok && seg.SegmentType == 0xC0 {
+			seg.SegmentType = 0xC2
+		}
+		if ok && seg.SegmentType == 0xDA {
+			scan_index = i
+		}
+	}
+	single := new(bytes.Buffer)
+	img.WriteTo(single)
+
+	img.Segments = append(img.Segments[:scan_index+2], img.Segments[scan_index:]...)
+	buf := new(bytes.Buffer)
+	img.WriteTo(buf)
+	stream := buf.Bytes()
+
+	repaired, report, err := Repair(stream[:len(stream)-20])
+	if err != nil {
+		t.Fatalf("Failed to repair image: %v", err)
+	}
+	if !report.Truncated || !report.Progressive || report.DroppedScans != 1 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+	if !Validate(repaired).Valid() {
+		t.Errorf("Expected repaired image to be valid: %v", Validate(repaired).Issues)
+	}
+
+	// Nothing left if the only scan is truncated.
+	if _, _, err = Repair(single.Bytes()[:single.Len()-20]); err != ErrUnrepairable {
+		t.Errorf("Expected ErrUnrepairable, got %v", err)
+	}
+
+	// Truncated before any scan.
+	if _, _, err = Repair(raw_bytes[:100]); err != ErrUnrepairable {
+		t.Errorf("Expected ErrUnrepairable, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package jpeg_parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	// Returned by visitor to stop walking without error.
+	ErrStopWalk = errors.New("stop walking jpeg segments")
+)
+
+// Stream states of segment reader.
+const (
+	streamMarker  = iota // Next item is a marker segment.
+	streamEcs            // Next item is entropy-coded data.
+	streamTrailer        // Next item is data after EOI.
+	streamDone           // Nothing left.
+)
+
+// Streaming JPEG segment reader.
+//
+// Segments are read one by one from a buffered reader, so memory usage doesn't depend on file size.
+// Entropy-coded data and trailing data can be copied to a writer with `CopyData` instead of being buffered.
+type SegmentReader struct {
+	reader *bufio.Reader
+	offset int64 // Number of bytes consumed.
+	state  int
+}
+
+// Create segment reader from reader.
+func NewSegmentReader(r io.Reader) *SegmentReader {
+	return &SegmentReader{reader: bufio.NewReader(r), state: streamMarker}
+}
+
+// Get number of bytes consumed from input.
+func (sr *SegmentReader) Offset() int64 {
+	return sr.offset
+}
+
+// Check if the next item is entropy-coded data (after SOS), or trailing data (after EOI).
+func (sr *SegmentReader) InData() bool {
+	return sr.state == streamEcs || sr.state == streamTrailer
+}
+
+// Read next segment.
+//
+// Marker segments are returned as `*JpegGeneralSegment`, entropy-coded data as `*JpegEcsSegment`,
+// and data after EOI as `*JpegRawSegment`. Returns `io.EOF` if there is nothing left.
+func (sr *SegmentReader) Next() (JpegSegment, error) {
+
+	switch sr.state {
+
+	case streamEcs, streamTrailer:
+		is_ecs := sr.state == streamEcs
+		buf := bytes.NewBuffer([]byte{})
+		_, err := sr.CopyData(buf)
+		if err != nil {
+			return nil, err
+		}
+		raw_data := buf.Bytes()
+		if is_ecs {
+			return &JpegEcsSegment{Data: &raw_data}, nil
+		}
+		return &JpegRawSegment{Data: &raw_data}, nil
+
+	case streamDone:
+		return nil, io.EOF
+
+	default:
+		seg := new(JpegGeneralSegment)
+		read_bytes, err := seg.ReadFrom(sr.reader)
+		sr.offset += read_bytes
+		if err != nil {
+			if err == io.EOF && read_bytes > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch seg.SegmentType {
+		case jpegSOS:
+			sr.state = streamEcs
+		case jpegEOI:
+			sr.state = streamTrailer
+			if _, err := sr.reader.Peek(1); err == io.EOF { // No trailing data.
+				sr.state = streamDone
+			}
+		}
+		return seg, nil
+	}
+}
+
+// Copy pending entropy-coded data or trailing data to writer, without buffering it.
+//
+// Use `io.Discard` to skip the data. Nothing is copied if the next item is a marker segment.
+func (sr *SegmentReader) CopyData(w io.Writer) (int64, error) {
+
+	switch sr.state {
+	case streamEcs:
+		n, err := sr.copyEcs(w)
+		sr.state = streamMarker
+		return n, err
+	case streamTrailer:
+		n, err := io.Copy(w, sr.reader)
+		sr.offset += n
+		sr.state = streamDone
+		return n, err
+	default:
+		return 0, nil
+	}
+}
+
+// Copy entropy-coded data until the next marker, the marker itself is left unread.
+func (sr *SegmentReader) copyEcs(w io.Writer) (int64, error) {
+
+	total_written := int64(0)
+
+	write := func(p []byte) error {
+		n, err := w.Write(p)
+		total_written += int64(n)
+		sr.offset += int64(n)
+		return err
+	}
+
+	for {
+		chunk, err := sr.reader.ReadSlice('\xFF')
+		switch err {
+		case nil:
+			sr.reader.UnreadByte() // Put 0xFF back, examine it with the following byte.
+			chunk = chunk[:len(chunk)-1]
+		case bufio.ErrBufferFull: // No 0xFF in buffer, the whole chunk is data.
+			if err := write(chunk); err != nil {
+				return total_written, err
+			}
+			continue
+		default:
+			if err := write(chunk); err != nil {
+				return total_written, err
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return total_written, err
+		}
+
+		if err := write(chunk); err != nil {
+			return total_written, err
+		}
+
+		marker, err := sr.reader.Peek(2)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return total_written, err
+		}
+
+		switch marker[1] {
+		case jpegNUL, jpegRST0, jpegRST1, jpegRST2, jpegRST3, jpegRST4, jpegRST5, jpegRST6, jpegRST7:
+			// Stuffed byte or restart marker, part of entropy-coded data.
+			if err := write(marker); err != nil {
+				return total_written, err
+			}
+			sr.reader.Discard(2)
+		case '\xFF':
+			// Fill byte, the following 0xFF is examined in next round.
+			if err := write(marker[:1]); err != nil {
+				return total_written, err
+			}
+			sr.reader.Discard(1)
+		default:
+			// Encountered a marker.
+			return total_written, nil
+		}
+	}
+}
+
+// Walk through marker segments of JPEG stream, entropy-coded data is skipped without buffering.
+//
+// Walking stops at the first error returned by visitor, `ErrStopWalk` stops walking without error.
+func WalkJpeg(r io.Reader, visit func(seg *JpegGeneralSegment) error) error {
+
+	sr := NewSegmentReader(r)
+
+	for {
+		if sr.InData() {
+			_, err := sr.CopyData(io.Discard)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		seg, err := sr.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		err = visit(seg.(*JpegGeneralSegment))
+		if err != nil {
+			if err == ErrStopWalk {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Rewrite JPEG stream from reader to writer, with constant memory usage.
+//
+// Every marker segment is passed to visitor, and replaced by returned segments: return nil to drop the segment,
+// or insert new segments around it. Entropy-coded data and trailing data are copied as is.
+func RewriteJpeg(r io.Reader, w io.Writer, visit func(seg *JpegGeneralSegment) ([]JpegSegment, error)) (int64, error) {
+
+	sr := NewSegmentReader(r)
+	bw := bufio.NewWriter(w)
+	total_written := int64(0)
+
+	for {
+		if sr.InData() {
+			written, err := sr.CopyData(bw)
+			total_written += written
+			if err != nil {
+				return total_written, err
+			}
+			continue
+		}
+
+		seg, err := sr.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return total_written, err
+		}
+
+		replacement, err := visit(seg.(*JpegGeneralSegment))
+		if err != nil {
+			return total_written, err
+		}
+
+		for _, elem := range replacement {
+			written, err := elem.WriteTo(bw)
+			total_written += written
+			if err != nil {
+				return total_written, err
+			}
+		}
+	}
+
+	return total_written, bw.Flush()
+}
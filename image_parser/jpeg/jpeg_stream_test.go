@@ -0,0 +1,122 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	. "imagecore/image_parser/jpeg"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// Create a JPEG image larger than the read buffer.
+func createNoiseJpeg(t *testing.T) []byte {
+	img := image.NewGray(image.Rect(0, 0, 256, 256))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSegmentReaderRoundTrip(t *testing.T) {
+
+	raw_bytes := append(createNoiseJpeg(t), []byte("trailing data")...)
+
+	img := parseTestJpeg(t, raw_bytes)
+	buf := new(bytes.Buffer)
+	if _, err := img.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), raw_bytes) {
+		t.Errorf("Round trip mismatch, expected %d bytes, got %d", len(raw_bytes), buf.Len())
+	}
+
+	last, ok := img.Segments[len(img.Segments)-1].(*JpegRawSegment)
+	if !ok || string(*last.Data) != "trailing data" {
+		t.Errorf("Expected trailing data segment.")
+	}
+}
+
+func TestSegmentReaderEcs(t *testing.T) {
+
+	// SOI, SOS, ECS with stuffed bytes, restart marker and fill byte, EOI.
+	sos := []byte{0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00}
+	ecs := []byte{0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56, 0xFF, 0xFF, 0x00, 0x78}
+	stream := append([]byte{0xFF, 0xD8}, sos...)
+	stream = append(stream, ecs...)
+	stream = append(stream, 0xFF, 0xD9)
+
+	sr := NewSegmentReader(bytes.NewReader(stream))
+	types := []string{}
+	for {
+		seg, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read segment: %v", err)
+		}
+		switch s := seg.(type) {
+		case *JpegGeneralSegment:
+			types = append(types, string(rune(s.SegmentType)))
+		case *JpegEcsSegment:
+			types = append(types, "ecs")
+			if !bytes.Equal(*s.Data, ecs) {
+				t.Errorf("ECS mismatch: %x", *s.Data)
+			}
+		}
+	}
+	if len(types) != 4 || types[2] != "ecs" {
+		t.Errorf("Unexpected segments: %q", types)
+	}
+	if sr.Offset() != int64(len(stream)) {
+		t.Errorf("Expected offset %d, got %d", len(stream), sr.Offset())
+	}
+
+	// Truncated entropy-coded data.
+	_, _, err := ReadJpeg(bytes.NewReader(stream[:len(stream)-3]))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestRewriteJpeg(t *testing.T) {
+
+	raw_bytes := createNoiseJpeg(t)
+	comment := NewGeneralSegment(0xFE, []byte("rewritten"))
+
+	out := new(bytes.Buffer)
+	_, err := RewriteJpeg(bytes.NewReader(raw_bytes), out, func(seg *JpegGeneralSegment) ([]JpegSegment, error) {
+		switch seg.SegmentType {
+		case 0xD8: // Insert comment after SOI.
+			return []JpegSegment{seg, comment}, nil
+		default:
+			return []JpegSegment{seg}, nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("Failed to rewrite image: %v", err)
+	}
+
+	expected := append([]byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x0B}, []byte("rewritten")...)
+	expected = append(expected, raw_bytes[2:]...)
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Unexpected rewrite output.")
+	}
+
+	// Walk stops at SOS.
+	markers := []byte{}
+	err = WalkJpeg(bytes.NewReader(out.Bytes()), func(seg *JpegGeneralSegment) error {
+		markers = append(markers, seg.SegmentType)
+		if seg.SegmentType == 0xDA {
+			return ErrStopWalk
+		}
+		return nil
+	})
+	if err != nil || markers[1] != 0xFE || markers[len(markers)-1] != 0xDA {
+		t.Errorf("Unexpected walk result: %x (%v)", markers, err)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"io"
-	"log"
+	"sort"
 	"strings"
 
 	"golang.org/x/exp/slices"
@@ -12,6 +12,29 @@ import (
 
 var (
 	ErrInvalidAppSegmentIndex = errors.New("invalid app segment")
+	ErrIccProfileTooLarge     = errors.New("icc profile too large to fit in 255 app2 segments")
+	ErrInvalidIccChunk        = errors.New("invalid or incomplete icc profile chunks")
+	ErrExifTooLarge           = errors.New("exif data too large to fit in app1 segment")
+	ErrXmpTooLarge            = errors.New("xmp data too large to fit in app1 segment")
+	ErrCommentTooLarge        = errors.New("comment too large to fit in com segment")
+)
+
+// ICC profile APP2 segment.
+//
+// Each APP2 segment holds the signature, a 1-based sequence number, the total number of chunks,
+// and then the chunk data. Segment length is 16-bit, includes the 2 length bytes itself.
+var iccChunkSignature = []byte("ICC_PROFILE\x00")
+
+// EXIF APP1 segment signature.
+var exifSignature = []byte("Exif\x00\x00")
+
+// XMP APP1 segment signature.
+var xmpSignature = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+const (
+	iccChunkHeaderSize = 14                              // Signature (12 bytes) + sequence number + chunk count.
+	iccMaxChunkSize    = 0xFFFF - 2 - iccChunkHeaderSize // Max profile bytes in one segment.
+	iccMaxChunkCount   = 255                             // Sequence number is 1 byte.
 )
 
 type JpegImage struct {
@@ -19,73 +42,25 @@ type JpegImage struct {
 }
 
 // Read JPEG and convert into segment list.
+//
+// Segments are read through `SegmentReader`, so the input is not buffered as a whole.
 func ReadJpeg(r io.Reader) ([]JpegSegment, int64, error) {
 
-	// Read whole file into buffer.
-	buf := bytes.NewBuffer([]byte{}) // Create file content placeholder.
-	file_len, err := io.Copy(buf, r) // Copy all bytes.
-	if err != nil {
-		return nil, 0, err
-	}
-
 	ret := make([]JpegSegment, 0)
 
-	readseeker := bytes.NewReader(buf.Bytes()) // New reader from JPEG bytes.
-
-	total_read := 0 // Total read byte counter.
-
+	sr := NewSegmentReader(r)
 	for { // Read loop
-		tmp := JpegGeneralSegment{}                 // Empty general segment.
-		read_bytes, err := tmp.ReadFrom(readseeker) // Read until segment end.
-		total_read += int(read_bytes)               // Add counter.
+		seg, err := sr.Next()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return nil, int64(total_read), err
-		}
-
-		// Process segment.
-		switch tmp.SegmentType {
-
-		case jpegSOS: // Encountered start of scan segment.
-			// Read ECS segment.
-			tmp_ecs := new(JpegEcsSegment)                       // Empty ECS segment.
-			read_bytes, err = tmp_ecs.ReadEcsSegment(readseeker) // Read
-			total_read += int(read_bytes)                        // Add read counter.
-			if err != nil {
-				return nil, int64(total_read), err
-			}
-			ret = append(ret, &tmp)    // Append SOS segment.
-			ret = append(ret, tmp_ecs) // Append ECS segment.
-			continue
-
-		case jpegEOI: // Encountered end of image segment.
-			ret = append(ret, &tmp)                           // Append EOI segment.
-			remainbyte := new(JpegRawSegment)                 // Remain bytes placeholder.
-			read_byte, err := remainbyte.ReadFrom(readseeker) // Read all remain bytes.
-			total_read += int(read_byte)                      // Add counter.
-			if err != nil {
-				if err == io.EOF { // Exit read loop if EOF.
-					break
-				} else {
-					return nil, int64(total_read), err
-				}
-			}
-			ret = append(ret, remainbyte) // Append segment
-
-		default:
-			ret = append(ret, &tmp) // Append segment
+			return nil, sr.Offset(), err
 		}
+		ret = append(ret, seg)
 	}
 
-	if total_read != int(file_len) {
-		// TODO: checker
-		log.Println("Total read number mismatch.")
-
-	}
-
-	return ret, int64(total_read), err
+	return ret, sr.Offset(), nil
 }
 
 // Create new general segment.
@@ -115,6 +90,31 @@ func NewGeneralSegmentFromSegmentName(segment_type string, data []byte) (*JpegGe
 	return NewGeneralSegment(seg_value, data), nil
 }
 
+// Read JPEG segments up to and including the first SOS segment.
+//
+// Entropy-coded data is not read, so this only consumes the header part of the input.
+// Reading stops at EOI as well, if the image has no scan.
+func ReadJpegHeader(r io.Reader) ([]JpegSegment, int64, error) {
+
+	ret := make([]JpegSegment, 0)
+	total_read := int64(0)
+
+	for {
+		tmp := new(JpegGeneralSegment)
+		read_bytes, err := tmp.ReadFrom(r)
+		total_read += read_bytes
+		if err != nil {
+			return nil, total_read, err
+		}
+
+		ret = append(ret, tmp)
+
+		if tmp.SegmentType == jpegSOS || tmp.SegmentType == jpegEOI {
+			return ret, total_read, nil
+		}
+	}
+}
+
 // Read JPEG from reader.
 func (img *JpegImage) ReadFrom(r io.Reader) (int64, error) {
 	seg_list, total_read, err := ReadJpeg(r)
@@ -154,6 +154,8 @@ func (img JpegImage) Read(p []byte) (int, error) {
 }
 
 // Insert APP(0-15) segment into image.
+//
+// If the APP segment already exists, the first one will be replaced.
 func (im *JpegImage) InsertAppSement(app_index int, data []byte) error {
 
 	if app_index < 0 || app_index > 15 { // Check if app_index in is valid range.
@@ -182,10 +184,33 @@ func (im *JpegImage) InsertAppSement(app_index int, data []byte) error {
 		return nil                                                               // Return
 	}
 
-	// Find insert position.
-	//  New APP segment will be inserted after SOI
-	//  and before any other non-APP segments.
-	target_index = slices.IndexFunc(im.Segments, func(elem JpegSegment) bool {
+	// Insert segment.
+	im.Segments = slices.Insert(im.Segments, im.appSegmentInsertIndex(target_segment_type), JpegSegment(NewGeneralSegment(target_segment_type, data)))
+	return nil
+}
+
+// Append APP(0-15) segment into image.
+//
+// Unlike `InsertAppSement`, existing segments are kept, and the new segment is placed after them.
+func (im *JpegImage) AppendAppSegment(app_index int, data []byte) error {
+
+	if app_index < 0 || app_index > 15 { // Check if app_index in is valid range.
+		return ErrInvalidAppSegmentIndex // Return error.
+	}
+
+	target_segment_type := jpegAPP0 + byte(app_index) // Convert app_index to byte signature.
+
+	// Insert segment.
+	im.Segments = slices.Insert(im.Segments, im.appSegmentInsertIndex(target_segment_type), JpegSegment(NewGeneralSegment(target_segment_type, data)))
+	return nil
+}
+
+// Find insert position of new APP segment.
+//
+// New APP segment will be inserted after SOI, after any APP segments with
+// same or lower index, and before any other non-APP segments.
+func (im *JpegImage) appSegmentInsertIndex(target_segment_type byte) int {
+	target_index := slices.IndexFunc(im.Segments, func(elem JpegSegment) bool {
 		switch _t := elem.(type) { // Check and cast segment type.
 		case *JpegGeneralSegment: // General segment.
 			switch _t.SegmentType { // Check segment signature.
@@ -211,31 +236,282 @@ func (im *JpegImage) InsertAppSement(app_index int, data []byte) error {
 			return true // Stop if encounted unknown segment type.
 		}
 	})
+	if target_index == -1 { // No segment to stop at, append to the end.
+		target_index = len(im.Segments)
+	}
+	return target_index
+}
 
-	// Insert segment.
-	im.Segments = slices.Insert(im.Segments, target_index, JpegSegment(NewGeneralSegment(target_segment_type, data)))
-	return nil
+// Remove all general segments matching the given function.
+func (im *JpegImage) RemoveSegmentsFunc(match func(seg *JpegGeneralSegment) bool) {
+	im.Segments = slices.DeleteFunc(im.Segments, func(elem JpegSegment) bool {
+		_t, ok := elem.(*JpegGeneralSegment)
+		return ok && match(_t)
+	})
 }
 
+// Check if segment is an APP2 segment holding ICC profile chunk.
+func isIccSegment(seg *JpegGeneralSegment) bool {
+	return seg.SegmentType == jpegAPP2 && seg.Data != nil && bytes.HasPrefix(*seg.Data, iccChunkSignature)
+}
+
+// Embed ICC profile into image.
+//
+// The profile is split into numbered APP2 chunks if it doesn't fit in one segment.
+// Existing ICC chunks are removed, other APP2 segments (e.g. FPXR, MPF) are kept.
 func (im *JpegImage) EmbedIccProfile(icc_profile []byte) error {
 
+	// Calculate chunk count.
+	chunk_count := (len(icc_profile) + iccMaxChunkSize - 1) / iccMaxChunkSize
+	if chunk_count == 0 { // Empty profile still takes one chunk.
+		chunk_count = 1
+	}
+	if chunk_count > iccMaxChunkCount {
+		return ErrIccProfileTooLarge
+	}
+
+	// Remove existing ICC chunks.
+	im.RemoveSegmentsFunc(isIccSegment)
+
+	for i := 0; i < chunk_count; i++ {
+
+		// Calculate chunk boundary.
+		chunk_start := i * iccMaxChunkSize
+		chunk_end := min(chunk_start+iccMaxChunkSize, len(icc_profile))
+
+		buf := bytes.NewBuffer(make([]byte, 0, iccChunkHeaderSize+chunk_end-chunk_start))
+		buf.Write(iccChunkSignature)                      // ICC chunk signature.
+		buf.Write([]byte{byte(i + 1), byte(chunk_count)}) // Sequence number and chunk count.
+		buf.Write(icc_profile[chunk_start:chunk_end])     // Chunk data.
+
+		err := im.AppendAppSegment(2, buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Extract embedded ICC profile from image.
+//
+// Chunks are reassembled in sequence order. Returns nil if image has no embedded profile.
+func (im *JpegImage) ExtractIccProfile() ([]byte, error) {
+
+	chunks := make([][]byte, 0)
+	chunk_count := 0
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok || !isIccSegment(seg) {
+			continue
+		}
+
+		data := *seg.Data
+		if len(data) < iccChunkHeaderSize {
+			return nil, ErrInvalidIccChunk
+		}
+
+		// Every chunk should declare same chunk count.
+		if chunk_count == 0 {
+			chunk_count = int(data[13])
+		} else if chunk_count != int(data[13]) {
+			return nil, ErrInvalidIccChunk
+		}
+		chunks = append(chunks, data)
+	}
+
+	if len(chunks) == 0 { // No profile.
+		return nil, nil
+	}
+
+	// Check chunk count.
+	if len(chunks) != chunk_count {
+		return nil, ErrInvalidIccChunk
+	}
+
+	// Sort chunks by sequence number.
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i][12] < chunks[j][12]
+	})
+
 	buf := bytes.NewBuffer([]byte{})
+	for i, chunk := range chunks {
+		if int(chunk[12]) != i+1 { // Sequence number should be 1-based and continuous.
+			return nil, ErrInvalidIccChunk
+		}
+		buf.Write(chunk[iccChunkHeaderSize:])
+	}
 
-	written, err := buf.Write(append([]byte("ICC_PROFILE"), []byte{'\x00', '\x01', '\x01'}...)) // ICC chunk header.
-	if err != nil {
-		return err
+	return buf.Bytes(), nil
+}
+
+// Check if segment is an APP1 segment holding EXIF data.
+func isExifSegment(seg *JpegGeneralSegment) bool {
+	return seg.SegmentType == jpegAPP1 && seg.Data != nil && bytes.HasPrefix(*seg.Data, exifSignature)
+}
+
+// Extract EXIF data (TIFF structure, without "Exif\0\0" header) from image.
+//
+// Returns nil if image has no EXIF segment.
+func (im *JpegImage) ExtractExif() ([]byte, error) {
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if ok && isExifSegment(seg) {
+			return (*seg.Data)[len(exifSignature):], nil
+		}
 	}
-	if written != 14 {
-		return io.ErrShortWrite
+	return nil, nil
+}
+
+// Embed EXIF data (TIFF structure, without "Exif\0\0" header) into image.
+//
+// Existing EXIF segment is replaced, other APP1 segments (e.g. XMP) are kept.
+// EXIF segment is placed before other APP1 segments.
+func (im *JpegImage) EmbedExif(exif_data []byte) error {
+
+	// Check segment length.
+	if len(exifSignature)+len(exif_data)+2 > 0xFFFF {
+		return ErrExifTooLarge
 	}
 
-	written, err = buf.Write(icc_profile)
-	if err != nil {
-		return err
+	// Remove existing EXIF segment.
+	im.RemoveSegmentsFunc(isExifSegment)
+
+	data := append(append([]byte{}, exifSignature...), exif_data...)
+
+	// Find first APP1 segment.
+	target_index := slices.IndexFunc(im.Segments, func(elem JpegSegment) bool {
+		seg, ok := elem.(*JpegGeneralSegment)
+		return ok && seg.SegmentType == jpegAPP1
+	})
+	if target_index == -1 { // No APP1 segment.
+		target_index = im.appSegmentInsertIndex(jpegAPP1)
+	}
+
+	im.Segments = slices.Insert(im.Segments, target_index, JpegSegment(NewGeneralSegment(jpegAPP1, data)))
+	return nil
+}
+
+// Remove metadata segments from image.
+//
+// EXIF/XMP (APP1), IPTC (APP13), other vendor APP segments, comments and trailing bytes after EOI
+// are removed. JFIF (APP0) and Adobe (APP14) are kept since they affect decoding.
+// ICC profile (APP2) is kept if `keep_icc` is set.
+func (im *JpegImage) StripMetadata(keep_icc bool) {
+
+	stripped := make([]JpegSegment, 0, len(im.Segments))
+	after_eoi := false
+
+	for _, elem := range im.Segments {
+
+		// Drop everything after EOI.
+		if after_eoi {
+			continue
+		}
+
+		if seg, ok := elem.(*JpegGeneralSegment); ok {
+			switch seg.SegmentType {
+			case jpegEOI:
+				after_eoi = true
+			case jpegAPP2:
+				if !keep_icc || !isIccSegment(seg) { // Keep ICC profile only.
+					continue
+				}
+			case jpegAPP1, jpegAPP3, jpegAPP4, jpegAPP5, jpegAPP6, jpegAPP7, jpegAPP8, jpegAPP9, jpegAPP10, jpegAPP11, jpegAPP12, jpegAPP13, jpegAPP15, jpegCOM_:
+				continue
+			}
+		}
+
+		stripped = append(stripped, elem)
 	}
-	if written != len(icc_profile) {
-		return io.ErrShortWrite
+
+	im.Segments = stripped
+}
+
+// Check if segment is an APP1 segment holding XMP packet.
+func isXmpSegment(seg *JpegGeneralSegment) bool {
+	return seg.SegmentType == jpegAPP1 && seg.Data != nil && bytes.HasPrefix(*seg.Data, xmpSignature)
+}
+
+// Extract XMP packet from image.
+//
+// Returns nil if image has no XMP segment. Extended XMP is not supported.
+func (im *JpegImage) ExtractXmp() ([]byte, error) {
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if ok && isXmpSegment(seg) {
+			return (*seg.Data)[len(xmpSignature):], nil
+		}
 	}
+	return nil, nil
+}
+
+// Embed XMP packet into image.
+//
+// Existing XMP segment is replaced, the new one is placed after other APP1 segments.
+func (im *JpegImage) EmbedXmp(xmp_data []byte) error {
+
+	// Check segment length.
+	if len(xmpSignature)+len(xmp_data)+2 > 0xFFFF {
+		return ErrXmpTooLarge
+	}
+
+	// Remove existing XMP segment.
+	im.RemoveSegmentsFunc(isXmpSegment)
+
+	data := append(append([]byte{}, xmpSignature...), xmp_data...)
+	return im.AppendAppSegment(1, data)
+}
 
-	return im.InsertAppSement(2, buf.Bytes())
+// Get data of all COM segments, in file order.
+func (im *JpegImage) Comments() [][]byte {
+	ret := make([][]byte, 0)
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if ok && seg.SegmentType == jpegCOM_ && seg.Data != nil {
+			ret = append(ret, *seg.Data)
+		}
+	}
+	return ret
+}
+
+// Add COM segment into image.
+//
+// Existing comments are kept, the new one is placed after APP and COM segments, before any table or frame segments.
+func (im *JpegImage) AddComment(comment []byte) error {
+
+	// Check segment length.
+	if len(comment)+2 > 0xFFFF {
+		return ErrCommentTooLarge
+	}
+
+	target_index := slices.IndexFunc(im.Segments, func(elem JpegSegment) bool {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok {
+			return true // Stop if encountered raw segment.
+		}
+		is_app := seg.SegmentType >= jpegAPP0 && seg.SegmentType <= jpegAPP15
+		return !is_app && seg.SegmentType != jpegSOI && seg.SegmentType != jpegCOM_
+	})
+	if target_index == -1 { // No segment to stop at, append to the end.
+		target_index = len(im.Segments)
+	}
+
+	// COM segment always has length field, even if comment is empty.
+	seg := NewGeneralSegment(jpegCOM_, comment)
+	if seg.Data == nil {
+		empty := []byte{}
+		seg.Data = &empty
+		seg.Length = 2
+	}
+
+	im.Segments = slices.Insert(im.Segments, target_index, JpegSegment(seg))
+	return nil
+}
+
+// Remove all COM segments from image.
+func (im *JpegImage) RemoveComments() {
+	im.RemoveSegmentsFunc(func(seg *JpegGeneralSegment) bool {
+		return seg.SegmentType == jpegCOM_
+	})
 }
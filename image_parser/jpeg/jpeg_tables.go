@@ -0,0 +1,479 @@
+package jpeg_parser
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Define errors.
+var (
+	ErrInvalidFrameHeader = errors.New("invalid jpeg frame header")
+	ErrInvalidScanHeader  = errors.New("invalid jpeg scan header")
+	ErrInvalidQuantTable  = errors.New("invalid jpeg quantization table")
+	ErrInvalidHuffTable   = errors.New("invalid jpeg huffman table")
+	ErrInvalidRestart     = errors.New("invalid jpeg restart interval")
+	ErrSegmentNotFound    = errors.New("jpeg segment not found")
+)
+
+// Zigzag order to natural (row-major) order.
+var ZigzagToNatural = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// Frame component specification.
+type FrameComponent struct {
+	Id              uint8 // Component identifier.
+	HorizontalScale uint8 // Horizontal sampling factor (1~4).
+	VerticalScale   uint8 // Vertical sampling factor (1~4).
+	QuantTableId    uint8 // Quantization table destination selector (0~3).
+}
+
+// Frame header (SOFn).
+type FrameHeader struct {
+	Marker     byte // SOF marker, identifies the coding process.
+	Precision  uint8
+	Height     uint16 // Number of lines, 0 means defined by DNL.
+	Width      uint16
+	Components []FrameComponent
+}
+
+// Check if frame is progressive (SOF2, SOF6, SOF10, SOF14).
+func (h *FrameHeader) IsProgressive() bool {
+	switch h.Marker {
+	case jpegSOF2, jpegSOF6, jpegSOF10, jpegSOF14:
+		return true
+	default:
+		return false
+	}
+}
+
+// Check if frame uses arithmetic coding (SOF9~SOF15).
+func (h *FrameHeader) IsArithmetic() bool {
+	return h.Marker >= jpegSOF9 && h.Marker <= jpegSOF15 && h.Marker != jpegDAC
+}
+
+// Check if frame is lossless (SOF3, SOF7, SOF11, SOF15).
+func (h *FrameHeader) IsLossless() bool {
+	switch h.Marker {
+	case jpegSOF3, jpegSOF7, jpegSOF11, jpegSOF15:
+		return true
+	default:
+		return false
+	}
+}
+
+// Check if frame is baseline sequential (SOF0).
+func (h *FrameHeader) IsBaseline() bool {
+	return h.Marker == jpegSOF0
+}
+
+// Get maximum sampling factors of all components.
+func (h *FrameHeader) MaxScale() (uint8, uint8) {
+	var max_h, max_v uint8 = 1, 1
+	for _, c := range h.Components {
+		max_h = max(max_h, c.HorizontalScale)
+		max_v = max(max_v, c.VerticalScale)
+	}
+	return max_h, max_v
+}
+
+// Get chroma subsampling notation, e.g. "4:2:0", for 3-component frames.
+//
+// Returns an empty string if the layout has no common notation.
+func (h *FrameHeader) ChromaSubsampling() string {
+	if len(h.Components) != 3 {
+		return ""
+	}
+
+	y, cb, cr := h.Components[0], h.Components[1], h.Components[2]
+	if cb.HorizontalScale != 1 || cb.VerticalScale != 1 || cr.HorizontalScale != 1 || cr.VerticalScale != 1 {
+		return ""
+	}
+
+	switch [2]uint8{y.HorizontalScale, y.VerticalScale} {
+	case [2]uint8{1, 1}:
+		return "4:4:4"
+	case [2]uint8{2, 1}:
+		return "4:2:2"
+	case [2]uint8{1, 2}:
+		return "4:4:0"
+	case [2]uint8{2, 2}:
+		return "4:2:0"
+	case [2]uint8{4, 1}:
+		return "4:1:1"
+	case [2]uint8{4, 2}:
+		return "4:1:0"
+	default:
+		return ""
+	}
+}
+
+// Encode frame header to segment data.
+func (h *FrameHeader) Bytes() []byte {
+	data := make([]byte, 6+3*len(h.Components))
+	data[0] = h.Precision
+	binary.BigEndian.PutUint16(data[1:3], h.Height)
+	binary.BigEndian.PutUint16(data[3:5], h.Width)
+	data[5] = uint8(len(h.Components))
+	for i, c := range h.Components {
+		data[6+i*3] = c.Id
+		data[7+i*3] = c.HorizontalScale<<4 | c.VerticalScale
+		data[8+i*3] = c.QuantTableId
+	}
+	return data
+}
+
+// Check if marker is one of SOFn markers.
+func isFrameMarker(marker byte) bool {
+	return marker >= jpegSOF0 && marker <= jpegSOF15 && marker != jpegDHT && marker != jpegJPG && marker != jpegDAC
+}
+
+// Parse frame header from SOFn segment.
+func ParseFrameHeader(seg *JpegGeneralSegment) (*FrameHeader, error) {
+
+	if !isFrameMarker(seg.SegmentType) || seg.Data == nil {
+		return nil, ErrInvalidSegmentType
+	}
+
+	data := *seg.Data
+	if len(data) < 6 {
+		return nil, ErrInvalidFrameHeader
+	}
+
+	header := &FrameHeader{
+		Marker:    seg.SegmentType,
+		Precision: data[0],
+		Height:    binary.BigEndian.Uint16(data[1:3]),
+		Width:     binary.BigEndian.Uint16(data[3:5]),
+	}
+
+	component_count := int(data[5])
+	if component_count == 0 || len(data) < 6+3*component_count {
+		return nil, ErrInvalidFrameHeader
+	}
+
+	header.Components = make([]FrameComponent, component_count)
+	for i := range header.Components {
+		c := FrameComponent{
+			Id:              data[6+i*3],
+			HorizontalScale: data[7+i*3] >> 4,
+			VerticalScale:   data[7+i*3] & 0x0F,
+			QuantTableId:    data[8+i*3],
+		}
+		if c.HorizontalScale < 1 || c.HorizontalScale > 4 || c.VerticalScale < 1 || c.VerticalScale > 4 || c.QuantTableId > 3 {
+			return nil, ErrInvalidFrameHeader
+		}
+		header.Components[i] = c
+	}
+
+	return header, nil
+}
+
+// Quantization table.
+type QuantTable struct {
+	Id        uint8      // Table destination identifier (0~3).
+	Precision uint8      // 0 for 8-bit, 1 for 16-bit values.
+	Values    [64]uint16 // Values in zigzag order.
+}
+
+// Get values in natural (row-major) order.
+func (q QuantTable) Natural() [64]uint16 {
+	var ret [64]uint16
+	for i, v := range q.Values {
+		ret[ZigzagToNatural[i]] = v
+	}
+	return ret
+}
+
+// Parse all quantization tables from DQT segment.
+func ParseQuantTables(seg *JpegGeneralSegment) ([]QuantTable, error) {
+
+	if seg.SegmentType != jpegDQT || seg.Data == nil {
+		return nil, ErrInvalidSegmentType
+	}
+
+	data := *seg.Data
+	tables := make([]QuantTable, 0, 1)
+	for len(data) > 0 {
+		table := QuantTable{
+			Precision: data[0] >> 4,
+			Id:        data[0] & 0x0F,
+		}
+		if table.Precision > 1 || table.Id > 3 {
+			return nil, ErrInvalidQuantTable
+		}
+		data = data[1:]
+
+		if table.Precision == 0 { // 8-bit values.
+			if len(data) < 64 {
+				return nil, ErrInvalidQuantTable
+			}
+			for i := range table.Values {
+				table.Values[i] = uint16(data[i])
+			}
+			data = data[64:]
+		} else { // 16-bit values.
+			if len(data) < 128 {
+				return nil, ErrInvalidQuantTable
+			}
+			for i := range table.Values {
+				table.Values[i] = binary.BigEndian.Uint16(data[i*2:])
+			}
+			data = data[128:]
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+// Encode quantization tables to DQT segment data.
+func EncodeQuantTables(tables []QuantTable) []byte {
+	data := make([]byte, 0, len(tables)*65)
+	for _, table := range tables {
+		data = append(data, table.Precision<<4|table.Id)
+		for _, v := range table.Values {
+			if table.Precision == 0 {
+				data = append(data, uint8(v))
+			} else {
+				data = binary.BigEndian.AppendUint16(data, v)
+			}
+		}
+	}
+	return data
+}
+
+// Huffman table classes.
+const (
+	HuffClassDC uint8 = 0
+	HuffClassAC uint8 = 1
+)
+
+// Huffman table specification.
+type HuffTable struct {
+	Class   uint8     // 0 for DC, 1 for AC.
+	Id      uint8     // Table destination identifier (0~3).
+	Lengths [16]uint8 // Number of codes of each length (1~16 bits).
+	Symbols []uint8   // Symbols in order of increasing code length.
+}
+
+// Parse all Huffman tables from DHT segment.
+func ParseHuffTables(seg *JpegGeneralSegment) ([]HuffTable, error) {
+
+	if seg.SegmentType != jpegDHT || seg.Data == nil {
+		return nil, ErrInvalidSegmentType
+	}
+
+	data := *seg.Data
+	tables := make([]HuffTable, 0, 1)
+	for len(data) > 0 {
+		if len(data) < 17 {
+			return nil, ErrInvalidHuffTable
+		}
+
+		table := HuffTable{
+			Class: data[0] >> 4,
+			Id:    data[0] & 0x0F,
+		}
+		if table.Class > 1 || table.Id > 3 {
+			return nil, ErrInvalidHuffTable
+		}
+
+		symbol_count := 0
+		for i := range table.Lengths {
+			table.Lengths[i] = data[1+i]
+			symbol_count += int(table.Lengths[i])
+		}
+		if symbol_count > 256 || len(data) < 17+symbol_count {
+			return nil, ErrInvalidHuffTable
+		}
+
+		table.Symbols = append([]uint8{}, data[17:17+symbol_count]...)
+		data = data[17+symbol_count:]
+
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+// Encode Huffman tables to DHT segment data.
+func EncodeHuffTables(tables []HuffTable) []byte {
+	data := make([]byte, 0)
+	for _, table := range tables {
+		data = append(data, table.Class<<4|table.Id)
+		data = append(data, table.Lengths[:]...)
+		data = append(data, table.Symbols...)
+	}
+	return data
+}
+
+// Parse restart interval (in MCUs) from DRI segment.
+func ParseRestartInterval(seg *JpegGeneralSegment) (uint16, error) {
+
+	if seg.SegmentType != jpegDRI || seg.Data == nil {
+		return 0, ErrInvalidSegmentType
+	}
+
+	data := *seg.Data
+	if len(data) != 2 {
+		return 0, ErrInvalidRestart
+	}
+
+	return binary.BigEndian.Uint16(data), nil
+}
+
+// Scan component specification.
+type ScanComponent struct {
+	Id        uint8 // Component selector, matches `FrameComponent.Id`.
+	DCTableId uint8 // DC entropy coding table selector.
+	ACTableId uint8 // AC entropy coding table selector.
+}
+
+// Scan header (SOS).
+type ScanHeader struct {
+	Components    []ScanComponent
+	SpectralStart uint8 // Start of spectral selection (Ss).
+	SpectralEnd   uint8 // End of spectral selection (Se).
+	ApproxHigh    uint8 // Successive approximation bit position high (Ah).
+	ApproxLow     uint8 // Successive approximation bit position low (Al).
+}
+
+// Encode scan header to segment data.
+func (h *ScanHeader) Bytes() []byte {
+	data := make([]byte, 1+2*len(h.Components)+3)
+	data[0] = uint8(len(h.Components))
+	for i, c := range h.Components {
+		data[1+i*2] = c.Id
+		data[2+i*2] = c.DCTableId<<4 | c.ACTableId
+	}
+	tail := data[1+2*len(h.Components):]
+	tail[0] = h.SpectralStart
+	tail[1] = h.SpectralEnd
+	tail[2] = h.ApproxHigh<<4 | h.ApproxLow
+	return data
+}
+
+// Parse scan header from SOS segment.
+func ParseScanHeader(seg *JpegGeneralSegment) (*ScanHeader, error) {
+
+	if seg.SegmentType != jpegSOS || seg.Data == nil {
+		return nil, ErrInvalidSegmentType
+	}
+
+	data := *seg.Data
+	if len(data) < 1 {
+		return nil, ErrInvalidScanHeader
+	}
+
+	component_count := int(data[0])
+	if component_count < 1 || component_count > 4 || len(data) != 1+2*component_count+3 {
+		return nil, ErrInvalidScanHeader
+	}
+
+	header := &ScanHeader{Components: make([]ScanComponent, component_count)}
+	for i := range header.Components {
+		header.Components[i] = ScanComponent{
+			Id:        data[1+i*2],
+			DCTableId: data[2+i*2] >> 4,
+			ACTableId: data[2+i*2] & 0x0F,
+		}
+	}
+
+	tail := data[1+2*component_count:]
+	header.SpectralStart = tail[0]
+	header.SpectralEnd = tail[1]
+	header.ApproxHigh = tail[2] >> 4
+	header.ApproxLow = tail[2] & 0x0F
+
+	return header, nil
+}
+
+// Find first general segment with given type.
+func (im *JpegImage) findSegment(match func(marker byte) bool) *JpegGeneralSegment {
+	for _, elem := range im.Segments {
+		if seg, ok := elem.(*JpegGeneralSegment); ok && match(seg.SegmentType) {
+			return seg
+		}
+	}
+	return nil
+}
+
+// Get frame header of image.
+func (im *JpegImage) FrameHeader() (*FrameHeader, error) {
+	seg := im.findSegment(isFrameMarker)
+	if seg == nil {
+		return nil, ErrSegmentNotFound
+	}
+	return ParseFrameHeader(seg)
+}
+
+// Get all quantization tables of image, later definitions override earlier ones.
+func (im *JpegImage) QuantTables() (map[uint8]QuantTable, error) {
+	ret := make(map[uint8]QuantTable)
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok || seg.SegmentType != jpegDQT {
+			continue
+		}
+		tables, err := ParseQuantTables(seg)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			ret[table.Id] = table
+		}
+	}
+	return ret, nil
+}
+
+// Get all Huffman tables of image in definition order.
+func (im *JpegImage) HuffTables() ([]HuffTable, error) {
+	ret := make([]HuffTable, 0)
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok || seg.SegmentType != jpegDHT {
+			continue
+		}
+		tables, err := ParseHuffTables(seg)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, tables...)
+	}
+	return ret, nil
+}
+
+// Get restart interval of image, 0 if not defined.
+func (im *JpegImage) RestartInterval() (uint16, error) {
+	seg := im.findSegment(func(marker byte) bool { return marker == jpegDRI })
+	if seg == nil {
+		return 0, nil
+	}
+	return ParseRestartInterval(seg)
+}
+
+// Get all scan headers of image.
+func (im *JpegImage) ScanHeaders() ([]*ScanHeader, error) {
+	ret := make([]*ScanHeader, 0)
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if !ok || seg.SegmentType != jpegSOS {
+			continue
+		}
+		header, err := ParseScanHeader(seg)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, header)
+	}
+	return ret, nil
+}
@@ -0,0 +1,112 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestJpegFrameHeader(t *testing.T) {
+	img := parseTestJpeg(t, createTestJpeg(t, 33, 17))
+
+	header, err := img.FrameHeader()
+	if err != nil {
+		t.Fatalf("Failed to parse frame header: %v", err)
+	}
+
+	if header.Width != 33 || header.Height != 17 || header.Precision != 8 {
+		t.Errorf("Unexpected frame header: %+v", header)
+	}
+	if !header.IsBaseline() || header.IsProgressive() || header.IsArithmetic() || header.IsLossless() {
+		t.Errorf("Expected baseline frame, got marker %#x", header.Marker)
+	}
+	if len(header.Components) != 3 {
+		t.Fatalf("Expected 3 components, got %d", len(header.Components))
+	}
+	if header.Components[0].QuantTableId != 0 || header.Components[1].QuantTableId != 1 {
+		t.Errorf("Unexpected quant table selectors: %+v", header.Components)
+	}
+	if s := header.ChromaSubsampling(); s != "4:2:0" {
+		t.Errorf("Expected 4:2:0 subsampling, got %q", s)
+	}
+	if h, v := header.MaxScale(); h != 2 || v != 2 {
+		t.Errorf("Expected max scale 2x2, got %dx%d", h, v)
+	}
+}
+
+func TestJpegTables(t *testing.T) {
+	img := parseTestJpeg(t, createTestJpeg(t, 16, 16))
+
+	quant, err := img.QuantTables()
+	if err != nil {
+		t.Fatalf("Failed to parse quant tables: %v", err)
+	}
+	if len(quant) != 2 {
+		t.Fatalf("Expected 2 quant tables, got %d", len(quant))
+	}
+	// Quality 90 scales the luminance DC entry 16 to 3.
+	if quant[0].Precision != 0 || quant[0].Values[0] != 3 {
+		t.Errorf("Unexpected luminance table: %+v", quant[0])
+	}
+	if quant[0].Natural()[1] != quant[0].Values[1] || quant[0].Natural()[8] != quant[0].Values[2] {
+		t.Errorf("Natural order mismatch.")
+	}
+
+	huff, err := img.HuffTables()
+	if err != nil {
+		t.Fatalf("Failed to parse huffman tables: %v", err)
+	}
+	if len(huff) != 4 {
+		t.Fatalf("Expected 4 huffman tables, got %d", len(huff))
+	}
+	for _, table := range huff {
+		count := 0
+		for _, l := range table.Lengths {
+			count += int(l)
+		}
+		if count != len(table.Symbols) {
+			t.Errorf("Symbol count mismatch in table %d/%d.", table.Class, table.Id)
+		}
+	}
+	if huff[0].Class != HuffClassDC || len(huff[0].Symbols) != 12 {
+		t.Errorf("Unexpected DC table: %+v", huff[0])
+	}
+
+	interval, err := img.RestartInterval()
+	if err != nil || interval != 0 {
+		t.Errorf("Expected no restart interval, got %d (%v)", interval, err)
+	}
+
+	scans, err := img.ScanHeaders()
+	if err != nil {
+		t.Fatalf("Failed to parse scan headers: %v", err)
+	}
+	if len(scans) != 1 || len(scans[0].Components) != 3 || scans[0].SpectralEnd != 63 {
+		t.Errorf("Unexpected scan headers: %+v", scans)
+	}
+}
+
+func TestJpegTablesRoundTrip(t *testing.T) {
+	quant := []QuantTable{{Id: 1, Precision: 1}}
+	for i := range quant[0].Values {
+		quant[0].Values[i] = uint16(i * 300)
+	}
+	data := EncodeQuantTables(quant)
+	parsed, err := ParseQuantTables(NewGeneralSegment(0xDB, data))
+	if err != nil || len(parsed) != 1 || parsed[0] != quant[0] {
+		t.Errorf("Quant table round trip failed: %v", err)
+	}
+
+	scan := &ScanHeader{
+		Components:    []ScanComponent{{Id: 1, DCTableId: 0, ACTableId: 1}},
+		SpectralStart: 1, SpectralEnd: 5, ApproxHigh: 2, ApproxLow: 1,
+	}
+	parsed_scan, err := ParseScanHeader(NewGeneralSegment(0xDA, scan.Bytes()))
+	if err != nil || !bytes.Equal(parsed_scan.Bytes(), scan.Bytes()) {
+		t.Errorf("Scan header round trip failed: %v", err)
+	}
+
+	if _, err := ParseQuantTables(NewGeneralSegment(0xDB, data[:10])); err != ErrInvalidQuantTable {
+		t.Errorf("Expected ErrInvalidQuantTable, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package jpeg_parser
+
+import (
+	"bytes"
+	"io"
+
+	"golang.org/x/exp/slices"
+)
+
+// Kind of data appended after EOI.
+type TrailerKind string
+
+const (
+	TrailerNone    TrailerKind = "none"    // No data after EOI.
+	TrailerPadding TrailerKind = "padding" // Only 0x00 or 0xFF bytes.
+	TrailerMpf     TrailerKind = "mpf"     // Secondary JPEG images described by MPF APP2 segment.
+	TrailerJpeg    TrailerKind = "jpeg"    // Appended JPEG image without MPF.
+	TrailerVideo   TrailerKind = "video"   // MP4/QuickTime video, e.g. motion photo.
+	TrailerZip     TrailerKind = "zip"     // ZIP archive, e.g. polyglot file.
+	TrailerUnknown TrailerKind = "unknown" // Anything else.
+)
+
+// MPF APP2 segment signature.
+var mpfSignature = []byte("MPF\x00")
+
+// Data appended after EOI.
+type Trailer struct {
+	Kind   TrailerKind
+	Offset int64  // Offset of trailer in file.
+	Data   []byte // Raw trailer bytes.
+}
+
+// Trailer kinds from the least to the most dangerous.
+var trailerSeverity = []TrailerKind{TrailerNone, TrailerPadding, TrailerMpf, TrailerJpeg, TrailerVideo, TrailerUnknown, TrailerZip}
+
+// Get the more dangerous one of two trailer kinds.
+func moreDangerous(a TrailerKind, b TrailerKind) TrailerKind {
+	if slices.Index(trailerSeverity, b) > slices.Index(trailerSeverity, a) {
+		return b
+	}
+	return a
+}
+
+// Get size of JPEG image at the start of data, up to and including its EOI. Returns -1 if EOI is not found.
+func embeddedJpegSize(data []byte) int {
+	sr := NewSegmentReader(bytes.NewReader(data))
+	for {
+		if sr.InData() {
+			if _, err := sr.CopyData(io.Discard); err != nil {
+				return -1
+			}
+			continue
+		}
+		seg, err := sr.Next()
+		if err != nil {
+			return -1
+		}
+		if seg, ok := seg.(*JpegGeneralSegment); ok && seg.SegmentType == jpegEOI {
+			return int(sr.Offset())
+		}
+	}
+}
+
+// Check if data ends with ZIP end of central directory record.
+//
+// ZIP readers locate the end of central directory record from the end of file,
+// so an archive may follow arbitrary bytes. The record is 22 bytes plus comment up to 64KiB.
+func hasZipDirectory(data []byte) bool {
+	tail := data[max(len(data)-0xFFFF-22, 0):]
+	return bytes.Contains(tail, []byte("PK\x05\x06"))
+}
+
+// Classify data appended after EOI.
+//
+// Appended JPEG images are skipped up to their EOI, and the rest is classified as well,
+// so the most dangerous kind found in the trailer is returned.
+// `has_mpf` tells if the main image has MPF APP2 segment, so an appended JPEG is an MPF image.
+func ClassifyTrailer(data []byte, has_mpf bool) TrailerKind {
+
+	if len(data) == 0 {
+		return TrailerNone
+	}
+	// Archive directory is searched in the whole trailer, whatever precedes it.
+	if hasZipDirectory(data) {
+		return TrailerZip
+	}
+
+	kind := TrailerNone
+	for len(data) > 0 {
+		part, size := TrailerUnknown, len(data)
+
+		switch {
+		case !slices.ContainsFunc(data, func(b byte) bool { return b != 0x00 && b != 0xFF }):
+			part = TrailerPadding
+		case bytes.HasPrefix(data, []byte{'\xFF', '\xD8', '\xFF'}):
+			// Appended JPEG without EOI is left as unknown data.
+			if jpeg_size := embeddedJpegSize(data); jpeg_size > 0 {
+				part, size = TrailerJpeg, jpeg_size
+				if has_mpf {
+					part = TrailerMpf
+				}
+			}
+		case len(data) >= 8 && string(data[4:8]) == "ftyp":
+			part = TrailerVideo
+		case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+			part = TrailerZip
+		}
+
+		kind = moreDangerous(kind, part)
+		data = data[size:]
+	}
+	return kind
+}
+
+// Check if image has MPF APP2 segment.
+func (im *JpegImage) hasMpf() bool {
+	return slices.ContainsFunc(im.Segments, func(elem JpegSegment) bool {
+		seg, ok := elem.(*JpegGeneralSegment)
+		return ok && seg.SegmentType == jpegAPP2 && seg.Data != nil && bytes.HasPrefix(*seg.Data, mpfSignature)
+	})
+}
+
+// Find index of raw segment after EOI, -1 if not found.
+func (im *JpegImage) trailerIndex() int {
+	for i, elem := range im.Segments {
+		seg, ok := elem.(*JpegGeneralSegment)
+		if ok && seg.SegmentType == jpegEOI && i+1 < len(im.Segments) {
+			if _, ok := im.Segments[i+1].(*JpegRawSegment); ok {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// Get data appended after EOI, and classify it.
+//
+// Kind is `TrailerNone` with empty data if image has no trailer.
+func (im *JpegImage) Trailer() *Trailer {
+
+	index := im.trailerIndex()
+	if index < 0 {
+		return &Trailer{Kind: TrailerNone, Offset: -1}
+	}
+
+	// Offset is the size of everything before trailer.
+	offset := int64(0)
+	for _, elem := range im.Segments[:index] {
+		switch seg := elem.(type) {
+		case *JpegGeneralSegment:
+			offset += 2 + int64(seg.Length)
+		case *JpegRawSegment:
+			offset += int64(len(*seg.Data))
+		}
+	}
+
+	data := *im.Segments[index].(*JpegRawSegment).Data
+	return &Trailer{Kind: ClassifyTrailer(data, im.hasMpf()), Offset: offset, Data: data}
+}
+
+// Remove data appended after EOI.
+func (im *JpegImage) StripTrailer() {
+	index := im.trailerIndex()
+	if index >= 0 {
+		im.Segments = slices.Delete(im.Segments, index, index+1)
+	}
+}
@@ -0,0 +1,84 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestJpegTrailer(t *testing.T) {
+
+	raw_bytes := createTestJpeg(t, 16, 16)
+	second := createTestJpeg(t, 8, 8)
+
+	zip := append([]byte("PK\x03\x04"), make([]byte, 30)...)
+	polyglot := append([]byte("some text"), []byte("PK\x05\x06")...)
+	polyglot = append(polyglot, make([]byte, 18)...)
+	video := append([]byte{0x00, 0x00, 0x00, 0x18}, []byte("ftypmp42")...)
+
+	cases := []struct {
+		trailer []byte
+		kind    TrailerKind
+	}{
+		{nil, TrailerNone},
+		{[]byte{0x00, 0x00, 0xFF}, TrailerPadding},
+		{second, TrailerJpeg},
+		{video, TrailerVideo},
+		{zip, TrailerZip},
+		{polyglot, TrailerZip},
+		{[]byte("<?php echo 1; ?>"), TrailerUnknown},
+	}
+
+	for _, c := range cases {
+		img := parseTestJpeg(t, append(bytes.Clone(raw_bytes), c.trailer...))
+		trailer := img.Trailer()
+		if trailer.Kind != c.kind {
+			t.Errorf("Expected %s, got %s", c.kind, trailer.Kind)
+		}
+		if c.trailer != nil && (trailer.Offset != int64(len(raw_bytes)) || !bytes.Equal(trailer.Data, c.trailer)) {
+			t.Errorf("Trailer %s: unexpected offset %d or data", c.kind, trailer.Offset)
+		}
+
+		img.StripTrailer()
+		buf := new(bytes.Buffer)
+		img.WriteTo(buf)
+		if !bytes.Equal(buf.Bytes(), raw_bytes) {
+			t.Errorf("Trailer %s: expected trailer to be stripped", c.kind)
+		}
+	}
+
+	// Data after appended JPEG is classified as well.
+	with_zip := append(bytes.Clone(second), zip...)
+	with_padding := append(bytes.Clone(second), 0x00, 0x00)
+	video_with_zip := append(bytes.Clone(video), polyglot...)
+	for _, c := range []struct {
+		trailer []byte
+		kind    TrailerKind
+	}{
+		{with_zip, TrailerZip},
+		{with_padding, TrailerJpeg},
+		{append(bytes.Clone(second), video...), TrailerVideo},
+		{append(bytes.Clone(second), []byte("<?php echo 1; ?>")...), TrailerUnknown},
+		{second[:len(second)-2], TrailerUnknown},
+		{video_with_zip, TrailerZip},
+	} {
+		if kind := ClassifyTrailer(c.trailer, false); kind != c.kind {
+			t.Errorf("Expected %s, got %s", c.kind, kind)
+		}
+	}
+
+	// Motion photo: MPF secondary image followed by video.
+	if kind := ClassifyTrailer(append(bytes.Clone(second), video...), true); kind != TrailerVideo {
+		t.Errorf("Expected %s, got %s", TrailerVideo, kind)
+	}
+	if kind := ClassifyTrailer(with_padding, true); kind != TrailerMpf {
+		t.Errorf("Expected %s, got %s", TrailerMpf, kind)
+	}
+
+	// Appended JPEG with MPF segment.
+	img := parseTestJpeg(t, append(bytes.Clone(raw_bytes), second...))
+	img.AppendAppSegment(2, []byte("MPF\x00II*\x00"))
+	if kind := img.Trailer().Kind; kind != TrailerMpf {
+		t.Errorf("Expected %s, got %s", TrailerMpf, kind)
+	}
+}
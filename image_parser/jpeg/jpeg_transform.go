@@ -0,0 +1,237 @@
+package jpeg_parser
+
+import (
+	"errors"
+)
+
+var (
+	ErrPartialMcu       = errors.New("image has partial mcu at the edge, which can't be transformed losslessly")
+	ErrImageTooSmall    = errors.New("image is smaller than one mcu after trimming")
+	ErrInvalidTransform = errors.New("invalid lossless transform")
+)
+
+// Lossless transform of JPEG image.
+type Transform int
+
+const (
+	TransformNone       Transform = iota // No transform.
+	TransformFlipH                       // Mirror horizontally.
+	TransformFlipV                       // Mirror vertically.
+	TransformTranspose                   // Mirror along top-left to bottom-right diagonal.
+	TransformTransverse                  // Mirror along top-right to bottom-left diagonal.
+	TransformRotate90                    // Rotate 90 degrees clockwise.
+	TransformRotate180                   // Rotate 180 degrees.
+	TransformRotate270                   // Rotate 270 degrees clockwise.
+)
+
+// Get primitive steps of transform, applied in order.
+func (t Transform) steps() []Transform {
+	switch t {
+	case TransformNone:
+		return []Transform{}
+	case TransformFlipH, TransformFlipV, TransformTranspose:
+		return []Transform{t}
+	case TransformTransverse:
+		return []Transform{TransformTranspose, TransformFlipH, TransformFlipV}
+	case TransformRotate90:
+		return []Transform{TransformTranspose, TransformFlipH}
+	case TransformRotate180:
+		return []Transform{TransformFlipH, TransformFlipV}
+	case TransformRotate270:
+		return []Transform{TransformTranspose, TransformFlipV}
+	default:
+		return nil
+	}
+}
+
+// Check which source edges are moved by transform.
+//
+// Partial MCUs on these edges would end up on the left or top of the output, which can't be represented.
+func (t Transform) movesEdges() (bool, bool) {
+	switch t {
+	case TransformFlipH:
+		return true, false
+	case TransformFlipV, TransformRotate90:
+		return false, true
+	case TransformRotate270:
+		return true, false
+	case TransformRotate180, TransformTransverse:
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// Drop partial MCUs at the right or bottom edge.
+func (c *Coefficients) trim(right bool, bottom bool) error {
+
+	mcu_w, mcu_h := c.McuSize()
+	width, height := int(c.Frame.Width), int(c.Frame.Height)
+	if right {
+		width -= width % mcu_w
+	}
+	if bottom {
+		height -= height % mcu_h
+	}
+	if width == 0 || height == 0 {
+		return ErrImageTooSmall
+	}
+	c.Frame.Width, c.Frame.Height = uint16(width), uint16(height)
+
+	mcus_wide, mcus_high := c.McuCount()
+	for i := range c.Components {
+		comp := &c.Components[i]
+		blocks_wide := mcus_wide * int(c.Frame.Components[i].HorizontalScale)
+		blocks_high := mcus_high * int(c.Frame.Components[i].VerticalScale)
+
+		blocks := make([][64]int16, 0, blocks_wide*blocks_high)
+		for y := 0; y < blocks_high; y++ {
+			blocks = append(blocks, comp.Blocks[y*comp.BlocksWide:y*comp.BlocksWide+blocks_wide]...)
+		}
+		comp.Blocks, comp.BlocksWide, comp.BlocksHigh = blocks, blocks_wide, blocks_high
+	}
+	return nil
+}
+
+// Mirror all components horizontally, the image width must be a multiple of MCU width.
+func (c *Coefficients) flipH() {
+	for i := range c.Components {
+		comp := &c.Components[i]
+		for y := 0; y < comp.BlocksHigh; y++ {
+			row := comp.Blocks[y*comp.BlocksWide : (y+1)*comp.BlocksWide]
+			for l, r := 0, len(row)-1; l < r; l, r = l+1, r-1 {
+				row[l], row[r] = row[r], row[l]
+			}
+			// Odd horizontal frequencies change sign.
+			for x := range row {
+				for k := 1; k < 64; k += 2 {
+					row[x][k] = -row[x][k]
+				}
+			}
+		}
+	}
+}
+
+// Mirror all components vertically, the image height must be a multiple of MCU height.
+func (c *Coefficients) flipV() {
+	for i := range c.Components {
+		comp := &c.Components[i]
+		for t, b := 0, comp.BlocksHigh-1; t < b; t, b = t+1, b-1 {
+			for x := 0; x < comp.BlocksWide; x++ {
+				comp.Blocks[t*comp.BlocksWide+x], comp.Blocks[b*comp.BlocksWide+x] = comp.Blocks[b*comp.BlocksWide+x], comp.Blocks[t*comp.BlocksWide+x]
+			}
+		}
+		// Odd vertical frequencies change sign.
+		for x := range comp.Blocks {
+			for k := 8; k < 64; k++ {
+				if (k/8)%2 == 1 {
+					comp.Blocks[x][k] = -comp.Blocks[x][k]
+				}
+			}
+		}
+	}
+}
+
+// Transpose 8x8 values in natural order.
+func transposeBlock(block *[64]int16) {
+	for r := 0; r < 8; r++ {
+		for col := r + 1; col < 8; col++ {
+			block[r*8+col], block[col*8+r] = block[col*8+r], block[r*8+col]
+		}
+	}
+}
+
+// Mirror all components along the main diagonal, along with sampling factors and quantization tables.
+func (c *Coefficients) transpose() {
+
+	c.Frame.Width, c.Frame.Height = c.Frame.Height, c.Frame.Width
+
+	for i := range c.Components {
+		comp := &c.Components[i]
+		frame_comp := &c.Frame.Components[i]
+		frame_comp.HorizontalScale, frame_comp.VerticalScale = frame_comp.VerticalScale, frame_comp.HorizontalScale
+
+		blocks := make([][64]int16, len(comp.Blocks))
+		for y := 0; y < comp.BlocksHigh; y++ {
+			for x := 0; x < comp.BlocksWide; x++ {
+				block := comp.Blocks[y*comp.BlocksWide+x]
+				transposeBlock(&block)
+				blocks[x*comp.BlocksHigh+y] = block
+			}
+		}
+		comp.Blocks, comp.BlocksWide, comp.BlocksHigh = blocks, comp.BlocksHigh, comp.BlocksWide
+	}
+
+	for id, table := range c.QuantTables {
+		natural := table.Natural()
+		var natural_block [64]int16
+		for k, v := range natural {
+			natural_block[k] = int16(v)
+		}
+		transposeBlock(&natural_block)
+		for k := range table.Values {
+			table.Values[k] = uint16(natural_block[ZigzagToNatural[k]])
+		}
+		c.QuantTables[id] = table
+	}
+}
+
+// Apply lossless transform to coefficients.
+//
+// Partial MCUs at edges which would be moved are dropped if `trim` is set, otherwise `ErrPartialMcu` is returned.
+func (c *Coefficients) Transform(t Transform, trim bool) error {
+
+	steps := t.steps()
+	if steps == nil {
+		return ErrInvalidTransform
+	}
+
+	// Check partial MCUs.
+	right, bottom := t.movesEdges()
+	mcu_w, mcu_h := c.McuSize()
+	right = right && int(c.Frame.Width)%mcu_w != 0
+	bottom = bottom && int(c.Frame.Height)%mcu_h != 0
+	if right || bottom {
+		if !trim {
+			return ErrPartialMcu
+		}
+		if err := c.trim(right, bottom); err != nil {
+			return err
+		}
+	}
+
+	for _, step := range steps {
+		switch step {
+		case TransformFlipH:
+			c.flipH()
+		case TransformFlipV:
+			c.flipV()
+		case TransformTranspose:
+			c.transpose()
+		}
+	}
+	return nil
+}
+
+// Apply lossless transform to image, without decoding pixels.
+//
+// Only baseline images are supported, see `ReadCoefficients`. `TransformNone` leaves any image unchanged.
+func (im *JpegImage) Transform(t Transform, trim bool) error {
+
+	// Nothing to do, entropy-coded data is left untouched whatever the coding is.
+	if t == TransformNone {
+		return nil
+	}
+
+	c, err := im.ReadCoefficients()
+	if err != nil {
+		return err
+	}
+
+	err = c.Transform(t, trim)
+	if err != nil {
+		return err
+	}
+
+	return im.WriteCoefficients(c)
+}
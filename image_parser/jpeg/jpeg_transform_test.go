@@ -0,0 +1,115 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+// Map output pixel to source pixel of transform.
+func sourcePixel(t Transform, x, y, w, h int) (int, int) {
+	switch t {
+	case TransformFlipH:
+		return w - 1 - x, y
+	case TransformFlipV:
+		return x, h - 1 - y
+	case TransformTranspose:
+		return y, x
+	case TransformTransverse:
+		return w - 1 - y, h - 1 - x
+	case TransformRotate90:
+		return y, h - 1 - x
+	case TransformRotate180:
+		return w - 1 - x, h - 1 - y
+	case TransformRotate270:
+		return w - 1 - y, x
+	default:
+		return x, y
+	}
+}
+
+// Encode and transform image, then compare decoded result with transformed pixels.
+func checkTransform(t *testing.T, raw_bytes []byte, transform Transform) {
+
+	src, err := jpeg.Decode(bytes.NewReader(raw_bytes))
+	if err != nil {
+		t.Fatalf("Failed to decode source: %v", err)
+	}
+
+	img := parseTestJpeg(t, raw_bytes)
+	if err := img.Transform(transform, false); err != nil {
+		t.Fatalf("Failed to transform image: %v", err)
+	}
+	buf := new(bytes.Buffer)
+	img.WriteTo(buf)
+
+	out, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to decode transformed image (%d): %v", transform, err)
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	ow, oh := out.Bounds().Dx(), out.Bounds().Dy()
+	if transform >= TransformTranspose && transform != TransformRotate180 {
+		w, h = h, w
+	}
+	if ow != w || oh != h {
+		t.Fatalf("Expected %dx%d, got %dx%d", w, h, ow, oh)
+	}
+
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	max_diff := 0
+	for y := 0; y < oh; y++ {
+		for x := 0; x < ow; x++ {
+			sx, sy := sourcePixel(transform, x, y, sw, sh)
+			r1, g1, b1, _ := out.At(x, y).RGBA()
+			r2, g2, b2, _ := src.At(sx, sy).RGBA()
+			for _, d := range []int{int(r1>>8) - int(r2>>8), int(g1>>8) - int(g2>>8), int(b1>>8) - int(b2>>8)} {
+				max_diff = max(max_diff, d, -d)
+			}
+		}
+	}
+	if max_diff > 2 {
+		t.Errorf("Transform %d: max pixel difference %d", transform, max_diff)
+	}
+}
+
+func TestJpegLosslessTransform(t *testing.T) {
+	raw_bytes := createTestJpeg(t, 64, 48)
+	for transform := TransformNone; transform <= TransformRotate270; transform++ {
+		checkTransform(t, raw_bytes, transform)
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, 40, 24))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 7)
+	}
+	buf := new(bytes.Buffer)
+	jpeg.Encode(buf, gray, &jpeg.Options{Quality: 80})
+	for transform := TransformNone; transform <= TransformRotate270; transform++ {
+		checkTransform(t, buf.Bytes(), transform)
+	}
+}
+
+func TestJpegLosslessTransformPartialMcu(t *testing.T) {
+	raw_bytes := createTestJpeg(t, 70, 50)
+
+	img := parseTestJpeg(t, raw_bytes)
+	if err := img.Transform(TransformRotate180, false); err != ErrPartialMcu {
+		t.Errorf("Expected ErrPartialMcu, got %v", err)
+	}
+
+	// Transpose keeps partial MCUs at right and bottom edges.
+	checkTransform(t, raw_bytes, TransformTranspose)
+
+	img = parseTestJpeg(t, raw_bytes)
+	if err := img.Transform(TransformRotate90, true); err != nil {
+		t.Fatalf("Failed to transform image: %v", err)
+	}
+	frame, _ := img.FrameHeader()
+	if frame.Width != 48 || frame.Height != 70 {
+		t.Errorf("Expected trimmed size 48x70, got %dx%d", frame.Width, frame.Height)
+	}
+}
@@ -0,0 +1,207 @@
+package jpeg_parser
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Kind of structural problem found by `Validate`.
+type IssueKind string
+
+const (
+	IssueMissingSoi       IssueKind = "missing-soi"       // File doesn't start with SOI.
+	IssueMissingEoi       IssueKind = "missing-eoi"       // File ends before EOI.
+	IssueMissingFrame     IssueKind = "missing-frame"     // No SOFn before scan, or no SOFn at all.
+	IssueMissingScan      IssueKind = "missing-scan"      // No SOS in file.
+	IssueDuplicateFrame   IssueKind = "duplicate-frame"   // More than one SOFn.
+	IssueUnexpectedMarker IssueKind = "unexpected-marker" // Marker not allowed at its position, or reserved marker.
+	IssueUnexpectedData   IssueKind = "unexpected-data"   // Non-marker bytes between segments.
+	IssueInvalidLength    IssueKind = "invalid-length"    // Segment length smaller than 2.
+	IssueTruncatedSegment IssueKind = "truncated-segment" // Segment length overruns the file.
+	IssueTruncatedScan    IssueKind = "truncated-scan"    // Entropy-coded data ends without a marker.
+	IssueRestartSequence  IssueKind = "restart-sequence"  // RST markers out of sequence.
+)
+
+// Structural problem found in JPEG file.
+type ValidationIssue struct {
+	Kind   IssueKind
+	Offset int64  // Byte offset of the problem.
+	Marker byte   // Related marker, 0 if not applicable.
+	Detail string // Human readable description.
+}
+
+func (issue ValidationIssue) String() string {
+	return fmt.Sprintf("%s at offset %d: %s", issue.Kind, issue.Offset, issue.Detail)
+}
+
+// Structural validation report of JPEG file.
+type ValidationReport struct {
+	Issues             []ValidationIssue
+	TrailingDataOffset int64 // Offset of data after EOI, -1 if EOI is not found.
+	TrailingDataSize   int64 // Size of data after EOI.
+}
+
+// Check if no structural problem is found, trailing data is not considered a problem.
+func (r *ValidationReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// Check if report has issue of given kind.
+func (r *ValidationReport) Has(kind IssueKind) bool {
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ValidationReport) add(kind IssueKind, offset int, marker byte, format string, args ...any) {
+	r.Issues = append(r.Issues, ValidationIssue{Kind: kind, Offset: int64(offset), Marker: marker, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Validate structure of JPEG file.
+//
+// Unlike `ReadJpeg`, validation doesn't stop at the first problem when the rest of file can still be located.
+// Problems which make the rest of file unreadable, e.g. overrunning segment length, end the validation.
+func Validate(data []byte) *ValidationReport {
+
+	report := &ValidationReport{TrailingDataOffset: -1}
+
+	if len(data) < 2 || data[0] != '\xFF' || data[1] != jpegSOI {
+		report.add(IssueMissingSoi, 0, 0, "file doesn't start with SOI marker")
+		return report
+	}
+
+	seen_frame, seen_scan := false, false
+	pos := 2
+
+	for {
+		if pos >= len(data) {
+			report.add(IssueMissingEoi, pos, 0, "file ended without EOI marker")
+			break
+		}
+
+		// Expect marker.
+		if data[pos] != '\xFF' {
+			start := pos
+			for pos < len(data) && !(data[pos] == '\xFF' && pos+1 < len(data) && data[pos+1] != jpegNUL && data[pos+1] != '\xFF') {
+				pos++
+			}
+			report.add(IssueUnexpectedData, start, 0, "%d bytes of non-marker data between segments", pos-start)
+			continue
+		}
+
+		// Skip fill bytes.
+		for pos+1 < len(data) && data[pos+1] == '\xFF' {
+			pos++
+		}
+		if pos+1 >= len(data) {
+			report.add(IssueMissingEoi, pos, 0, "file ended without EOI marker")
+			break
+		}
+
+		offset, marker := pos, data[pos+1]
+		pos += 2
+
+		switch {
+		case marker == jpegEOI:
+			report.TrailingDataOffset = int64(pos)
+			report.TrailingDataSize = int64(len(data) - pos)
+			if !seen_frame {
+				report.add(IssueMissingFrame, offset, marker, "no frame header in file")
+			}
+			if !seen_scan {
+				report.add(IssueMissingScan, offset, marker, "no scan in file")
+			}
+			return report
+
+		case marker == jpegSOI, marker == jpegNUL, marker == jpegTEM, marker >= jpegRST0 && marker <= jpegRST7:
+			report.add(IssueUnexpectedMarker, offset, marker, "parameter-less marker 0x%02X outside of scan", marker)
+			continue
+		}
+
+		// Read segment length.
+		if pos+2 > len(data) {
+			report.add(IssueTruncatedSegment, offset, marker, "segment 0x%02X has no length", marker)
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos : pos+2]))
+		if length < 2 {
+			report.add(IssueInvalidLength, offset, marker, "segment 0x%02X has invalid length %d", marker, length)
+			break
+		}
+		if pos+length > len(data) {
+			report.add(IssueTruncatedSegment, offset, marker, "segment 0x%02X length %d overruns file by %d bytes", marker, length, pos+length-len(data))
+			break
+		}
+		pos += length
+
+		switch {
+		case isFrameMarker(marker):
+			if seen_frame {
+				report.add(IssueDuplicateFrame, offset, marker, "duplicate frame header 0x%02X", marker)
+			}
+			seen_frame = true
+
+		case marker == jpegSOS:
+			if !seen_frame {
+				report.add(IssueMissingFrame, offset, marker, "scan before frame header")
+			}
+			seen_scan = true
+
+			end, ok := validateScan(data, pos, report)
+			pos = end
+			if !ok {
+				report.add(IssueTruncatedScan, offset, marker, "entropy-coded data ended without marker")
+				report.add(IssueMissingEoi, pos, 0, "file ended without EOI marker")
+				return report
+			}
+
+		case marker == jpegDHT, marker == jpegDQT, marker == jpegDRI, marker == jpegDNL, marker == jpegDAC, marker == jpegEXP, marker == jpegCOM_:
+		case marker >= jpegAPP0 && marker <= jpegAPP15:
+		case marker >= 0xF0 && marker <= 0xFD: // JPEG extensions.
+
+		default:
+			report.add(IssueUnexpectedMarker, offset, marker, "reserved marker 0x%02X", marker)
+		}
+	}
+
+	return report
+}
+
+// Validate entropy-coded data starting at given position.
+//
+// Returns position of the marker ending the data, and false if data ended without marker.
+func validateScan(data []byte, pos int, report *ValidationReport) (int, bool) {
+
+	expected_restart := 0
+
+	for pos < len(data) {
+		if data[pos] != '\xFF' {
+			pos++
+			continue
+		}
+		if pos+1 >= len(data) {
+			return len(data), false
+		}
+
+		switch next := data[pos+1]; {
+		case next == jpegNUL: // Stuffed byte.
+			pos += 2
+		case next == '\xFF': // Fill byte.
+			pos++
+		case next >= jpegRST0 && next <= jpegRST7:
+			index := int(next - jpegRST0)
+			if index != expected_restart%8 {
+				report.add(IssueRestartSequence, pos, next, "expected RST%d, got RST%d", expected_restart%8, index)
+			}
+			expected_restart = index + 1
+			pos += 2
+		default:
+			return pos, true
+		}
+	}
+
+	return len(data), false
+}
@@ -0,0 +1,59 @@
+package jpeg_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestJpegValidate(t *testing.T) {
+
+	raw_bytes := createTestJpeg(t, 32, 32)
+
+	report := Validate(raw_bytes)
+	if !report.Valid() || report.TrailingDataSize != 0 || report.TrailingDataOffset != int64(len(raw_bytes)) {
+		t.Errorf("Expected valid report, got %+v", report)
+	}
+
+	// Trailing data.
+	report = Validate(append(bytes.Clone(raw_bytes), []byte("trailer")...))
+	if !report.Valid() || report.TrailingDataSize != 7 {
+		t.Errorf("Expected 7 bytes of trailing data, got %+v", report)
+	}
+
+	// Truncated entropy-coded data.
+	report = Validate(raw_bytes[:len(raw_bytes)-20])
+	if !report.Has(IssueTruncatedScan) || !report.Has(IssueMissingEoi) {
+		t.Errorf("Expected truncated scan, got %v", report.Issues)
+	}
+
+	// Missing SOI.
+	if report = Validate(raw_bytes[2:]); !report.Has(IssueMissingSoi) {
+		t.Errorf("Expected missing SOI, got %v", report.Issues)
+	}
+}
+
+func TestJpegValidateStructure(t *testing.T) {
+
+	sof := []byte{0xFF, 0xC0, 0x00, 0x0B, 0x08, 0x00, 0x08, 0x00, 0x08, 0x01, 0x01, 0x11, 0x00}
+	sos := []byte{0xFF, 0xDA, 0x00, 0x08, 0x01, 0x01, 0x00, 0x00, 0x3F, 0x00}
+
+	stream := []byte{0xFF, 0xD8}
+	stream = append(stream, sof...)
+	stream = append(stream, sof...)                      // Duplicate frame.
+	stream = append(stream, 0xFF, 0xD3)                  // RST outside of scan.
+	stream = append(stream, sos...)                      // Scan.
+	stream = append(stream, 0x12, 0xFF, 0xD0, 0x34)      // RST0.
+	stream = append(stream, 0xFF, 0xD2, 0x56)            // Gap, RST1 is missing.
+	stream = append(stream, 0xFF, 0xE1, 0x00, 0x40, 'x') // Length overruns file.
+
+	report := Validate(stream)
+	for _, kind := range []IssueKind{IssueDuplicateFrame, IssueUnexpectedMarker, IssueRestartSequence, IssueTruncatedSegment} {
+		if !report.Has(kind) {
+			t.Errorf("Expected %s issue, got %v", kind, report.Issues)
+		}
+	}
+	if report.TrailingDataOffset != -1 {
+		t.Errorf("Expected no EOI, got trailing offset %d", report.TrailingDataOffset)
+	}
+}
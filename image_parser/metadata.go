@@ -0,0 +1,67 @@
+package image_parser
+
+// Metadata bundle of an image.
+//
+// Every part is raw bytes in container-independent form, nil if not present:
+// ICC profile as a whole profile, EXIF as TIFF structure, and XMP as XML packet.
+type Metadata struct {
+	IccProfile []byte
+	Exif       []byte
+	Xmp        []byte
+}
+
+// Check if metadata bundle has no content.
+func (m Metadata) IsEmpty() bool {
+	return m.IccProfile == nil && m.Exif == nil && m.Xmp == nil
+}
+
+// Extract metadata bundle from parsed image.
+func ExtractMetadata(img ParserdImage) (Metadata, error) {
+
+	var meta Metadata
+	var err error
+
+	meta.IccProfile, err = img.ExtractIccProfile()
+	if err != nil {
+		return meta, err
+	}
+
+	meta.Exif, err = img.ExtractExif()
+	if err != nil {
+		return meta, err
+	}
+
+	meta.Xmp, err = img.ExtractXmp()
+	if err != nil {
+		return meta, err
+	}
+
+	return meta, nil
+}
+
+// Embed metadata bundle into parsed image, parts that are nil are skipped.
+func EmbedMetadata(img ParserdImage, meta Metadata) error {
+
+	if meta.IccProfile != nil {
+		err := img.EmbedIccProfile(meta.IccProfile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if meta.Exif != nil {
+		err := img.EmbedExif(meta.Exif)
+		if err != nil {
+			return err
+		}
+	}
+
+	if meta.Xmp != nil {
+		err := img.EmbedXmp(meta.Xmp)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
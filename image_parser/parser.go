@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	jpeg_parser "imagecore/image_parser/jpeg"
+	png_parser "imagecore/image_parser/png"
 	"io"
 )
 
@@ -13,6 +14,12 @@ var (
 
 type ParserdImage interface {
 	EmbedIccProfile(icc_profile []byte) error
+	ExtractIccProfile() ([]byte, error)
+	ExtractExif() ([]byte, error)
+	EmbedExif(exif_data []byte) error
+	ExtractXmp() ([]byte, error)
+	EmbedXmp(xmp_data []byte) error
+	StripMetadata(keep_icc bool)
 	ReadFrom(io.Reader) (int64, error)
 	WriteTo(io.Writer) (int64, error)
 }
@@ -27,7 +34,7 @@ func Parse(rd io.Reader) (ParserdImage, error) {
 
 	switch {
 	// JPEG
-	case bytes.Equal(buf.Bytes()[0:2], []byte{'\xFF', '\xD8'}):
+	case bytes.HasPrefix(buf.Bytes(), []byte{'\xFF', '\xD8'}):
 		parsed_jpeg := new(jpeg_parser.JpegImage)
 		_, err := parsed_jpeg.ReadFrom(buf)
 		if err != nil {
@@ -35,8 +42,13 @@ func Parse(rd io.Reader) (ParserdImage, error) {
 		}
 		return parsed_jpeg, nil
 	// PNG
-	case bytes.Equal(buf.Bytes()[0:8], []byte("\x89\x50\x4E\x47\x0D\x0A\x1A\x0A")):
-		return nil, ErrUnsupportedFileType
+	case bytes.HasPrefix(buf.Bytes(), png_parser.PNG_HEADER):
+		parsed_png := new(png_parser.PngImage)
+		_, err := parsed_png.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
+		return parsed_png, nil
 
 	default:
 		return nil, ErrUnsupportedFileType
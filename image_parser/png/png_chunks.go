@@ -0,0 +1,470 @@
+package png_parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrInvalidImageHeader = errors.New("invalid png image header")
+	ErrInvalidChunkType   = errors.New("unexpected png chunk type")
+	ErrInvalidChunkLength = errors.New("invalid png chunk length")
+	ErrInvalidChunkValue  = errors.New("invalid png chunk value")
+)
+
+// PNG color types.
+const (
+	ColorTypeGrayscale      uint8 = 0
+	ColorTypeRGB            uint8 = 2
+	ColorTypePalette        uint8 = 3
+	ColorTypeGrayscaleAlpha uint8 = 4
+	ColorTypeRGBA           uint8 = 6
+)
+
+// PNG interlace methods.
+const (
+	InterlaceNone  uint8 = 0
+	InterlaceAdam7 uint8 = 1
+)
+
+// PNG physical pixel dimension units.
+const (
+	UnitUnknown uint8 = 0
+	UnitMeter   uint8 = 1
+)
+
+// Check chunk type and data length, `length` of -1 means any length.
+func checkChunk(seg *PngGeneralSegment, segment_type string, length int) error {
+	if seg.SegmentType != segment_type || seg.Data == nil {
+		return ErrInvalidChunkType
+	}
+	if length >= 0 && len(*seg.Data) != length {
+		return ErrInvalidChunkLength
+	}
+	return nil
+}
+
+// Find first chunk with given type, nil if not found.
+func (im *PngImage) findSegment(segment_type string) *PngGeneralSegment {
+	index := slices.IndexFunc(im.Segments, func(elem PngSegment) bool {
+		_t, ok := elem.(*PngGeneralSegment)
+		return ok && _t.SegmentType == segment_type
+	})
+	if index == -1 {
+		return nil
+	}
+	return im.Segments[index].(*PngGeneralSegment)
+}
+
+// Image header (IHDR).
+type ImageHeader struct {
+	Width             uint32
+	Height            uint32
+	BitDepth          uint8
+	ColorType         uint8
+	CompressionMethod uint8 // Always 0 (deflate).
+	FilterMethod      uint8 // Always 0 (adaptive filtering).
+	InterlaceMethod   uint8 // 0 (none) or 1 (Adam7).
+}
+
+// Parse image header from IHDR chunk, the header is validated.
+func ParseImageHeader(seg *PngGeneralSegment) (*ImageHeader, error) {
+
+	if err := checkChunk(seg, "IHDR", 13); err != nil {
+		return nil, err
+	}
+
+	data := *seg.Data
+	header := &ImageHeader{
+		Width:             binary.BigEndian.Uint32(data[0:4]),
+		Height:            binary.BigEndian.Uint32(data[4:8]),
+		BitDepth:          data[8],
+		ColorType:         data[9],
+		CompressionMethod: data[10],
+		FilterMethod:      data[11],
+		InterlaceMethod:   data[12],
+	}
+
+	if err := header.Validate(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Validate header fields, including allowed bit depth of color type.
+func (h *ImageHeader) Validate() error {
+
+	// Dimensions are limited to 2^31-1.
+	if h.Width == 0 || h.Height == 0 || h.Width > math.MaxInt32 || h.Height > math.MaxInt32 {
+		return ErrInvalidImageHeader
+	}
+
+	var allowed []uint8
+	switch h.ColorType {
+	case ColorTypeGrayscale:
+		allowed = []uint8{1, 2, 4, 8, 16}
+	case ColorTypePalette:
+		allowed = []uint8{1, 2, 4, 8}
+	case ColorTypeRGB, ColorTypeGrayscaleAlpha, ColorTypeRGBA:
+		allowed = []uint8{8, 16}
+	default:
+		return ErrInvalidImageHeader
+	}
+	if !slices.Contains(allowed, h.BitDepth) {
+		return ErrInvalidImageHeader
+	}
+
+	if h.CompressionMethod != 0 || h.FilterMethod != 0 || h.InterlaceMethod > InterlaceAdam7 {
+		return ErrInvalidImageHeader
+	}
+	return nil
+}
+
+// Get number of channels of color type.
+func (h *ImageHeader) Channels() int {
+	switch h.ColorType {
+	case ColorTypeRGB:
+		return 3
+	case ColorTypeGrayscaleAlpha:
+		return 2
+	case ColorTypeRGBA:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// Check if color type has alpha channel.
+func (h *ImageHeader) HasAlpha() bool {
+	return h.ColorType == ColorTypeGrayscaleAlpha || h.ColorType == ColorTypeRGBA
+}
+
+// Encode image header to IHDR chunk data.
+func (h *ImageHeader) Bytes() []byte {
+	data := make([]byte, 13)
+	binary.BigEndian.PutUint32(data[0:4], h.Width)
+	binary.BigEndian.PutUint32(data[4:8], h.Height)
+	data[8] = h.BitDepth
+	data[9] = h.ColorType
+	data[10] = h.CompressionMethod
+	data[11] = h.FilterMethod
+	data[12] = h.InterlaceMethod
+	return data
+}
+
+// Palette entry.
+type PaletteEntry struct {
+	R, G, B uint8
+}
+
+// Palette (PLTE).
+type Palette []PaletteEntry
+
+// Parse palette from PLTE chunk.
+func ParsePalette(seg *PngGeneralSegment) (Palette, error) {
+
+	if err := checkChunk(seg, "PLTE", -1); err != nil {
+		return nil, err
+	}
+
+	data := *seg.Data
+	if len(data) == 0 || len(data)%3 != 0 || len(data) > 256*3 {
+		return nil, ErrInvalidChunkLength
+	}
+
+	palette := make(Palette, len(data)/3)
+	for i := range palette {
+		palette[i] = PaletteEntry{R: data[i*3], G: data[i*3+1], B: data[i*3+2]}
+	}
+	return palette, nil
+}
+
+// Encode palette to PLTE chunk data.
+func (p Palette) Bytes() []byte {
+	data := make([]byte, 0, len(p)*3)
+	for _, entry := range p {
+		data = append(data, entry.R, entry.G, entry.B)
+	}
+	return data
+}
+
+// Transparency (tRNS), fields used depend on color type.
+type Transparency struct {
+	Gray             uint16  // Transparent gray level, grayscale only.
+	Red, Green, Blue uint16  // Transparent color, RGB only.
+	Alpha            []uint8 // Alpha of palette entries, palette only.
+}
+
+// Parse transparency from tRNS chunk of image with given color type.
+func ParseTransparency(seg *PngGeneralSegment, color_type uint8) (*Transparency, error) {
+
+	if err := checkChunk(seg, "tRNS", -1); err != nil {
+		return nil, err
+	}
+
+	data := *seg.Data
+	switch color_type {
+	case ColorTypeGrayscale:
+		if len(data) != 2 {
+			return nil, ErrInvalidChunkLength
+		}
+		return &Transparency{Gray: binary.BigEndian.Uint16(data)}, nil
+	case ColorTypeRGB:
+		if len(data) != 6 {
+			return nil, ErrInvalidChunkLength
+		}
+		return &Transparency{
+			Red:   binary.BigEndian.Uint16(data[0:2]),
+			Green: binary.BigEndian.Uint16(data[2:4]),
+			Blue:  binary.BigEndian.Uint16(data[4:6]),
+		}, nil
+	case ColorTypePalette:
+		if len(data) > 256 {
+			return nil, ErrInvalidChunkLength
+		}
+		return &Transparency{Alpha: append([]uint8{}, data...)}, nil
+	default: // Color types with alpha channel can't have tRNS.
+		return nil, ErrInvalidChunkType
+	}
+}
+
+// Encode transparency to tRNS chunk data for given color type.
+func (t *Transparency) Bytes(color_type uint8) []byte {
+	switch color_type {
+	case ColorTypeGrayscale:
+		return binary.BigEndian.AppendUint16(nil, t.Gray)
+	case ColorTypeRGB:
+		data := binary.BigEndian.AppendUint16(nil, t.Red)
+		data = binary.BigEndian.AppendUint16(data, t.Green)
+		return binary.BigEndian.AppendUint16(data, t.Blue)
+	default:
+		return append([]byte{}, t.Alpha...)
+	}
+}
+
+// Parse image gamma from gAMA chunk.
+func ParseGamma(seg *PngGeneralSegment) (float64, error) {
+	if err := checkChunk(seg, "gAMA", 4); err != nil {
+		return 0, err
+	}
+	gamma := binary.BigEndian.Uint32(*seg.Data)
+	if gamma == 0 {
+		return 0, ErrInvalidChunkValue
+	}
+	return float64(gamma) / 100000, nil
+}
+
+// Encode image gamma to gAMA chunk data.
+func GammaBytes(gamma float64) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(math.Round(gamma*100000)))
+}
+
+// Primary chromaticities and white point (cHRM), as CIE 1931 x, y.
+type Chromaticities struct {
+	WhiteX, WhiteY float64
+	RedX, RedY     float64
+	GreenX, GreenY float64
+	BlueX, BlueY   float64
+}
+
+// Parse chromaticities from cHRM chunk.
+func ParseChromaticities(seg *PngGeneralSegment) (*Chromaticities, error) {
+
+	if err := checkChunk(seg, "cHRM", 32); err != nil {
+		return nil, err
+	}
+
+	data := *seg.Data
+	values := make([]float64, 8)
+	for i := range values {
+		values[i] = float64(binary.BigEndian.Uint32(data[i*4:])) / 100000
+	}
+	return &Chromaticities{
+		WhiteX: values[0], WhiteY: values[1],
+		RedX: values[2], RedY: values[3],
+		GreenX: values[4], GreenY: values[5],
+		BlueX: values[6], BlueY: values[7],
+	}, nil
+}
+
+// Encode chromaticities to cHRM chunk data.
+func (c *Chromaticities) Bytes() []byte {
+	data := make([]byte, 0, 32)
+	for _, v := range []float64{c.WhiteX, c.WhiteY, c.RedX, c.RedY, c.GreenX, c.GreenY, c.BlueX, c.BlueY} {
+		data = binary.BigEndian.AppendUint32(data, uint32(math.Round(v*100000)))
+	}
+	return data
+}
+
+// Parse rendering intent (0~3) from sRGB chunk.
+func ParseSrgbIntent(seg *PngGeneralSegment) (uint8, error) {
+	if err := checkChunk(seg, "sRGB", 1); err != nil {
+		return 0, err
+	}
+	intent := (*seg.Data)[0]
+	if intent > 3 {
+		return 0, ErrInvalidChunkValue
+	}
+	return intent, nil
+}
+
+// Physical pixel dimensions (pHYs).
+type PhysicalDimensions struct {
+	X    uint32 // Pixels per unit, X axis.
+	Y    uint32 // Pixels per unit, Y axis.
+	Unit uint8  // `UnitUnknown` (aspect ratio only) or `UnitMeter`.
+}
+
+// Parse physical pixel dimensions from pHYs chunk.
+func ParsePhysicalDimensions(seg *PngGeneralSegment) (*PhysicalDimensions, error) {
+	if err := checkChunk(seg, "pHYs", 9); err != nil {
+		return nil, err
+	}
+	data := *seg.Data
+	dims := &PhysicalDimensions{
+		X:    binary.BigEndian.Uint32(data[0:4]),
+		Y:    binary.BigEndian.Uint32(data[4:8]),
+		Unit: data[8],
+	}
+	if dims.Unit > UnitMeter {
+		return nil, ErrInvalidChunkValue
+	}
+	return dims, nil
+}
+
+// Get resolution in dots per inch, false if unit is unknown.
+func (p *PhysicalDimensions) DPI() (float64, float64, bool) {
+	if p.Unit != UnitMeter {
+		return 0, 0, false
+	}
+	return float64(p.X) * 0.0254, float64(p.Y) * 0.0254, true
+}
+
+// Encode physical pixel dimensions to pHYs chunk data.
+func (p *PhysicalDimensions) Bytes() []byte {
+	data := binary.BigEndian.AppendUint32(nil, p.X)
+	data = binary.BigEndian.AppendUint32(data, p.Y)
+	return append(data, p.Unit)
+}
+
+// Create physical pixel dimensions from resolution in dots per inch.
+func NewPhysicalDimensionsFromDPI(x_dpi float64, y_dpi float64) *PhysicalDimensions {
+	return &PhysicalDimensions{
+		X:    uint32(math.Round(x_dpi / 0.0254)),
+		Y:    uint32(math.Round(y_dpi / 0.0254)),
+		Unit: UnitMeter,
+	}
+}
+
+// Parse last modification time (UTC) from tIME chunk.
+func ParseModificationTime(seg *PngGeneralSegment) (time.Time, error) {
+
+	if err := checkChunk(seg, "tIME", 7); err != nil {
+		return time.Time{}, err
+	}
+
+	data := *seg.Data
+	year := int(binary.BigEndian.Uint16(data[0:2]))
+	month, day, hour, minute, second := data[2], data[3], data[4], data[5], data[6]
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 60 {
+		return time.Time{}, ErrInvalidChunkValue
+	}
+	return time.Date(year, time.Month(month), int(day), int(hour), int(minute), int(second), 0, time.UTC), nil
+}
+
+// Encode last modification time to tIME chunk data, time is converted to UTC.
+func ModificationTimeBytes(t time.Time) []byte {
+	t = t.UTC()
+	data := binary.BigEndian.AppendUint16(nil, uint16(t.Year()))
+	return append(data, uint8(t.Month()), uint8(t.Day()), uint8(t.Hour()), uint8(t.Minute()), uint8(t.Second()))
+}
+
+// Get image header.
+func (im *PngImage) Header() (*ImageHeader, error) {
+	if !im.hasImageHeader() {
+		return nil, ErrMissingImageHeader
+	}
+	return ParseImageHeader(im.Segments[0].(*PngGeneralSegment))
+}
+
+// Get palette, nil if image has no PLTE chunk.
+func (im *PngImage) Palette() (Palette, error) {
+	seg := im.findSegment("PLTE")
+	if seg == nil {
+		return nil, nil
+	}
+	return ParsePalette(seg)
+}
+
+// Get transparency, nil if image has no tRNS chunk.
+func (im *PngImage) Transparency() (*Transparency, error) {
+	seg := im.findSegment("tRNS")
+	if seg == nil {
+		return nil, nil
+	}
+	header, err := im.Header()
+	if err != nil {
+		return nil, err
+	}
+	return ParseTransparency(seg, header.ColorType)
+}
+
+// Get image gamma, 0 if image has no gAMA chunk.
+func (im *PngImage) Gamma() (float64, error) {
+	seg := im.findSegment("gAMA")
+	if seg == nil {
+		return 0, nil
+	}
+	return ParseGamma(seg)
+}
+
+// Get chromaticities, nil if image has no cHRM chunk.
+func (im *PngImage) Chromaticities() (*Chromaticities, error) {
+	seg := im.findSegment("cHRM")
+	if seg == nil {
+		return nil, nil
+	}
+	return ParseChromaticities(seg)
+}
+
+// Get sRGB rendering intent, false if image has no sRGB chunk.
+func (im *PngImage) SrgbIntent() (uint8, bool, error) {
+	seg := im.findSegment("sRGB")
+	if seg == nil {
+		return 0, false, nil
+	}
+	intent, err := ParseSrgbIntent(seg)
+	return intent, err == nil, err
+}
+
+// Get physical pixel dimensions, nil if image has no pHYs chunk.
+func (im *PngImage) PhysicalDimensions() (*PhysicalDimensions, error) {
+	seg := im.findSegment("pHYs")
+	if seg == nil {
+		return nil, nil
+	}
+	return ParsePhysicalDimensions(seg)
+}
+
+// Get resolution in dots per inch, false if image has no pHYs chunk or its unit is unknown.
+func (im *PngImage) PhysicalDPI() (float64, float64, bool, error) {
+	dims, err := im.PhysicalDimensions()
+	if err != nil || dims == nil {
+		return 0, 0, false, err
+	}
+	x_dpi, y_dpi, ok := dims.DPI()
+	return x_dpi, y_dpi, ok, nil
+}
+
+// Get last modification time, zero time if image has no tIME chunk.
+func (im *PngImage) ModificationTime() (time.Time, error) {
+	seg := im.findSegment("tIME")
+	if seg == nil {
+		return time.Time{}, nil
+	}
+	return ParseModificationTime(seg)
+}
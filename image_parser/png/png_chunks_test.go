@@ -0,0 +1,144 @@
+package png_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/png"
+	"testing"
+	"time"
+)
+
+func TestImageHeaderValidate(t *testing.T) {
+
+	cases := []struct {
+		header ImageHeader
+		valid  bool
+	}{
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 8, ColorType: ColorTypeRGBA}, true},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 1, ColorType: ColorTypeGrayscale}, true},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 4, ColorType: ColorTypePalette, InterlaceMethod: InterlaceAdam7}, true},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 16, ColorType: ColorTypePalette}, false},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 4, ColorType: ColorTypeRGB}, false},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 8, ColorType: 5}, false},
+		{ImageHeader{Width: 0, Height: 16, BitDepth: 8, ColorType: ColorTypeRGB}, false},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 8, ColorType: ColorTypeRGB, InterlaceMethod: 2}, false},
+		{ImageHeader{Width: 16, Height: 16, BitDepth: 8, ColorType: ColorTypeRGB, FilterMethod: 1}, false},
+	}
+
+	for i, c := range cases {
+		err := c.header.Validate()
+		if (err == nil) != c.valid {
+			t.Errorf("Case %d: expected valid %v, got error %v", i, c.valid, err)
+		}
+	}
+}
+
+func TestPngTypedChunks(t *testing.T) {
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(createTestPng(t)))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	header, err := img.Header()
+	if err != nil {
+		t.Fatalf("Failed to read header: %v", err)
+	}
+	if header.Width != 16 || header.Height != 16 || header.BitDepth != 8 || header.ColorType != ColorTypeRGB {
+		t.Fatalf("Unexpected header: %+v", header)
+	}
+	if header.Channels() != 3 || header.HasAlpha() {
+		t.Errorf("Unexpected channel info for color type %d", header.ColorType)
+	}
+
+	// Header round trip.
+	parsed, err := ParseImageHeader(NewGeneralSegment("IHDR", header.Bytes()))
+	if err != nil || *parsed != *header {
+		t.Errorf("Header round trip mismatch: %+v, %v", parsed, err)
+	}
+
+	// Absent ancillary chunks.
+	x_dpi, y_dpi, ok, err := img.PhysicalDPI()
+	if ok || err != nil {
+		t.Errorf("Expected no DPI, got %v, %v, %v", x_dpi, y_dpi, err)
+	}
+	if transparency, err := img.Transparency(); transparency != nil || err != nil {
+		t.Errorf("Expected no transparency, got %+v, %v", transparency, err)
+	}
+
+	modified := time.Date(2024, time.March, 5, 6, 7, 8, 0, time.UTC)
+	chromaticities := &Chromaticities{
+		WhiteX: 0.3127, WhiteY: 0.329,
+		RedX: 0.64, RedY: 0.33,
+		GreenX: 0.3, GreenY: 0.6,
+		BlueX: 0.15, BlueY: 0.06,
+	}
+	img.InsertSegmentBefore(NewGeneralSegment("pHYs", NewPhysicalDimensionsFromDPI(300, 150).Bytes()), "IDAT")
+	img.InsertSegmentBefore(NewGeneralSegment("gAMA", GammaBytes(1/2.2)), "IDAT")
+	img.InsertSegmentBefore(NewGeneralSegment("cHRM", chromaticities.Bytes()), "IDAT")
+	img.InsertSegmentBefore(NewGeneralSegment("sRGB", []byte{1}), "IDAT")
+	img.InsertSegmentBefore(NewGeneralSegment("tRNS", (&Transparency{Red: 1, Green: 2, Blue: 3}).Bytes(ColorTypeRGB)), "IDAT")
+	img.InsertSegmentBefore(NewGeneralSegment("tIME", ModificationTimeBytes(modified)), "IEND")
+
+	// Chunks should survive serialization.
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := img.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	img = new(PngImage)
+	if _, err := img.ReadFrom(buf); err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	x_dpi, y_dpi, ok, err = img.PhysicalDPI()
+	if !ok || err != nil || x_dpi < 299.9 || x_dpi > 300.1 || y_dpi < 149.9 || y_dpi > 150.1 {
+		t.Errorf("Unexpected DPI: %v, %v, %v, %v", x_dpi, y_dpi, ok, err)
+	}
+
+	gamma, err := img.Gamma()
+	if err != nil || gamma != 0.45455 {
+		t.Errorf("Unexpected gamma: %v, %v", gamma, err)
+	}
+
+	parsed_chromaticities, err := img.Chromaticities()
+	if err != nil || *parsed_chromaticities != *chromaticities {
+		t.Errorf("Unexpected chromaticities: %+v, %v", parsed_chromaticities, err)
+	}
+
+	intent, ok, err := img.SrgbIntent()
+	if !ok || err != nil || intent != 1 {
+		t.Errorf("Unexpected sRGB intent: %v, %v, %v", intent, ok, err)
+	}
+
+	transparency, err := img.Transparency()
+	if err != nil || transparency.Red != 1 || transparency.Green != 2 || transparency.Blue != 3 {
+		t.Errorf("Unexpected transparency: %+v, %v", transparency, err)
+	}
+
+	parsed_time, err := img.ModificationTime()
+	if err != nil || !parsed_time.Equal(modified) {
+		t.Errorf("Unexpected modification time: %v, %v", parsed_time, err)
+	}
+}
+
+func TestPngPalette(t *testing.T) {
+
+	palette := Palette{{R: 255}, {G: 255}, {B: 255}}
+	parsed, err := ParsePalette(NewGeneralSegment("PLTE", palette.Bytes()))
+	if err != nil || len(parsed) != 3 || parsed[1] != palette[1] {
+		t.Errorf("Palette round trip mismatch: %v, %v", parsed, err)
+	}
+
+	if _, err := ParsePalette(NewGeneralSegment("PLTE", []byte{1, 2})); err != ErrInvalidChunkLength {
+		t.Errorf("Expected ErrInvalidChunkLength, got %v", err)
+	}
+
+	transparency, err := ParseTransparency(NewGeneralSegment("tRNS", []byte{0, 128}), ColorTypePalette)
+	if err != nil || len(transparency.Alpha) != 2 || transparency.Alpha[1] != 128 {
+		t.Errorf("Unexpected palette transparency: %+v, %v", transparency, err)
+	}
+
+	if _, err := ParseTransparency(NewGeneralSegment("tRNS", []byte{0, 128}), ColorTypeRGBA); err == nil {
+		t.Errorf("Expected error for tRNS on color type with alpha")
+	}
+}
@@ -0,0 +1,116 @@
+package png_parser_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"image/png"
+	. "imagecore/image_parser/png"
+	"io"
+	"testing"
+)
+
+// Create a small PNG image in memory.
+func createTestPng(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x * 16), G: uint8(y * 16), B: 128, A: 255})
+		}
+	}
+	buf := bytes.NewBuffer([]byte{})
+	err := png.Encode(buf, img)
+	if err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPngEmbedIcc(t *testing.T) {
+
+	raw_bytes := createTestPng(t)
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(raw_bytes))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	// Embed twice, the second profile should replace the first one.
+	test_icc_profile := []byte("1234567890abcdef")
+	err = img.EmbedIccProfile([]byte("placeholder"))
+	if err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+	err = img.EmbedIccProfile(test_icc_profile)
+	if err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+
+	iccp_count := 0
+	for pos, x := range img.Segments {
+		seg := x.(*PngGeneralSegment)
+		switch seg.SegmentType {
+		case "iCCP":
+			iccp_count++
+			if pos != 1 {
+				t.Errorf("Expected iCCP right after IHDR, got position %d", pos)
+			}
+
+			// Check chunk content.
+			data := *seg.Data
+			sep := bytes.IndexByte(data, '\x00')
+			if sep < 1 || data[sep+1] != 0 {
+				t.Fatalf("Invalid iCCP header: %v", data)
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(data[sep+2:]))
+			if err != nil {
+				t.Fatalf("Failed to decompress profile: %v", err)
+			}
+			profile, _ := io.ReadAll(zr)
+			if !bytes.Equal(profile, test_icc_profile) {
+				t.Errorf("Profile mismatch, got: %v", profile)
+			}
+		}
+	}
+	if iccp_count != 1 {
+		t.Errorf("Expected 1 iCCP chunk, got %d", iccp_count)
+	}
+
+	// Output should still be a valid PNG.
+	buf := bytes.NewBuffer([]byte{})
+	_, err = img.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	_, err = png.Decode(buf)
+	if err != nil {
+		t.Errorf("Failed to decode output image: %v", err)
+	}
+}
+
+func TestPngExtractOversizedIcc(t *testing.T) {
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(createTestPng(t)))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	// Highly compressible profile above 16MB.
+	if err := img.EmbedIccProfile(make([]byte, 16<<20+1)); err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+	if _, err := img.ExtractIccProfile(); err != ErrIccProfileTooLarge {
+		t.Errorf("Expected ErrIccProfileTooLarge, got %v", err)
+	}
+
+	// Profile at the limit is still accepted.
+	if err := img.EmbedIccProfile(make([]byte, 16<<20)); err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+	if profile, err := img.ExtractIccProfile(); err != nil || len(profile) != 16<<20 {
+		t.Errorf("Expected 16MB profile, got %d bytes, error %v", len(profile), err)
+	}
+}
@@ -0,0 +1,474 @@
+package png_parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"golang.org/x/exp/slices"
+)
+
+// Row filter selection strategy.
+//
+// Strategies other than `FilterAdaptive` use the same filter type for every row.
+type FilterStrategy int
+
+const (
+	FilterNone     FilterStrategy = 0
+	FilterSub      FilterStrategy = 1
+	FilterUp       FilterStrategy = 2
+	FilterAverage  FilterStrategy = 3
+	FilterPaeth    FilterStrategy = 4
+	FilterAdaptive FilterStrategy = 5 // Per row, filter with minimum sum of absolute differences.
+)
+
+// Strategies and zlib levels tried by `Optimize`.
+var (
+	optimizeFilters = []FilterStrategy{FilterNone, FilterSub, FilterUp, FilterAverage, FilterPaeth, FilterAdaptive}
+	optimizeLevels  = []int{zlib.DefaultCompression, zlib.BestCompression}
+)
+
+// Ancillary chunks depending on color type or palette, which are dropped when image data is rewritten.
+var colorDependentChunks = []string{"bKGD", "sBIT", "hIST", "tRNS"}
+
+// Result of `Optimize`.
+type OptimizeResult struct {
+	Changed          bool // False if no candidate is smaller than the original image.
+	ColorType        uint8
+	BitDepth         uint8
+	Filter           FilterStrategy
+	CompressionLevel int
+	OriginalSize     int64
+	OptimizedSize    int64
+}
+
+// Pixel properties used to choose color type.
+type pixelStats struct {
+	opaque    bool                // All pixels are fully opaque.
+	gray      bool                // All pixels have R == G == B.
+	fits8     bool                // All samples are representable in 8 bits.
+	grayDepth uint8               // Minimal bit depth of gray samples, valid if gray and fits8.
+	colors    []color.NRGBA       // Distinct colors in first-seen order, nil if more than 256 or not fits8.
+	index     map[color.NRGBA]int // Palette index of each color.
+}
+
+// Get stored non-premultiplied samples of pixel, scaled to 16 bits.
+//
+// Converting through `color.NRGBA64Model` goes via premultiplied color, which alters samples of semi-transparent pixels,
+// so samples are read from the concrete image types returned by the PNG decoder.
+func storedColor(pixels image.Image, x int, y int) color.NRGBA64 {
+
+	expand := func(c color.NRGBA) color.NRGBA64 {
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101, B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	}
+
+	switch img := pixels.(type) {
+	case *image.NRGBA:
+		return expand(img.NRGBAAt(x, y))
+	case *image.NRGBA64:
+		return img.NRGBA64At(x, y)
+	case *image.Gray:
+		v := uint16(img.GrayAt(x, y).Y) * 0x101
+		return color.NRGBA64{R: v, G: v, B: v, A: 0xFFFF}
+	case *image.Gray16:
+		v := img.Gray16At(x, y).Y
+		return color.NRGBA64{R: v, G: v, B: v, A: 0xFFFF}
+	case *image.Paletted:
+		switch c := img.Palette[img.ColorIndexAt(x, y)].(type) {
+		case color.NRGBA:
+			return expand(c)
+		case color.RGBA:
+			if c.A == 0xFF {
+				return expand(color.NRGBA(c))
+			}
+		}
+	case *image.RGBA:
+		// Decoder uses premultiplied types for opaque images only.
+		if c := img.RGBAAt(x, y); c.A == 0xFF {
+			return expand(color.NRGBA(c))
+		}
+	case *image.RGBA64:
+		if c := img.RGBA64At(x, y); c.A == 0xFFFF {
+			return color.NRGBA64(c)
+		}
+	}
+	return color.NRGBA64Model.Convert(pixels.At(x, y)).(color.NRGBA64)
+}
+
+// Collect pixel properties.
+func collectStats(pixels image.Image) *pixelStats {
+
+	stats := &pixelStats{opaque: true, gray: true, fits8: true, index: map[color.NRGBA]int{}}
+	gray_values := map[uint8]bool{}
+
+	bounds := pixels.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := storedColor(pixels, x, y)
+			stats.opaque = stats.opaque && c.A == 0xFFFF
+			stats.gray = stats.gray && c.R == c.G && c.G == c.B
+			for _, v := range []uint16{c.R, c.G, c.B, c.A} {
+				stats.fits8 = stats.fits8 && v>>8 == v&0xFF
+			}
+			if !stats.fits8 {
+				continue
+			}
+
+			gray_values[uint8(c.R)] = true
+			c8 := color.NRGBA{R: uint8(c.R), G: uint8(c.G), B: uint8(c.B), A: uint8(c.A)}
+			if _, ok := stats.index[c8]; !ok && len(stats.index) <= 256 {
+				stats.index[c8] = len(stats.index)
+			}
+		}
+	}
+
+	// Minimal depth which represents every gray level exactly.
+	stats.grayDepth = 8
+	for _, depth := range []uint8{4, 2, 1} {
+		levels := (1 << depth) - 1
+		exact := true
+		for v := range gray_values {
+			exact = exact && int(v)*levels%255 == 0
+		}
+		if exact {
+			stats.grayDepth = depth
+		}
+	}
+
+	if stats.fits8 && len(stats.index) <= 256 {
+		stats.colors = make([]color.NRGBA, len(stats.index))
+		for c, i := range stats.index {
+			stats.colors[i] = c
+		}
+		// Transparent entries go first, so tRNS can be truncated.
+		slices.SortStableFunc(stats.colors, func(a, b color.NRGBA) int {
+			return btoi(a.A == 0xFF) - btoi(b.A == 0xFF)
+		})
+		for i, c := range stats.colors {
+			stats.index[c] = i
+		}
+	}
+	return stats
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Get candidate color types and bit depths of image.
+//
+// With ICC profile, the gray/color family of the original color type is kept, since the profile color space must match.
+func candidateHeaders(stats *pixelStats, original *ImageHeader, has_icc bool) []ImageHeader {
+
+	gray := stats.gray
+	allow_palette := stats.colors != nil
+	if has_icc {
+		gray = original.ColorType == ColorTypeGrayscale || original.ColorType == ColorTypeGrayscaleAlpha
+		allow_palette = allow_palette && !gray
+	}
+
+	depth := uint8(16)
+	if stats.fits8 {
+		depth = 8
+	}
+
+	var candidates []ImageHeader
+	add := func(color_type uint8, bit_depth uint8) {
+		candidates = append(candidates, ImageHeader{Width: original.Width, Height: original.Height, ColorType: color_type, BitDepth: bit_depth})
+	}
+
+	switch {
+	case gray && stats.opaque && stats.fits8:
+		add(ColorTypeGrayscale, stats.grayDepth)
+	case gray && stats.opaque:
+		add(ColorTypeGrayscale, depth)
+	case gray:
+		add(ColorTypeGrayscaleAlpha, depth)
+	case stats.opaque:
+		add(ColorTypeRGB, depth)
+	default:
+		add(ColorTypeRGBA, depth)
+	}
+
+	if allow_palette {
+		palette_depth := uint8(8)
+		for _, d := range []uint8{4, 2, 1} {
+			if len(stats.colors) <= 1<<d {
+				palette_depth = d
+			}
+		}
+		add(ColorTypePalette, palette_depth)
+	}
+	return candidates
+}
+
+// Pack pixels into unfiltered scanlines of given header.
+func packRows(pixels image.Image, header *ImageHeader, stats *pixelStats) [][]byte {
+
+	bounds := pixels.Bounds()
+	bits_per_pixel := header.Channels() * int(header.BitDepth)
+	rows := make([][]byte, bounds.Dy())
+
+	for y := range rows {
+		row := make([]byte, (bits_per_pixel*bounds.Dx()+7)/8)
+		bit_pos := 0
+
+		// Write a sample of given bit depth, most significant bit first.
+		put := func(v uint16, depth int) {
+			switch depth {
+			case 16:
+				row[bit_pos/8], row[bit_pos/8+1] = uint8(v>>8), uint8(v)
+			case 8:
+				row[bit_pos/8] = uint8(v)
+			default:
+				row[bit_pos/8] |= uint8(v) << (8 - depth - bit_pos%8)
+			}
+			bit_pos += depth
+		}
+
+		for x := 0; x < bounds.Dx(); x++ {
+			c := storedColor(pixels, bounds.Min.X+x, bounds.Min.Y+y)
+			depth := int(header.BitDepth)
+
+			// Scale 16-bit sample to bit depth.
+			sample := func(v uint16) uint16 {
+				if depth == 16 {
+					return v
+				}
+				return uint16(uint32(v>>8) * (1<<depth - 1) / 255)
+			}
+
+			switch header.ColorType {
+			case ColorTypeGrayscale:
+				put(sample(c.R), depth)
+			case ColorTypeGrayscaleAlpha:
+				put(sample(c.R), depth)
+				put(sample(c.A), depth)
+			case ColorTypeRGB:
+				put(sample(c.R), depth)
+				put(sample(c.G), depth)
+				put(sample(c.B), depth)
+			case ColorTypeRGBA:
+				put(sample(c.R), depth)
+				put(sample(c.G), depth)
+				put(sample(c.B), depth)
+				put(sample(c.A), depth)
+			case ColorTypePalette:
+				c8 := color.NRGBA{R: uint8(c.R), G: uint8(c.G), B: uint8(c.B), A: uint8(c.A)}
+				put(uint16(stats.index[c8]), depth)
+			}
+		}
+		rows[y] = row
+	}
+	return rows
+}
+
+// Paeth predictor.
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	} else if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Apply filter type to row, `prev` is the unfiltered previous row.
+func filterRow(filter_type uint8, row []byte, prev []byte, bpp int, out []byte) {
+	for i := range row {
+		var a, b, c uint8
+		if i >= bpp {
+			a, c = row[i-bpp], prev[i-bpp]
+		}
+		b = prev[i]
+
+		switch filter_type {
+		case 0:
+			out[i] = row[i]
+		case 1:
+			out[i] = row[i] - a
+		case 2:
+			out[i] = row[i] - b
+		case 3:
+			out[i] = row[i] - uint8((int(a)+int(b))/2)
+		case 4:
+			out[i] = row[i] - paeth(a, b, c)
+		}
+	}
+}
+
+// Filter scanlines with given strategy, each row is prefixed with its filter type.
+func filterRows(rows [][]byte, bpp int, strategy FilterStrategy) []byte {
+
+	buf := bytes.NewBuffer([]byte{})
+	prev := make([]byte, len(rows[0]))
+	out := make([]byte, len(rows[0]))
+	best := make([]byte, len(rows[0]))
+
+	for _, row := range rows {
+		if strategy != FilterAdaptive {
+			filterRow(uint8(strategy), row, prev, bpp, out)
+			buf.WriteByte(uint8(strategy))
+			buf.Write(out)
+		} else {
+			// Minimum sum of absolute differences, treating filtered bytes as signed.
+			best_type, best_sum := uint8(0), -1
+			for filter_type := uint8(0); filter_type <= 4; filter_type++ {
+				filterRow(filter_type, row, prev, bpp, out)
+				sum := 0
+				for _, v := range out {
+					sum += abs(int(int8(v)))
+				}
+				if best_sum == -1 || sum < best_sum {
+					best_type, best_sum = filter_type, sum
+					copy(best, out)
+				}
+			}
+			buf.WriteByte(best_type)
+			buf.Write(best)
+		}
+		prev = row
+	}
+	return buf.Bytes()
+}
+
+// Compress filtered image data with zlib.
+func compressImageData(data []byte, level int) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	zw, _ := zlib.NewWriterLevel(buf, level)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// Get encoded size of image.
+func (im *PngImage) encodedSize() int64 {
+	size, _ := im.WriteTo(io.Discard)
+	return size
+}
+
+// Recompress image data losslessly, keeping the smallest result.
+//
+// Color type and bit depth are reduced when pixels allow it, then every filter strategy and zlib level is tried.
+// The output is never interlaced. Color dependent ancillary chunks (bKGD, sBIT, hIST) and unknown chunks
+// which are not safe to copy are dropped when image data is rewritten. Image is left unchanged if no candidate is smaller.
+func (im *PngImage) Optimize() (*OptimizeResult, error) {
+
+	original, err := im.Header()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := im.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	result := &OptimizeResult{OriginalSize: int64(buf.Len()), OptimizedSize: int64(buf.Len())}
+
+	pixels, err := png.Decode(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := collectStats(pixels)
+	best_segments := im.Segments
+
+	for _, header := range candidateHeaders(stats, original, im.findSegment("iCCP") != nil) {
+		rows := packRows(pixels, &header, stats)
+		bpp := max(1, header.Channels()*int(header.BitDepth)/8)
+
+		for _, strategy := range optimizeFilters {
+			filtered := filterRows(rows, bpp, strategy)
+
+			for _, level := range optimizeLevels {
+				candidate := &PngImage{Segments: im.rewriteImageData(&header, stats, compressImageData(filtered, level))}
+				size := candidate.encodedSize()
+				if size < result.OptimizedSize {
+					best_segments = candidate.Segments
+					*result = OptimizeResult{
+						Changed:          true,
+						ColorType:        header.ColorType,
+						BitDepth:         header.BitDepth,
+						Filter:           strategy,
+						CompressionLevel: level,
+						OriginalSize:     result.OriginalSize,
+						OptimizedSize:    size,
+					}
+				}
+			}
+		}
+	}
+
+	if !result.Changed {
+		result.ColorType, result.BitDepth = original.ColorType, original.BitDepth
+		return result, nil
+	}
+
+	// Image is modified only if the result is valid, chunk order problems of the source are tolerated.
+	optimized := &PngImage{Segments: best_segments}
+	report, _ := optimized.Canonicalize()
+	if len(report.NewIssues(im.ValidateChunkOrder())) > 0 {
+		return nil, ErrInvalidChunkOrder
+	}
+
+	im.Segments = optimized.Segments
+	return result, nil
+}
+
+// Replace IHDR, PLTE, tRNS and IDAT chunks with given header and compressed image data.
+func (im *PngImage) rewriteImageData(header *ImageHeader, stats *pixelStats, compressed []byte) []PngSegment {
+
+	segments := make([]PngSegment, 0, len(im.Segments))
+	data_written := false
+	for _, elem := range im.Segments {
+		chunk_type := segmentType(elem)
+		_, known := ancillaryRegions[chunk_type]
+
+		switch {
+		case chunk_type == "IHDR":
+			segments = append(segments, NewGeneralSegment("IHDR", header.Bytes()))
+		case chunk_type == "IDAT":
+			if data_written {
+				continue // Image data is written as a single chunk.
+			}
+			data_written = true
+			if header.ColorType == ColorTypePalette {
+				palette := make(Palette, len(stats.colors))
+				alpha := []uint8{}
+				for i, c := range stats.colors {
+					palette[i] = PaletteEntry{R: c.R, G: c.G, B: c.B}
+					if c.A != 0xFF {
+						alpha = append(alpha, c.A)
+					}
+				}
+				segments = append(segments, NewGeneralSegment("PLTE", palette.Bytes()))
+				if len(alpha) > 0 {
+					segments = append(segments, NewGeneralSegment("tRNS", alpha))
+				}
+			}
+			segments = append(segments, NewGeneralSegment("IDAT", compressed))
+		case chunk_type == "PLTE" || slices.Contains(colorDependentChunks, chunk_type):
+			continue
+		case chunk_type != "" && !IsCriticalChunk(chunk_type) && !known && !IsSafeToCopyChunk(chunk_type):
+			continue
+		default:
+			segments = append(segments, elem)
+		}
+	}
+	return segments
+}
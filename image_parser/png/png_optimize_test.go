@@ -0,0 +1,211 @@
+package png_parser_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	. "imagecore/image_parser/png"
+	"math/rand"
+	"testing"
+)
+
+// Encode image with standard encoder and parse it.
+func encodeAndParse(t *testing.T, img image.Image) *PngImage {
+	buf := bytes.NewBuffer([]byte{})
+	encoder := &png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+	parsed := new(PngImage)
+	if _, err := parsed.ReadFrom(buf); err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+	return parsed
+}
+
+// Get stored samples of decoded color, without going through premultiplied color.
+func exactColor(c color.Color) color.NRGBA64 {
+	switch c := c.(type) {
+	case color.NRGBA:
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101, B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	case color.NRGBA64:
+		return c
+	}
+	return color.NRGBA64Model.Convert(c).(color.NRGBA64)
+}
+
+// Check that optimized image decodes to the same pixels.
+func checkSamePixels(t *testing.T, expected image.Image, parsed *PngImage) {
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := parsed.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	decoded, err := png.Decode(buf)
+	if err != nil {
+		t.Fatalf("Failed to decode optimized image: %v", err)
+	}
+	bounds := expected.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			a := exactColor(expected.At(x, y))
+			b := exactColor(decoded.At(x, y))
+			if a != b {
+				t.Fatalf("Pixel (%d, %d) mismatch: %v != %v", x, y, a, b)
+			}
+		}
+	}
+}
+
+func TestOptimizeGray(t *testing.T) {
+
+	// Opaque gray image with 4 levels, stored as RGBA.
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 32; y++ {
+			v := uint8((x / 8) * 85)
+			img.Set(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	parsed := encodeAndParse(t, img)
+	parsed.SetText(&TextChunk{Type: TextTypeText, Keyword: "Source", Text: "test"})
+	parsed.InsertSegmentBefore(NewGeneralSegment("bKGD", []byte{0, 0, 0, 0, 0, 0}), "IDAT")
+
+	result, err := parsed.Optimize()
+	if err != nil {
+		t.Fatalf("Failed to optimize: %v", err)
+	}
+	if !result.Changed || result.OptimizedSize >= result.OriginalSize {
+		t.Errorf("Expected smaller image, got %+v", result)
+	}
+	header, _ := parsed.Header()
+	if header.ColorType != result.ColorType || header.BitDepth != result.BitDepth || header.BitDepth > 2 {
+		t.Errorf("Expected reduced bit depth, got %+v", header)
+	}
+	checkSamePixels(t, img, parsed)
+
+	if text, _ := parsed.GetText("Source"); text == nil {
+		t.Errorf("Expected text chunk to be kept")
+	}
+	for _, elem := range parsed.Segments {
+		if elem.(*PngGeneralSegment).SegmentType == "bKGD" {
+			t.Errorf("Expected bKGD to be dropped")
+		}
+	}
+	if report := parsed.ValidateChunkOrder(); !report.Valid() {
+		t.Errorf("Expected valid chunk order, got %v", report.Issues)
+	}
+
+	// Source chunk order problems don't fail optimization.
+	duplicate := encodeAndParse(t, img)
+	duplicate.InsertSegmentBefore(NewGeneralSegment("tIME", []byte{7, 232, 1, 1, 0, 0, 0}), "IEND")
+	duplicate.InsertSegmentBefore(NewGeneralSegment("tIME", []byte{7, 232, 1, 2, 0, 0, 0}), "IEND")
+	if result, err := duplicate.Optimize(); err != nil || !result.Changed {
+		t.Errorf("Expected image with duplicate tIME to be optimized, got %+v, %v", result, err)
+	}
+
+	// Optimized image can't be made smaller.
+	result, err = parsed.Optimize()
+	if err != nil || result.Changed {
+		t.Errorf("Expected optimized image to be unchanged, got %+v, %v", result, err)
+	}
+}
+
+func TestOptimizePalette(t *testing.T) {
+
+	// Few colors with transparency, randomly placed.
+	colors := []color.NRGBA{{255, 0, 0, 255}, {0, 255, 0, 128}, {0, 0, 255, 255}, {0, 0, 0, 0}, {255, 255, 0, 255}}
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 24))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 24; y++ {
+			img.Set(x, y, colors[rng.Intn(len(colors))])
+		}
+	}
+	parsed := encodeAndParse(t, img)
+
+	result, err := parsed.Optimize()
+	if err != nil {
+		t.Fatalf("Failed to optimize: %v", err)
+	}
+	if result.ColorType != ColorTypePalette || result.BitDepth != 4 {
+		t.Errorf("Expected 4-bit palette image, got %+v", result)
+	}
+	transparency, err := parsed.Transparency()
+	if err != nil || transparency == nil || len(transparency.Alpha) != 2 {
+		t.Errorf("Expected 2 transparent palette entries, got %+v, %v", transparency, err)
+	}
+	checkSamePixels(t, img, parsed)
+}
+
+func TestOptimizeSemiTransparentPalette(t *testing.T) {
+
+	// Semi-transparent samples must be read without premultiplication.
+	colors := []color.NRGBA{{200, 100, 50, 100}, {10, 20, 30, 40}, {255, 128, 1, 254}, {90, 80, 70, 1}}
+	rng := rand.New(rand.NewSource(2))
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, colors[rng.Intn(len(colors))])
+		}
+	}
+	parsed := encodeAndParse(t, img)
+
+	result, err := parsed.Optimize()
+	if err != nil {
+		t.Fatalf("Failed to optimize: %v", err)
+	}
+	if !result.Changed || result.ColorType != ColorTypePalette || result.BitDepth != 2 {
+		t.Errorf("Expected 2-bit palette image, got %+v", result)
+	}
+	checkSamePixels(t, img, parsed)
+}
+
+func TestOptimizeDeepColor(t *testing.T) {
+
+	// 16-bit samples can't be reduced.
+	img := image.NewNRGBA64(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, color.NRGBA64{R: uint16(x * 4097), G: uint16(y*4097 + 1), B: 300, A: 0xFFFF})
+		}
+	}
+	parsed := encodeAndParse(t, img)
+
+	result, err := parsed.Optimize()
+	if err != nil {
+		t.Fatalf("Failed to optimize: %v", err)
+	}
+	if result.ColorType != ColorTypeRGB || result.BitDepth != 16 {
+		t.Errorf("Expected 16-bit RGB image, got %+v", result)
+	}
+	checkSamePixels(t, img, parsed)
+}
+
+func TestOptimizeKeepsIccColorSpace(t *testing.T) {
+
+	// Gray pixels in RGB image with ICC profile stay RGB.
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			v := uint8(x*16 + y)
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	parsed := encodeAndParse(t, img)
+	if err := parsed.EmbedIccProfile([]byte("placeholder profile")); err != nil {
+		t.Fatalf("Failed to embed profile: %v", err)
+	}
+
+	result, err := parsed.Optimize()
+	if err != nil {
+		t.Fatalf("Failed to optimize: %v", err)
+	}
+	if result.ColorType == ColorTypeGrayscale || result.ColorType == ColorTypeGrayscaleAlpha {
+		t.Errorf("Expected color image with ICC profile, got %+v", result)
+	}
+	if icc_profile, _ := parsed.ExtractIccProfile(); icc_profile == nil {
+		t.Errorf("Expected ICC profile to be kept")
+	}
+	checkSamePixels(t, img, parsed)
+}
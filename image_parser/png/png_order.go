@@ -0,0 +1,322 @@
+package png_parser
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+var (
+	ErrInvalidChunkOrder = errors.New("invalid png chunk order")
+)
+
+// Kind of chunk ordering problem found by `ValidateChunkOrder`.
+type IssueKind string
+
+const (
+	IssueMissingHeader      IssueKind = "missing-header"       // First chunk is not IHDR.
+	IssueMissingData        IssueKind = "missing-data"         // No IDAT chunk.
+	IssueMissingEnd         IssueKind = "missing-end"          // No IEND chunk.
+	IssueMissingPalette     IssueKind = "missing-palette"      // Palette image without PLTE.
+	IssueUnexpectedPalette  IssueKind = "unexpected-palette"   // PLTE in grayscale image.
+	IssueDuplicateChunk     IssueKind = "duplicate-chunk"      // Chunk allowed only once appears again.
+	IssueMisplacedChunk     IssueKind = "misplaced-chunk"      // Chunk at position not allowed by the spec.
+	IssueNonConsecutiveData IssueKind = "non-consecutive-data" // IDAT chunks separated by other chunks.
+	IssueUnknownCritical    IssueKind = "unknown-critical"     // Critical chunk not known to decoders.
+	IssueInvalidChunkType   IssueKind = "invalid-chunk-type"   // Chunk type is not 4 ASCII letters, or reserved bit is set.
+)
+
+// Chunk ordering problem found in PNG image.
+type ValidationIssue struct {
+	Kind      IssueKind
+	Index     int    // Index of the chunk in `Segments`.
+	ChunkType string // Related chunk type, empty if not applicable.
+	Detail    string // Human readable description.
+}
+
+func (issue ValidationIssue) String() string {
+	return fmt.Sprintf("%s at chunk %d: %s", issue.Kind, issue.Index, issue.Detail)
+}
+
+// Validation report of PNG image.
+type ValidationReport struct {
+	Issues []ValidationIssue
+}
+
+// Check if no problem is found.
+func (r *ValidationReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// Check if report has issue of given kind.
+func (r *ValidationReport) Has(kind IssueKind) bool {
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ValidationReport) add(kind IssueKind, index int, chunk_type string, format string, args ...any) {
+	r.Issues = append(r.Issues, ValidationIssue{Kind: kind, Index: index, ChunkType: chunk_type, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Get issues not found in `before`.
+//
+// Issues are matched by kind and chunk type, since chunk indices change when chunks are reordered.
+func (r *ValidationReport) NewIssues(before *ValidationReport) []ValidationIssue {
+
+	type issueKey struct {
+		kind      IssueKind
+		chunkType string
+	}
+	counts := map[issueKey]int{}
+	for _, issue := range before.Issues {
+		counts[issueKey{issue.Kind, issue.ChunkType}]++
+	}
+
+	issues := []ValidationIssue{}
+	for _, issue := range r.Issues {
+		key := issueKey{issue.Kind, issue.ChunkType}
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// Check if chunk type consists of 4 ASCII letters.
+func isValidChunkType(chunk_type string) bool {
+	if len(chunk_type) != 4 {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		c := chunk_type[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if chunk is critical (ancillary bit is unset).
+func IsCriticalChunk(chunk_type string) bool {
+	return len(chunk_type) == 4 && chunk_type[0]&0x20 == 0
+}
+
+// Check if chunk is safe to copy when critical chunks are modified (safe-to-copy bit is set).
+func IsSafeToCopyChunk(chunk_type string) bool {
+	return len(chunk_type) == 4 && chunk_type[3]&0x20 != 0
+}
+
+// Regions of the chunk stream, separated by critical chunks.
+const (
+	regionBeforePalette = 0 // After IHDR, before PLTE.
+	regionBeforeData    = 1 // After PLTE, before IDAT.
+	regionAfterData     = 2 // After IDAT, before IEND.
+)
+
+// Allowed regions of known ancillary chunks.
+var ancillaryRegions = map[string][2]int{
+	"cHRM": {regionBeforePalette, regionBeforePalette},
+	"gAMA": {regionBeforePalette, regionBeforePalette},
+	"iCCP": {regionBeforePalette, regionBeforePalette},
+	"sBIT": {regionBeforePalette, regionBeforePalette},
+	"sRGB": {regionBeforePalette, regionBeforePalette},
+	"cICP": {regionBeforePalette, regionBeforePalette},
+	"mDCv": {regionBeforePalette, regionBeforePalette},
+	"cLLi": {regionBeforePalette, regionBeforePalette},
+	"bKGD": {regionBeforeData, regionBeforeData},
+	"hIST": {regionBeforeData, regionBeforeData},
+	"tRNS": {regionBeforeData, regionBeforeData},
+	"eXIf": {regionBeforePalette, regionBeforeData},
+	"pHYs": {regionBeforePalette, regionBeforeData},
+	"sPLT": {regionBeforePalette, regionBeforeData},
+	"oFFs": {regionBeforePalette, regionBeforeData},
+	"pCAL": {regionBeforePalette, regionBeforeData},
+	"sCAL": {regionBeforePalette, regionBeforeData},
+	"tIME": {regionBeforePalette, regionAfterData},
+	"tEXt": {regionBeforePalette, regionAfterData},
+	"zTXt": {regionBeforePalette, regionAfterData},
+	"iTXt": {regionBeforePalette, regionAfterData},
+}
+
+// Chunks allowed only once.
+var uniqueChunks = []string{
+	"IHDR", "PLTE", "IEND",
+	"cHRM", "gAMA", "iCCP", "sBIT", "sRGB", "cICP", "mDCv", "cLLi",
+	"bKGD", "hIST", "tRNS", "eXIf", "pHYs", "oFFs", "pCAL", "sCAL", "tIME",
+}
+
+// Get chunk type of segment, empty if segment is not general segment.
+func segmentType(elem PngSegment) string {
+	seg, ok := elem.(*PngGeneralSegment)
+	if !ok {
+		return ""
+	}
+	return seg.SegmentType
+}
+
+// Merge regions before and after palette if image has no PLTE.
+func normalizeRegion(region int, has_palette bool) int {
+	if !has_palette && region == regionBeforeData {
+		return regionBeforePalette
+	}
+	return region
+}
+
+// Clamp region of ancillary chunk into its allowed regions.
+//
+// Unknown chunks stay in their region.
+func allowedRegion(chunk_type string, region int, has_palette bool) int {
+	region = normalizeRegion(region, has_palette)
+	bounds, known := ancillaryRegions[chunk_type]
+	if !known {
+		return region
+	}
+	return max(normalizeRegion(bounds[0], has_palette), min(region, normalizeRegion(bounds[1], has_palette)))
+}
+
+// Check if image has PLTE chunk.
+func (im *PngImage) hasPalette() bool {
+	return slices.IndexFunc(im.Segments, func(elem PngSegment) bool { return segmentType(elem) == "PLTE" }) != -1
+}
+
+// Validate chunk order against the PNG specification.
+func (im *PngImage) ValidateChunkOrder() *ValidationReport {
+
+	report := &ValidationReport{}
+
+	has_palette := im.hasPalette()
+	region := regionBeforePalette
+	seen := map[string]int{}
+	last_data := -1
+	end := -1
+
+	for i, elem := range im.Segments {
+		chunk_type := segmentType(elem)
+		seen[chunk_type]++
+
+		if i == 0 && chunk_type != "IHDR" {
+			report.add(IssueMissingHeader, i, chunk_type, "first chunk is %q, not IHDR", chunk_type)
+		}
+		if chunk_type == "" {
+			continue
+		}
+		if !isValidChunkType(chunk_type) || chunk_type[2]&0x20 != 0 {
+			report.add(IssueInvalidChunkType, i, chunk_type, "invalid chunk type %q", chunk_type)
+			continue
+		}
+		if seen[chunk_type] > 1 && slices.Contains(uniqueChunks, chunk_type) {
+			report.add(IssueDuplicateChunk, i, chunk_type, "duplicate %s chunk", chunk_type)
+			continue
+		}
+		if end != -1 {
+			report.add(IssueMisplacedChunk, i, chunk_type, "%s chunk after IEND", chunk_type)
+			continue
+		}
+
+		switch chunk_type {
+		case "IHDR":
+			if i != 0 {
+				report.add(IssueMisplacedChunk, i, chunk_type, "IHDR is not the first chunk")
+			}
+		case "PLTE":
+			if last_data != -1 {
+				report.add(IssueMisplacedChunk, i, chunk_type, "PLTE after IDAT")
+			}
+			region = max(region, regionBeforeData)
+		case "IDAT":
+			if last_data != -1 && last_data != i-1 {
+				report.add(IssueNonConsecutiveData, i, chunk_type, "IDAT separated from previous IDAT at chunk %d", last_data)
+			}
+			last_data = i
+			region = regionAfterData
+		case "IEND":
+			end = i
+		default:
+			if IsCriticalChunk(chunk_type) {
+				report.add(IssueUnknownCritical, i, chunk_type, "unknown critical chunk %s", chunk_type)
+			} else if allowedRegion(chunk_type, region, has_palette) != normalizeRegion(region, has_palette) {
+				report.add(IssueMisplacedChunk, i, chunk_type, "%s chunk not allowed at this position", chunk_type)
+			}
+		}
+	}
+
+	if seen["IDAT"] == 0 {
+		report.add(IssueMissingData, len(im.Segments), "IDAT", "no IDAT chunk")
+	}
+	if end == -1 {
+		report.add(IssueMissingEnd, len(im.Segments), "IEND", "no IEND chunk")
+	}
+
+	// Palette requirement depends on color type.
+	if header, err := im.Header(); err == nil {
+		switch header.ColorType {
+		case ColorTypePalette:
+			if !has_palette {
+				report.add(IssueMissingPalette, len(im.Segments), "PLTE", "palette image without PLTE chunk")
+			}
+		case ColorTypeGrayscale, ColorTypeGrayscaleAlpha:
+			if has_palette {
+				report.add(IssueUnexpectedPalette, len(im.Segments), "PLTE", "PLTE chunk in grayscale image")
+			}
+		}
+	}
+
+	return report
+}
+
+// Reorder chunks to satisfy the PNG specification, then validate the result.
+//
+// The reorder is stable, chunks are moved only when their position is not allowed.
+// Unknown ancillary chunks keep their position relative to critical chunks, as required for safe-to-copy chunks.
+// Problems that can't be fixed by reordering (e.g. duplicate or unknown critical chunks) are reported by `ErrInvalidChunkOrder`.
+func (im *PngImage) Canonicalize() (*ValidationReport, error) {
+
+	has_palette := im.hasPalette()
+
+	// Rank of chunk in canonical order.
+	ranks := make([]int, len(im.Segments))
+	region := regionBeforePalette
+	for i, elem := range im.Segments {
+		switch segmentType(elem) {
+		case "IHDR":
+			ranks[i] = 0
+		case "PLTE":
+			ranks[i] = 2
+			region = max(region, regionBeforeData)
+		case "IDAT":
+			ranks[i] = 4
+			region = regionAfterData
+		case "IEND":
+			ranks[i] = 6
+		default:
+			ranks[i] = []int{1, 3, 5}[allowedRegion(segmentType(elem), region, has_palette)]
+		}
+	}
+
+	// Stable sort by rank.
+	order := make([]int, len(im.Segments))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return ranks[a] - ranks[b]
+	})
+	segments := make([]PngSegment, len(im.Segments))
+	for i, index := range order {
+		segments[i] = im.Segments[index]
+	}
+	im.Segments = segments
+
+	report := im.ValidateChunkOrder()
+	if !report.Valid() {
+		return report, ErrInvalidChunkOrder
+	}
+	return report, nil
+}
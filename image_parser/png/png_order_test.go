@@ -0,0 +1,156 @@
+package png_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/png"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+// Create image with given chunk types and placeholder chunk data.
+func createChunkSequence(color_type uint8, chunk_types ...string) *PngImage {
+
+	header := &ImageHeader{Width: 16, Height: 16, BitDepth: 8, ColorType: color_type}
+	img := new(PngImage)
+	for _, chunk_type := range chunk_types {
+		data := []byte{0}
+		if chunk_type == "IHDR" {
+			data = header.Bytes()
+		}
+		img.Segments = append(img.Segments, NewGeneralSegment(chunk_type, data))
+	}
+	return img
+}
+
+// Get chunk types of image.
+func chunkTypes(img *PngImage) []string {
+	chunk_types := []string{}
+	for _, elem := range img.Segments {
+		chunk_types = append(chunk_types, elem.(*PngGeneralSegment).SegmentType)
+	}
+	return chunk_types
+}
+
+func TestValidateChunkOrder(t *testing.T) {
+
+	cases := []struct {
+		color_type  uint8
+		chunk_types []string
+		expected    IssueKind
+	}{
+		{ColorTypeRGB, []string{"IHDR", "gAMA", "tEXt", "IDAT", "IDAT", "tIME", "IEND"}, ""},
+		{ColorTypePalette, []string{"IHDR", "sRGB", "PLTE", "tRNS", "IDAT", "IEND"}, ""},
+		{ColorTypeRGB, []string{"IHDR", "tRNS", "IDAT", "IEND"}, ""},
+		{ColorTypeRGB, []string{"IHDR", "prVt", "IDAT", "prVt", "IEND"}, ""},
+		{ColorTypeRGB, []string{"gAMA", "IHDR", "IDAT", "IEND"}, IssueMissingHeader},
+		{ColorTypeRGB, []string{"IHDR", "IEND"}, IssueMissingData},
+		{ColorTypeRGB, []string{"IHDR", "IDAT"}, IssueMissingEnd},
+		{ColorTypePalette, []string{"IHDR", "IDAT", "IEND"}, IssueMissingPalette},
+		{ColorTypeGrayscale, []string{"IHDR", "PLTE", "IDAT", "IEND"}, IssueUnexpectedPalette},
+		{ColorTypeRGB, []string{"IHDR", "gAMA", "gAMA", "IDAT", "IEND"}, IssueDuplicateChunk},
+		{ColorTypeRGB, []string{"IHDR", "IDAT", "iCCP", "IEND"}, IssueMisplacedChunk},
+		{ColorTypePalette, []string{"IHDR", "PLTE", "gAMA", "IDAT", "IEND"}, IssueMisplacedChunk},
+		{ColorTypePalette, []string{"IHDR", "IDAT", "PLTE", "IEND"}, IssueMisplacedChunk},
+		{ColorTypeRGB, []string{"IHDR", "IDAT", "IEND", "tEXt"}, IssueMisplacedChunk},
+		{ColorTypeRGB, []string{"IHDR", "IDAT", "tEXt", "IDAT", "IEND"}, IssueNonConsecutiveData},
+		{ColorTypeRGB, []string{"IHDR", "ABCD", "IDAT", "IEND"}, IssueUnknownCritical},
+		{ColorTypeRGB, []string{"IHDR", "abcd", "IDAT", "IEND"}, IssueInvalidChunkType},
+	}
+
+	for i, c := range cases {
+		report := createChunkSequence(c.color_type, c.chunk_types...).ValidateChunkOrder()
+		if c.expected == "" && !report.Valid() {
+			t.Errorf("Case %d: expected valid, got %v", i, report.Issues)
+		} else if c.expected != "" && !report.Has(c.expected) {
+			t.Errorf("Case %d: expected %s, got %v", i, c.expected, report.Issues)
+		}
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+
+	cases := []struct {
+		color_type  uint8
+		chunk_types []string
+		expected    []string
+	}{
+		{
+			ColorTypeRGB,
+			[]string{"IHDR", "IDAT", "iCCP", "tEXt", "IDAT", "IEND", "pHYs"},
+			[]string{"IHDR", "iCCP", "pHYs", "IDAT", "IDAT", "tEXt", "IEND"},
+		},
+		{
+			ColorTypePalette,
+			[]string{"IHDR", "tRNS", "PLTE", "gAMA", "prVt", "IDAT", "IEND"},
+			[]string{"IHDR", "gAMA", "PLTE", "tRNS", "prVt", "IDAT", "IEND"},
+		},
+		{
+			// Unknown chunks keep their position relative to critical chunks.
+			ColorTypeRGB,
+			[]string{"IHDR", "prVt", "IDAT", "prVu", "tIME", "IEND"},
+			[]string{"IHDR", "prVt", "IDAT", "prVu", "tIME", "IEND"},
+		},
+	}
+
+	for i, c := range cases {
+		img := createChunkSequence(c.color_type, c.chunk_types...)
+		_, err := img.Canonicalize()
+		if err != nil {
+			t.Errorf("Case %d: expected no error, got %v", i, err)
+		}
+		if got := chunkTypes(img); !slices.Equal(got, c.expected) {
+			t.Errorf("Case %d: expected %v, got %v", i, c.expected, got)
+		}
+	}
+
+	// Duplicate chunk can't be fixed by reordering.
+	img := createChunkSequence(ColorTypeRGB, "IHDR", "IDAT", "gAMA", "gAMA", "IEND")
+	report, err := img.Canonicalize()
+	if err != ErrInvalidChunkOrder || !report.Has(IssueDuplicateChunk) {
+		t.Errorf("Expected ErrInvalidChunkOrder with duplicate chunk, got %v", err)
+	}
+}
+
+func TestCanonicalizeEncodedImage(t *testing.T) {
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(createTestPng(t)))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+	if report := img.ValidateChunkOrder(); !report.Valid() {
+		t.Fatalf("Expected encoded image to be valid, got %v", report.Issues)
+	}
+
+	// Metadata appended after IEND.
+	img.Segments = append(img.Segments, NewGeneralSegment("gAMA", GammaBytes(0.45455)))
+	if report := img.ValidateChunkOrder(); !report.Has(IssueMisplacedChunk) {
+		t.Errorf("Expected misplaced chunk, got %v", report.Issues)
+	}
+	if _, err := img.Canonicalize(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if segment_type := img.Segments[1].(*PngGeneralSegment).SegmentType; segment_type != "gAMA" {
+		t.Errorf("Expected gAMA right after IHDR, got %s", segment_type)
+	}
+}
+
+func TestNewIssues(t *testing.T) {
+
+	before := createChunkSequence(ColorTypeRGB, "IHDR", "tIME", "tIME", "PrVT", "IDAT", "IEND").ValidateChunkOrder()
+	if len(before.Issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %v", before.Issues)
+	}
+
+	// Same issues at other positions aren't new.
+	after := createChunkSequence(ColorTypeRGB, "IHDR", "PrVT", "IDAT", "tIME", "tIME", "IEND").ValidateChunkOrder()
+	if issues := after.NewIssues(before); len(issues) != 0 {
+		t.Errorf("Expected no new issues, got %v", issues)
+	}
+
+	after = createChunkSequence(ColorTypeRGB, "IHDR", "tIME", "tIME", "tIME", "PrVT", "gAMA", "gAMA", "IDAT", "IEND").ValidateChunkOrder()
+	if issues := after.NewIssues(before); len(issues) != 2 {
+		t.Errorf("Expected duplicate tIME and gAMA as new issues, got %v", issues)
+	}
+}
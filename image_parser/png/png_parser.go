@@ -5,7 +5,6 @@ import (
 	"errors"
 	"hash/crc32"
 	"io"
-	"log"
 )
 
 var (
@@ -41,7 +40,6 @@ func (seg *PngGeneralSegment) ReadFrom(reader io.Reader) (int64, error) {
 
 	length := int(binary.BigEndian.Uint32(seg_len)) // Convert bytes to int.
 	seg.Length = length                             // Set length.
-	log.Println(length)
 
 	segment_type := make([]byte, 4) // Segment type placeholder.
 	read, err = io.ReadFull(reader, segment_type)
@@ -0,0 +1,50 @@
+package png_parser
+
+import (
+	"io"
+)
+
+// Options of `ReadPngWithOptions`, zero value reads in strict mode, same as `ReadPng`.
+type ReadOptions struct {
+	// Keep chunks with bad checksum, the checksum is recomputed when the image is written.
+	RecomputeCrc bool
+	// Drop ancillary chunks with bad checksum. Critical chunks with bad checksum still fail unless `RecomputeCrc` is set.
+	SkipBadAncillary bool
+}
+
+// Chunk with bad checksum found while reading.
+type CrcIssue struct {
+	Offset    int64 // Byte offset of the chunk.
+	ChunkType string
+	Skipped   bool // Chunk is dropped from the result.
+}
+
+// Report of `ReadPngWithOptions`.
+type ReadReport struct {
+	CrcIssues          []CrcIssue
+	TrailingDataOffset int64  // Offset of data after IEND.
+	TrailingData       []byte // Data after IEND, nil if there is none.
+}
+
+// Read PNG image with given options.
+//
+// Unlike `ReadPng`, the input is read to the end, and data after IEND is returned in the report.
+func ReadPngWithOptions(r io.Reader, options ReadOptions) (*PngImage, *ReadReport, error) {
+
+	report := &ReadReport{}
+	seg_list, total_read, err := readPng(r, options, report)
+	if err != nil {
+		return nil, report, err
+	}
+
+	trailing_data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, report, err
+	}
+	report.TrailingDataOffset = total_read
+	if len(trailing_data) > 0 {
+		report.TrailingData = trailing_data
+	}
+
+	return &PngImage{Segments: seg_list}, report, nil
+}
@@ -0,0 +1,112 @@
+package png_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/png"
+	"testing"
+)
+
+// Create test image with a tEXt chunk before IEND, optionally corrupting CRC of tEXt and IHDR.
+func createCorruptedPng(t *testing.T, bad_text bool, bad_header bool) []byte {
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(createTestPng(t)))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+	err = img.SetText(&TextChunk{Type: TextTypeText, Keyword: "Comment", Text: "old tool"})
+	if err != nil {
+		t.Fatalf("Failed to set text: %v", err)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	img.WriteTo(buf)
+	raw_bytes := buf.Bytes()
+
+	// CRC is the last 4 bytes of a chunk.
+	if bad_text {
+		text_end := bytes.Index(raw_bytes, []byte("IEND")) - 4
+		raw_bytes[text_end-1] ^= 0xFF
+	}
+	if bad_header {
+		raw_bytes[8+8+13+3] ^= 0xFF
+	}
+	return raw_bytes
+}
+
+func TestReadPngStrict(t *testing.T) {
+
+	_, _, err := ReadPng(bytes.NewReader(createCorruptedPng(t, true, false)))
+	if err != ErrCrcCheckFailed {
+		t.Errorf("Expected ErrCrcCheckFailed, got %v", err)
+	}
+	_, report, err := ReadPngWithOptions(bytes.NewReader(createCorruptedPng(t, true, false)), ReadOptions{})
+	if err != ErrCrcCheckFailed || len(report.CrcIssues) != 0 {
+		t.Errorf("Expected ErrCrcCheckFailed in strict mode, got %v", err)
+	}
+}
+
+func TestReadPngRecomputeCrc(t *testing.T) {
+
+	raw_bytes := createCorruptedPng(t, true, true)
+	img, report, err := ReadPngWithOptions(bytes.NewReader(raw_bytes), ReadOptions{RecomputeCrc: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.CrcIssues) != 2 || report.CrcIssues[0].ChunkType != "IHDR" || report.CrcIssues[0].Offset != 8 ||
+		report.CrcIssues[1].ChunkType != "tEXt" || report.CrcIssues[1].Skipped {
+		t.Errorf("Unexpected CRC issues: %+v", report.CrcIssues)
+	}
+
+	// Written image should have valid checksums.
+	buf := bytes.NewBuffer([]byte{})
+	img.WriteTo(buf)
+	if _, _, err := ReadPng(buf); err != nil {
+		t.Errorf("Expected fixed image to be readable, got %v", err)
+	}
+	if text, _ := img.GetText("Comment"); text == nil || text.Text != "old tool" {
+		t.Errorf("Expected text chunk to be kept, got %+v", text)
+	}
+}
+
+func TestReadPngSkipBadAncillary(t *testing.T) {
+
+	img, report, err := ReadPngWithOptions(bytes.NewReader(createCorruptedPng(t, true, false)), ReadOptions{SkipBadAncillary: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.CrcIssues) != 1 || !report.CrcIssues[0].Skipped {
+		t.Errorf("Unexpected CRC issues: %+v", report.CrcIssues)
+	}
+	if text, _ := img.GetText("Comment"); text != nil {
+		t.Errorf("Expected text chunk to be dropped")
+	}
+	if header, err := img.Header(); err != nil || header.Width != 16 {
+		t.Errorf("Expected header to be kept, got %v", err)
+	}
+
+	// Critical chunk still fails.
+	_, _, err = ReadPngWithOptions(bytes.NewReader(createCorruptedPng(t, true, true)), ReadOptions{SkipBadAncillary: true})
+	if err != ErrCrcCheckFailed {
+		t.Errorf("Expected ErrCrcCheckFailed for critical chunk, got %v", err)
+	}
+}
+
+func TestReadPngTrailingData(t *testing.T) {
+
+	raw_bytes := createTestPng(t)
+	trailer := []byte("appended data")
+
+	_, report, err := ReadPngWithOptions(bytes.NewReader(raw_bytes), ReadOptions{})
+	if err != nil || report.TrailingData != nil || report.TrailingDataOffset != int64(len(raw_bytes)) {
+		t.Errorf("Expected no trailing data, got %+v, %v", report, err)
+	}
+
+	_, report, err = ReadPngWithOptions(bytes.NewReader(append(raw_bytes, trailer...)), ReadOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(report.TrailingData, trailer) || report.TrailingDataOffset != int64(len(raw_bytes)) {
+		t.Errorf("Unexpected trailing data: %q at %d", report.TrailingData, report.TrailingDataOffset)
+	}
+}
@@ -1,22 +1,47 @@
 package png_parser
 
 import (
+	"bufio"
 	"bytes"
+	"compress/zlib"
+	"encoding/binary"
 	"errors"
 	"io"
+
+	"golang.org/x/exp/slices"
 )
 
 var (
-	ErrSignatureMismatch = errors.New("png signature mismatch")
+	ErrSignatureMismatch  = errors.New("png signature mismatch")
+	ErrMissingImageHeader = errors.New("png image header not found")
+	ErrInvalidIccChunk    = errors.New("invalid iccp chunk")
+	ErrInvalidTextChunk   = errors.New("invalid textual chunk")
+	ErrChunkTooLarge      = errors.New("png chunk too large")
+	ErrIccProfileTooLarge = errors.New("png icc profile too large")
 )
 
+// Profile name written into iCCP chunk.
+const iccProfileName = "ICC Profile"
+
+// Maximum size of decompressed ICC profile.
+const maxIccProfileSize = 16 << 20
+
+// Keyword of iTXt chunk holding XMP packet.
+const xmpKeyword = "XML:com.adobe.xmp"
+
 var PNG_HEADER = []byte{'\x89', '\x50', '\x4E', '\x47', '\x0D', '\x0A', '\x1A', '\x0A'}
 
 type PngImage struct {
 	Segments []PngSegment
 }
 
+// Read PNG chunks up to IEND, fails on the first chunk with bad checksum.
 func ReadPng(r io.Reader) ([]PngSegment, int64, error) {
+	return readPng(r, ReadOptions{}, &ReadReport{})
+}
+
+// Read PNG chunks up to IEND, checksum errors are handled according to `options` and recorded in `report`.
+func readPng(r io.Reader, options ReadOptions, report *ReadReport) ([]PngSegment, int64, error) {
 
 	total_read := int64(0)
 
@@ -33,10 +58,21 @@ func ReadPng(r io.Reader) ([]PngSegment, int64, error) {
 	seg_list := make([]PngSegment, 0)
 
 	for {
+		offset := total_read
 		seg := new(PngGeneralSegment)
 		read, err := seg.ReadFrom(r)
 		total_read += read
-		if err != nil {
+
+		if err == ErrCrcCheckFailed {
+			skip := options.SkipBadAncillary && !IsCriticalChunk(seg.SegmentType)
+			if !skip && !options.RecomputeCrc {
+				return nil, total_read, err
+			}
+			report.CrcIssues = append(report.CrcIssues, CrcIssue{Offset: offset, ChunkType: seg.SegmentType, Skipped: skip})
+			if skip {
+				continue
+			}
+		} else if err != nil {
 			return nil, total_read, err
 		}
 
@@ -49,6 +85,85 @@ func ReadPng(r io.Reader) ([]PngSegment, int64, error) {
 	return seg_list, total_read, nil
 }
 
+// Read PNG chunks before the first IDAT chunk.
+//
+// Image data is not parsed, only a small read-ahead buffer past the header is consumed from the input.
+// Reading stops at IEND as well, if the image has no IDAT chunk.
+func ReadPngHeader(r io.Reader) ([]PngSegment, int64, error) {
+	return readPngHeader(r, nil)
+}
+
+// Read chunks of given types before the first IDAT chunk, for untrusted input.
+//
+// `max_lengths` maps chunk types to read to their maximum data length, longer chunks fail with `ErrChunkTooLarge`.
+// Other chunks are skipped without buffering, so declared chunk lengths can't exhaust memory.
+func ReadPngHeaderChunks(r io.Reader, max_lengths map[string]int) ([]PngSegment, int64, error) {
+	if max_lengths == nil {
+		max_lengths = map[string]int{}
+	}
+	return readPngHeader(r, max_lengths)
+}
+
+// Read chunks before the first IDAT chunk, every chunk is read if `max_lengths` is nil.
+func readPngHeader(r io.Reader, max_lengths map[string]int) ([]PngSegment, int64, error) {
+
+	total_read := int64(0)
+	reader := bufio.NewReader(r)
+
+	signature := make([]byte, 8)
+	read, err := io.ReadFull(reader, signature)
+	total_read += int64(read)
+	if err != nil {
+		return nil, total_read, err
+	}
+	if !bytes.Equal(signature, PNG_HEADER) {
+		return nil, total_read, ErrSignatureMismatch
+	}
+
+	seg_list := make([]PngSegment, 0)
+
+	for {
+		// Peek chunk length and type, so IDAT data is left unread.
+		chunk_head, err := reader.Peek(8)
+		if err != nil {
+			return nil, total_read, err
+		}
+		length := int64(binary.BigEndian.Uint32(chunk_head[0:4]))
+		chunk_type := string(chunk_head[4:8])
+		if chunk_type == "IDAT" {
+			return seg_list, total_read, nil
+		}
+
+		max_length, wanted := max_lengths[chunk_type]
+		switch {
+		case max_lengths != nil && !wanted:
+			// Skip length, type, data and CRC.
+			skipped, err := io.CopyN(io.Discard, reader, 8+length+4)
+			total_read += skipped
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, total_read, err
+			}
+		case max_lengths != nil && length > int64(max_length):
+			return nil, total_read, ErrChunkTooLarge
+		default:
+			seg := new(PngGeneralSegment)
+			read, err := seg.ReadFrom(reader)
+			total_read += read
+			if err != nil {
+				return nil, total_read, err
+			}
+			seg_list = append(seg_list, seg)
+		}
+
+		if chunk_type == "IEND" {
+			return seg_list, total_read, nil
+		}
+	}
+}
+
 func (img *PngImage) ReadFrom(r io.Reader) (int64, error) {
 	seg_list, total_read, err := ReadPng(r)
 	if err != nil {
@@ -63,6 +178,17 @@ func (img *PngImage) ReadFrom(r io.Reader) (int64, error) {
 
 func (img PngImage) WriteTo(wt io.Writer) (int64, error) {
 	total_written := int64(0)
+
+	// Write signature.
+	n, err := wt.Write(PNG_HEADER)
+	total_written += int64(n)
+	if err != nil {
+		return total_written, err
+	}
+	if n != len(PNG_HEADER) {
+		return total_written, io.ErrShortWrite
+	}
+
 	for _, seg := range img.Segments {
 		written, err := seg.WriteTo(wt)
 		total_written += written
@@ -82,6 +208,215 @@ func NewGeneralSegment(segment_type string, data []byte) *PngGeneralSegment {
 	return &seg
 }
 
-func (im *PngImage) EmbedIccProfile(icc_profile []byte) {
+// Remove all segments with given segment type.
+func (im *PngImage) RemoveSegments(segment_type string) {
+	im.RemoveSegmentsFunc(func(seg *PngGeneralSegment) bool {
+		return seg.SegmentType == segment_type
+	})
+}
+
+// Remove all segments matching the given function.
+func (im *PngImage) RemoveSegmentsFunc(match func(seg *PngGeneralSegment) bool) {
+	im.Segments = slices.DeleteFunc(im.Segments, func(elem PngSegment) bool {
+		_t, ok := elem.(*PngGeneralSegment)
+		return ok && match(_t)
+	})
+}
+
+// Insert segment before the first segment matching any of given segment types.
+//
+// The segment is inserted before IEND if none of the segment types exists.
+func (im *PngImage) InsertSegmentBefore(seg PngSegment, segment_types ...string) {
+
+	target_index := slices.IndexFunc(im.Segments, func(elem PngSegment) bool {
+		_t, ok := elem.(*PngGeneralSegment)
+		if !ok {
+			return false // Unknown segment type, continue search.
+		}
+		return slices.Contains(segment_types, _t.SegmentType) || _t.SegmentType == "IEND"
+	})
+	if target_index == -1 { // No IEND, append to the end.
+		target_index = len(im.Segments)
+	}
+
+	im.Segments = slices.Insert(im.Segments, target_index, seg)
+}
+
+// Check if the first chunk is IHDR.
+func (im *PngImage) hasImageHeader() bool {
+	if len(im.Segments) == 0 {
+		return false
+	}
+	ihdr, ok := im.Segments[0].(*PngGeneralSegment)
+	return ok && ihdr.SegmentType == "IHDR"
+}
+
+// Embed ICC profile into image.
+//
+// The profile is compressed into an iCCP chunk, which is placed before PLTE and IDAT.
+// Any existing iCCP or sRGB chunk is removed, since they are mutually exclusive.
+func (im *PngImage) EmbedIccProfile(icc_profile []byte) error {
+
+	// IHDR should be the first chunk.
+	if !im.hasImageHeader() {
+		return ErrMissingImageHeader
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+
+	buf.WriteString(iccProfileName) // Profile name.
+	buf.WriteByte('\x00')           // Null separator.
+	buf.WriteByte('\x00')           // Compression method, 0 is the only method (zlib).
+
+	// Compress profile.
+	zw := zlib.NewWriter(buf)
+	_, err := zw.Write(icc_profile)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+
+	// Remove existing colour space chunks.
+	im.RemoveSegments("iCCP")
+	im.RemoveSegments("sRGB")
+
+	// Insert new iCCP chunk.
+	im.InsertSegmentBefore(NewGeneralSegment("iCCP", buf.Bytes()), "PLTE", "IDAT")
+	return nil
+}
+
+// Extract embedded ICC profile from image.
+//
+// Returns nil if image has no iCCP chunk.
+func (im *PngImage) ExtractIccProfile() ([]byte, error) {
+
+	target_index := slices.IndexFunc(im.Segments, func(elem PngSegment) bool {
+		_t, ok := elem.(*PngGeneralSegment)
+		return ok && _t.SegmentType == "iCCP"
+	})
+	if target_index == -1 { // No profile.
+		return nil, nil
+	}
+
+	data := *im.Segments[target_index].(*PngGeneralSegment).Data
+
+	// Skip profile name.
+	sep := bytes.IndexByte(data, '\x00')
+	if sep < 1 || sep+2 > len(data) {
+		return nil, ErrInvalidIccChunk
+	}
+
+	// Check compression method.
+	if data[sep+1] != 0 {
+		return nil, ErrInvalidIccChunk
+	}
+
+	// Decompress profile.
+	zr, err := zlib.NewReader(bytes.NewReader(data[sep+2:]))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// Read one more byte to detect oversized profile.
+	profile, err := io.ReadAll(io.LimitReader(zr, maxIccProfileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(profile) > maxIccProfileSize {
+		return nil, ErrIccProfileTooLarge
+	}
+	return profile, nil
+}
+
+// Extract EXIF data (TIFF structure) from eXIf chunk.
+//
+// Returns nil if image has no eXIf chunk.
+func (im *PngImage) ExtractExif() ([]byte, error) {
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*PngGeneralSegment)
+		if ok && seg.SegmentType == "eXIf" {
+			return *seg.Data, nil
+		}
+	}
+	return nil, nil
+}
+
+// Embed EXIF data (TIFF structure) as eXIf chunk.
+//
+// Existing eXIf chunk is replaced. The chunk is placed before IDAT.
+func (im *PngImage) EmbedExif(exif_data []byte) error {
+
+	// IHDR should be the first chunk.
+	if !im.hasImageHeader() {
+		return ErrMissingImageHeader
+	}
+
+	data := make([]byte, len(exif_data))
+	copy(data, exif_data)
+
+	im.RemoveSegments("eXIf")
+	im.InsertSegmentBefore(NewGeneralSegment("eXIf", data), "IDAT")
+	return nil
+}
+
+// Remove metadata chunks from image.
+//
+// Textual chunks (tEXt, zTXt, iTXt), EXIF (eXIf) and modification time (tIME) are removed.
+// ICC profile (iCCP) is kept if `keep_icc` is set.
+func (im *PngImage) StripMetadata(keep_icc bool) {
+	for _, segment_type := range []string{"tEXt", "zTXt", "iTXt", "eXIf", "tIME"} {
+		im.RemoveSegments(segment_type)
+	}
+	if !keep_icc {
+		im.RemoveSegments("iCCP")
+	}
+}
+
+// Check if segment is an iTXt chunk holding XMP packet.
+func isXmpSegment(seg *PngGeneralSegment) bool {
+	return seg.SegmentType == TextTypeInternationalTxt && isTextSegmentWithKeyword(seg, xmpKeyword)
+}
+
+// Extract XMP packet from iTXt chunk.
+//
+// Returns nil if image has no XMP chunk.
+func (im *PngImage) ExtractXmp() ([]byte, error) {
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*PngGeneralSegment)
+		if !ok || !isXmpSegment(seg) {
+			continue
+		}
+
+		chunk, err := ParseTextChunk(seg)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(chunk.Text), nil
+	}
+	return nil, nil
+}
+
+// Embed XMP packet as uncompressed iTXt chunk.
+//
+// Existing XMP chunk is replaced. The chunk is placed before IDAT.
+func (im *PngImage) EmbedXmp(xmp_data []byte) error {
+
+	// IHDR should be the first chunk.
+	if !im.hasImageHeader() {
+		return ErrMissingImageHeader
+	}
+
+	chunk := &TextChunk{Type: TextTypeInternationalTxt, Keyword: xmpKeyword, Text: string(xmp_data)}
+	seg, err := chunk.Segment()
+	if err != nil {
+		return err
+	}
 
+	im.RemoveSegmentsFunc(isXmpSegment)
+	im.InsertSegmentBefore(seg, "IDAT")
+	return nil
 }
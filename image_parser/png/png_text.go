@@ -0,0 +1,322 @@
+package png_parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidKeyword     = errors.New("invalid png text keyword")
+	ErrInvalidTextType    = errors.New("invalid png text chunk type")
+	ErrInvalidLanguageTag = errors.New("invalid png text language tag")
+	ErrTextTooLarge       = errors.New("png text too large")
+)
+
+// Maximum size of decompressed text.
+const maxTextSize = 8 << 20
+
+// Textual chunk types.
+const (
+	TextTypeText             = "tEXt" // Uncompressed Latin-1 text.
+	TextTypeCompressedText   = "zTXt" // Compressed Latin-1 text.
+	TextTypeInternationalTxt = "iTXt" // Optionally compressed UTF-8 text.
+)
+
+// Check if chunk type is textual chunk.
+func isTextType(segment_type string) bool {
+	return segment_type == TextTypeText || segment_type == TextTypeCompressedText || segment_type == TextTypeInternationalTxt
+}
+
+// Textual chunk (tEXt, zTXt or iTXt).
+//
+// Keyword is stored as Go string, and converted from/to Latin-1 when parsing/encoding.
+// Same applies to text of tEXt and zTXt chunks.
+type TextChunk struct {
+	Type              string // `TextTypeText`, `TextTypeCompressedText` or `TextTypeInternationalTxt`.
+	Keyword           string // 1~79 Latin-1 characters.
+	Text              string
+	Compressed        bool   // Always set for zTXt, ignored for tEXt.
+	LanguageTag       string // iTXt only, e.g. "en-US".
+	TranslatedKeyword string // iTXt only, UTF-8.
+}
+
+// Decode Latin-1 bytes to string.
+func latin1ToString(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// Encode string to Latin-1 bytes, fails if string contains characters outside Latin-1.
+func stringToLatin1(s string) ([]byte, bool) {
+	data := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			return nil, false
+		}
+		data = append(data, byte(r))
+	}
+	return data, true
+}
+
+// Validate text keyword.
+//
+// Keyword must be 1~79 printable Latin-1 characters, without leading, trailing or consecutive spaces.
+func ValidateKeyword(keyword string) error {
+
+	data, ok := stringToLatin1(keyword)
+	if !ok || len(data) < 1 || len(data) > 79 {
+		return ErrInvalidKeyword
+	}
+	if data[0] == ' ' || data[len(data)-1] == ' ' || bytes.Contains(data, []byte("  ")) {
+		return ErrInvalidKeyword
+	}
+	for _, b := range data {
+		if b < 32 || (b > 126 && b < 161) {
+			return ErrInvalidKeyword
+		}
+	}
+	return nil
+}
+
+// Validate language tag of iTXt chunk, empty tag is allowed.
+func validateLanguageTag(tag string) error {
+	for _, word := range strings.Split(tag, "-") {
+		if tag == "" {
+			break
+		}
+		if len(word) < 1 || len(word) > 8 {
+			return ErrInvalidLanguageTag
+		}
+		for _, c := range word {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return ErrInvalidLanguageTag
+			}
+		}
+	}
+	return nil
+}
+
+// Compress data with zlib.
+func compressText(data []byte) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	zw := zlib.NewWriter(buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// Decompress zlib data.
+func decompressText(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// Read one more byte to detect oversized text.
+	text, err := io.ReadAll(io.LimitReader(zr, maxTextSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > maxTextSize {
+		return nil, ErrTextTooLarge
+	}
+	return text, nil
+}
+
+// Split null-terminated field from data.
+func splitField(data []byte) ([]byte, []byte, error) {
+	sep := bytes.IndexByte(data, '\x00')
+	if sep == -1 {
+		return nil, nil, ErrInvalidTextChunk
+	}
+	return data[:sep], data[sep+1:], nil
+}
+
+// Parse textual chunk, compressed text is decompressed.
+func ParseTextChunk(seg *PngGeneralSegment) (*TextChunk, error) {
+
+	if !isTextType(seg.SegmentType) || seg.Data == nil {
+		return nil, ErrInvalidTextType
+	}
+
+	raw_keyword, data, err := splitField(*seg.Data)
+	if err != nil {
+		return nil, err
+	}
+	chunk := &TextChunk{Type: seg.SegmentType, Keyword: latin1ToString(raw_keyword)}
+
+	switch seg.SegmentType {
+	case TextTypeText:
+		chunk.Text = latin1ToString(data)
+
+	case TextTypeCompressedText:
+		// Compression method, only zlib is defined.
+		if len(data) < 1 || data[0] != 0 {
+			return nil, ErrInvalidTextChunk
+		}
+		text, err := decompressText(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		chunk.Text = latin1ToString(text)
+		chunk.Compressed = true
+
+	case TextTypeInternationalTxt:
+		// Compression flag and method.
+		if len(data) < 2 || data[0] > 1 || data[1] != 0 {
+			return nil, ErrInvalidTextChunk
+		}
+		chunk.Compressed = data[0] == 1
+		data = data[2:]
+
+		language_tag, data, err := splitField(data)
+		if err != nil {
+			return nil, err
+		}
+		translated_keyword, text, err := splitField(data)
+		if err != nil {
+			return nil, err
+		}
+		chunk.LanguageTag = string(language_tag)
+		chunk.TranslatedKeyword = string(translated_keyword)
+
+		if chunk.Compressed {
+			text, err = decompressText(text)
+			if err != nil {
+				return nil, err
+			}
+		}
+		chunk.Text = string(text)
+	}
+
+	return chunk, nil
+}
+
+// Encode textual chunk to segment, keyword, language tag and text encoding are validated.
+func (t *TextChunk) Segment() (*PngGeneralSegment, error) {
+
+	if err := ValidateKeyword(t.Keyword); err != nil {
+		return nil, err
+	}
+	keyword, _ := stringToLatin1(t.Keyword)
+
+	buf := bytes.NewBuffer([]byte{})
+	buf.Write(keyword)
+	buf.WriteByte('\x00')
+
+	switch t.Type {
+	case TextTypeText, TextTypeCompressedText:
+		// Null character would terminate the text for readers.
+		text, ok := stringToLatin1(t.Text)
+		if !ok || bytes.IndexByte(text, '\x00') != -1 {
+			return nil, ErrInvalidTextChunk
+		}
+		if t.Type == TextTypeText {
+			buf.Write(text)
+		} else {
+			buf.WriteByte(0) // Compression method.
+			buf.Write(compressText(text))
+		}
+
+	case TextTypeInternationalTxt:
+		if err := validateLanguageTag(t.LanguageTag); err != nil {
+			return nil, err
+		}
+		if !utf8.ValidString(t.TranslatedKeyword) || !utf8.ValidString(t.Text) ||
+			strings.ContainsRune(t.TranslatedKeyword, '\x00') {
+			return nil, ErrInvalidTextChunk
+		}
+
+		if t.Compressed {
+			buf.Write([]byte{1, 0}) // Compression flag and method.
+		} else {
+			buf.Write([]byte{0, 0})
+		}
+		buf.WriteString(t.LanguageTag)
+		buf.WriteByte('\x00')
+		buf.WriteString(t.TranslatedKeyword)
+		buf.WriteByte('\x00')
+		if t.Compressed {
+			buf.Write(compressText([]byte(t.Text)))
+		} else {
+			buf.WriteString(t.Text)
+		}
+
+	default:
+		return nil, ErrInvalidTextType
+	}
+
+	return NewGeneralSegment(t.Type, buf.Bytes()), nil
+}
+
+// Check if segment is textual chunk with given keyword.
+func isTextSegmentWithKeyword(seg *PngGeneralSegment, keyword string) bool {
+	if !isTextType(seg.SegmentType) || seg.Data == nil {
+		return false
+	}
+	raw_keyword, _, err := splitField(*seg.Data)
+	return err == nil && latin1ToString(raw_keyword) == keyword
+}
+
+// List all textual chunks in image, in file order.
+func (im *PngImage) TextChunks() ([]*TextChunk, error) {
+	chunks := []*TextChunk{}
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*PngGeneralSegment)
+		if !ok || !isTextType(seg.SegmentType) {
+			continue
+		}
+		chunk, err := ParseTextChunk(seg)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// Get first textual chunk with given keyword, nil if not found.
+func (im *PngImage) GetText(keyword string) (*TextChunk, error) {
+	for _, elem := range im.Segments {
+		seg, ok := elem.(*PngGeneralSegment)
+		if ok && isTextSegmentWithKeyword(seg, keyword) {
+			return ParseTextChunk(seg)
+		}
+	}
+	return nil, nil
+}
+
+// Set textual chunk.
+//
+// Existing textual chunks with same keyword are replaced. The chunk is placed before IEND.
+func (im *PngImage) SetText(chunk *TextChunk) error {
+
+	// IHDR should be the first chunk.
+	if !im.hasImageHeader() {
+		return ErrMissingImageHeader
+	}
+
+	seg, err := chunk.Segment()
+	if err != nil {
+		return err
+	}
+
+	im.DeleteText(chunk.Keyword)
+	im.InsertSegmentBefore(seg, "IEND")
+	return nil
+}
+
+// Delete all textual chunks with given keyword.
+func (im *PngImage) DeleteText(keyword string) {
+	im.RemoveSegmentsFunc(func(seg *PngGeneralSegment) bool {
+		return isTextSegmentWithKeyword(seg, keyword)
+	})
+}
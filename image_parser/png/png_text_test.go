@@ -0,0 +1,132 @@
+package png_parser_test
+
+import (
+	"bytes"
+	. "imagecore/image_parser/png"
+	"strings"
+	"testing"
+)
+
+func TestValidateKeyword(t *testing.T) {
+
+	cases := map[string]bool{
+		"Title":                               true,
+		"Création":                            true, // Latin-1 character.
+		"Two words":                           true,
+		"":                                    false,
+		" Leading":                            false,
+		"Trailing ":                           false,
+		"Double  space":                       false,
+		"Tab\tkey":                            false,
+		"漢字":                                  false, // Outside Latin-1.
+		string(make([]byte, 80)):              false,
+		string(bytes.Repeat([]byte{'a'}, 79)): true,
+	}
+
+	for keyword, valid := range cases {
+		err := ValidateKeyword(keyword)
+		if (err == nil) != valid {
+			t.Errorf("Keyword %q: expected valid %v, got error %v", keyword, valid, err)
+		}
+	}
+}
+
+func TestPngTextChunks(t *testing.T) {
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(createTestPng(t)))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	chunks := []TextChunk{
+		{Type: TextTypeText, Keyword: "Comment", Text: "Café"},
+		{Type: TextTypeCompressedText, Keyword: "License", Text: "CC BY 4.0", Compressed: true},
+		{Type: TextTypeInternationalTxt, Keyword: "Title", Text: "標題", Compressed: true, LanguageTag: "zh-TW", TranslatedKeyword: "標題"},
+	}
+	for _, chunk := range chunks {
+		if err := img.SetText(&chunk); err != nil {
+			t.Fatalf("Failed to set text: %v", err)
+		}
+	}
+
+	// Replace existing keyword.
+	err = img.SetText(&TextChunk{Type: TextTypeText, Keyword: "Comment", Text: "Replaced"})
+	if err != nil {
+		t.Fatalf("Failed to set text: %v", err)
+	}
+
+	// Invalid language tag, non-Latin-1 tEXt and null character in text.
+	if err := img.SetText(&TextChunk{Type: TextTypeInternationalTxt, Keyword: "Bad", LanguageTag: "en_US"}); err != ErrInvalidLanguageTag {
+		t.Errorf("Expected ErrInvalidLanguageTag, got %v", err)
+	}
+	if err := img.SetText(&TextChunk{Type: TextTypeText, Keyword: "Bad", Text: "標題"}); err != ErrInvalidTextChunk {
+		t.Errorf("Expected ErrInvalidTextChunk, got %v", err)
+	}
+	for _, text_type := range []string{TextTypeText, TextTypeCompressedText} {
+		if err := img.SetText(&TextChunk{Type: text_type, Keyword: "Bad", Text: "null\x00text"}); err != ErrInvalidTextChunk {
+			t.Errorf("Expected ErrInvalidTextChunk for null character in %s, got %v", text_type, err)
+		}
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := img.WriteTo(buf); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	img = new(PngImage)
+	if _, err := img.ReadFrom(buf); err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	// Text chunks should be placed right before IEND.
+	last := img.Segments[len(img.Segments)-2].(*PngGeneralSegment)
+	if last.SegmentType != "tEXt" {
+		t.Errorf("Expected text chunk before IEND, got %s", last.SegmentType)
+	}
+
+	parsed, err := img.TextChunks()
+	if err != nil || len(parsed) != 3 {
+		t.Fatalf("Expected 3 text chunks, got %d, %v", len(parsed), err)
+	}
+
+	title, err := img.GetText("Title")
+	if err != nil || *title != chunks[2] {
+		t.Errorf("Unexpected title: %+v, %v", title, err)
+	}
+	license, err := img.GetText("License")
+	if err != nil || *license != chunks[1] {
+		t.Errorf("Unexpected license: %+v, %v", license, err)
+	}
+	comment, err := img.GetText("Comment")
+	if err != nil || comment.Text != "Replaced" {
+		t.Errorf("Unexpected comment: %+v, %v", comment, err)
+	}
+
+	img.DeleteText("Title")
+	if title, _ := img.GetText("Title"); title != nil {
+		t.Errorf("Expected title to be deleted")
+	}
+}
+
+func TestPngOversizedCompressedText(t *testing.T) {
+
+	img := new(PngImage)
+	_, err := img.ReadFrom(bytes.NewReader(createTestPng(t)))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	// Highly compressible text above 8MB.
+	large_text := strings.Repeat("a", 8<<20+1)
+	for _, chunk := range []TextChunk{
+		{Type: TextTypeCompressedText, Keyword: "Large", Text: large_text, Compressed: true},
+		{Type: TextTypeInternationalTxt, Keyword: "Large", Text: large_text, Compressed: true},
+	} {
+		if err := img.SetText(&chunk); err != nil {
+			t.Fatalf("Failed to set text: %v", err)
+		}
+		if _, err := img.GetText("Large"); err != ErrTextTooLarge {
+			t.Errorf("Expected ErrTextTooLarge for %s, got %v", chunk.Type, err)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package image_parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	exif "imagecore/exif"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	png_parser "imagecore/image_parser/png"
+	"io"
+)
+
+var (
+	ErrMissingImageHeader = errors.New("image header not found")
+)
+
+// Maximum length of PNG metadata chunk read by probing.
+const maxProbeChunkLength = 4 << 20
+
+// Image properties read from headers, without decoding pixels.
+type ImageInfo struct {
+	Format            string // Image format, "jpeg" or "png".
+	Width             int    // Width in pixels.
+	Height            int    // Height in pixels.
+	BitDepth          int    // Bits per sample.
+	ColorType         string // Color model, e.g. "YCbCr", "Grayscale", "CMYK", "RGB", "RGBA", "Palette".
+	Components        int    // Number of channels.
+	Progressive       bool   // Progressive JPEG.
+	Interlaced        bool   // Adam7 interlaced PNG.
+	ChromaSubsampling string // JPEG chroma subsampling, e.g. "4:2:0", empty if not applicable.
+	HasAlpha          bool   // Image has alpha channel or transparency chunk.
+	HasIcc            bool   // Image has embedded ICC profile.
+	Orientation       int    // EXIF orientation (1~8), 1 if not present.
+}
+
+// Pixel count of image.
+func (info ImageInfo) Pixels() int {
+	return info.Width * info.Height
+}
+
+// Probe image properties from reader.
+//
+// Only header segments are read: JPEG until the first scan, PNG until the first IDAT chunk.
+func Probe(rd io.Reader) (*ImageInfo, error) {
+
+	reader := bufio.NewReader(rd)
+	signature, err := reader.Peek(len(png_parser.PNG_HEADER))
+	if err != nil && len(signature) < 2 {
+		return nil, err
+	}
+
+	switch {
+	// JPEG
+	case bytes.HasPrefix(signature, []byte{'\xFF', '\xD8'}):
+		return probeJpeg(reader)
+	// PNG
+	case bytes.HasPrefix(signature, png_parser.PNG_HEADER):
+		return probePng(reader)
+
+	default:
+		return nil, ErrUnsupportedFileType
+	}
+}
+
+// Read orientation from raw EXIF data, normal orientation if unavailable.
+func probeOrientation(exif_data []byte) int {
+	if exif_data == nil {
+		return 1
+	}
+	parsed_exif, err := exif.Parse(exif_data)
+	if err != nil {
+		return 1
+	}
+	return parsed_exif.Orientation()
+}
+
+func probeJpeg(rd io.Reader) (*ImageInfo, error) {
+
+	seg_list, _, err := jpeg_parser.ReadJpegHeader(rd)
+	if err != nil {
+		return nil, err
+	}
+	img := &jpeg_parser.JpegImage{Segments: seg_list}
+
+	frame, err := img.FrameHeader()
+	if err != nil {
+		if err == jpeg_parser.ErrSegmentNotFound {
+			return nil, ErrMissingImageHeader
+		}
+		return nil, err
+	}
+
+	info := &ImageInfo{
+		Format:            "jpeg",
+		Width:             int(frame.Width),
+		Height:            int(frame.Height),
+		BitDepth:          int(frame.Precision),
+		Components:        len(frame.Components),
+		Progressive:       frame.IsProgressive(),
+		ChromaSubsampling: frame.ChromaSubsampling(),
+	}
+
+	// Color model, the Adobe APP14 transform flag overrides the default.
+	switch info.Components {
+	case 1:
+		info.ColorType = "Grayscale"
+	case 3:
+		info.ColorType = "YCbCr"
+		if transform, ok := adobeTransform(img); ok && transform == 0 {
+			info.ColorType = "RGB"
+		}
+	case 4:
+		info.ColorType = "CMYK"
+		if transform, ok := adobeTransform(img); ok && transform == 2 {
+			info.ColorType = "YCCK"
+		}
+	}
+
+	// Broken metadata shouldn't fail probing.
+	icc_profile, _ := img.ExtractIccProfile()
+	info.HasIcc = icc_profile != nil
+
+	exif_data, _ := img.ExtractExif()
+	info.Orientation = probeOrientation(exif_data)
+
+	return info, nil
+}
+
+// Get color transform flag of Adobe APP14 segment.
+func adobeTransform(img *jpeg_parser.JpegImage) (byte, bool) {
+	for _, elem := range img.Segments {
+		seg, ok := elem.(*jpeg_parser.JpegGeneralSegment)
+		if !ok || seg.SegmentType != 0xEE || seg.Data == nil {
+			continue
+		}
+		// "Adobe", version, flags0, flags1, transform.
+		if len(*seg.Data) >= 12 && bytes.HasPrefix(*seg.Data, []byte("Adobe")) {
+			return (*seg.Data)[11], true
+		}
+	}
+	return 0, false
+}
+
+func probePng(rd io.Reader) (*ImageInfo, error) {
+
+	// Only chunks used by probing are read, with bounded length.
+	seg_list, _, err := png_parser.ReadPngHeaderChunks(rd, map[string]int{
+		"IHDR": 13,
+		"tRNS": 256,
+		"iCCP": maxProbeChunkLength,
+		"eXIf": maxProbeChunkLength,
+	})
+	if err != nil {
+		return nil, err
+	}
+	img := &png_parser.PngImage{Segments: seg_list}
+
+	// IHDR must be the first chunk.
+	header, err := img.Header()
+	if err == png_parser.ErrMissingImageHeader {
+		return nil, ErrMissingImageHeader
+	} else if err != nil {
+		return nil, err
+	}
+
+	info := &ImageInfo{
+		Format:     "png",
+		Width:      int(header.Width),
+		Height:     int(header.Height),
+		BitDepth:   int(header.BitDepth),
+		Components: header.Channels(),
+		Interlaced: header.InterlaceMethod == png_parser.InterlaceAdam7,
+		HasAlpha:   header.HasAlpha(),
+	}
+
+	switch header.ColorType {
+	case png_parser.ColorTypeGrayscale:
+		info.ColorType = "Grayscale"
+	case png_parser.ColorTypeRGB:
+		info.ColorType = "RGB"
+	case png_parser.ColorTypePalette:
+		info.ColorType = "Palette"
+	case png_parser.ColorTypeGrayscaleAlpha:
+		info.ColorType = "GrayAlpha"
+	case png_parser.ColorTypeRGBA:
+		info.ColorType = "RGBA"
+	}
+
+	// Transparency chunk adds alpha to color types without alpha channel.
+	if transparency, _ := img.Transparency(); transparency != nil {
+		info.HasAlpha = true
+	}
+
+	// Broken metadata shouldn't fail probing.
+	icc_profile, _ := img.ExtractIccProfile()
+	info.HasIcc = icc_profile != nil
+
+	exif_data, _ := img.ExtractExif()
+	info.Orientation = probeOrientation(exif_data)
+
+	return info, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"image"
+	jpeg_parser "imagecore/image_parser/jpeg"
 	"strings"
 
 	"image/jpeg"
@@ -29,7 +30,18 @@ var (
 
 type EncoderOption struct {
 	// For JPEG encoder.
-	Quality int
+	Quality            int
+	MatchSourceQuality bool // Cap quality at the estimated quality of the decoded JPEG source.
+
+	// For PNG encoder, zero value is default compression.
+	CompressionLevel png.CompressionLevel
+
+	// Metadata captured by `Decode` is written back to the output by default.
+	DropIcc             bool // Don't write ICC profile.
+	DropExif            bool // Don't write EXIF.
+	DropXmp             bool // Don't write XMP.
+	KeepExifDimensions  bool // Keep original EXIF pixel dimensions, instead of updating them to the output size.
+	KeepExifOrientation bool // Keep original EXIF orientation, instead of updating it to the current orientation.
 }
 
 // Decode image from given `CurrentProcessingImage` instance.
@@ -54,7 +66,22 @@ func Decode() Operation {
 			return currentImage, err
 		}
 
-		return CurrentProcessingImage{Image: image, isBinaryData: false, imageFormat: format}, nil
+		// Capture metadata and orientation, since the decoder ignores them.
+		metadata := readMetadata(currentImage.ImageData, currentImage.Metadata)
+		orientation := readOrientation(metadata.Exif)
+		quality := readQuality(currentImage.ImageData)
+
+		return CurrentProcessingImage{
+			Image:        image,
+			isBinaryData: false,
+			imageFormat:  format,
+			Metadata:     metadata,
+			orientation:  orientation,
+			quality:      quality,
+			Info:         currentImage.Info,
+			Validation:   currentImage.Validation,
+			Repair:       currentImage.Repair,
+		}, nil
 	}
 }
 
@@ -87,6 +114,11 @@ func Encode(format string, opt *EncoderOption) Operation {
 				quality = opt.Quality
 			}
 
+			// Re-encoding at higher quality than source only bloats the output.
+			if opt.MatchSourceQuality && currentImage.quality > 0 {
+				quality = min(quality, currentImage.quality)
+			}
+
 			err := jpeg.Encode(buf, currentImage.Image, &jpeg.Options{Quality: quality})
 			if err != nil {
 				// Change the error state.
@@ -96,7 +128,8 @@ func Encode(format string, opt *EncoderOption) Operation {
 			}
 		case "png":
 			// Quality option is ignored.
-			err := png.Encode(buf, currentImage.Image)
+			encoder := &png.Encoder{CompressionLevel: opt.CompressionLevel}
+			err := encoder.Encode(buf, currentImage.Image)
 			if err != nil {
 				// Change the error state.
 				currentImage.errorState = err
@@ -113,12 +146,38 @@ func Encode(format string, opt *EncoderOption) Operation {
 			}
 		}
 
-		// Get the bytes from buffer.
-		binary_content := make([]byte, buf.Len())
-		copy(binary_content, buf.Bytes())
+		// Write metadata back.
+		binary_content, err := writeMetadata(buf.Bytes(), currentImage, opt)
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
 
 		// Return the new image.
-		return CurrentProcessingImage{ImageData: binary_content, isBinaryData: true, imageFormat: format}, nil
+		return CurrentProcessingImage{ImageData: binary_content, isBinaryData: true, imageFormat: format, Metadata: currentImage.Metadata, orientation: currentImage.orientation, quality: currentImage.quality}, nil
 	}
 
 }
+
+// Estimate JPEG quality from quantization tables of binary image.
+//
+// Returns 0 if image is not JPEG, or the tables can't be read.
+func readQuality(data []byte) int {
+
+	if !bytes.HasPrefix(data, JPEG_HEADER) {
+		return 0
+	}
+
+	seg_list, _, err := jpeg_parser.ReadJpegHeader(bytes.NewReader(data))
+	if err != nil {
+		return 0
+	}
+
+	estimate, err := (&jpeg_parser.JpegImage{Segments: seg_list}).EstimateQuality()
+	if err != nil {
+		return 0
+	}
+	return estimate.Quality
+}
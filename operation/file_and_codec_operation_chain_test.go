@@ -185,3 +185,40 @@ func TestInvalidEncoding(t *testing.T) {
 	}
 
 }
+
+func TestMatchSourceQuality(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, err := CreateImageFromFile(test_png_relative_path)
+	if err != nil {
+		t.Fatalf("Error creating image from file: %v", err)
+	}
+
+	source := im.Then(Decode()).Then(Encode("jpeg", &EncoderOption{Quality: 60})).Then(Decode())
+	if source.LastError() != nil {
+		t.Fatalf("Error encoding source: %v", source.LastError())
+	}
+	if source.SourceQuality() != 60 {
+		t.Errorf("Expected source quality 60, got %d", source.SourceQuality())
+	}
+
+	// Quality is capped at source quality.
+	capped := source.Then(Encode("jpeg", &EncoderOption{MatchSourceQuality: true})).Then(Decode())
+	if capped.LastError() != nil {
+		t.Fatalf("Error encoding image: %v", capped.LastError())
+	}
+	if capped.SourceQuality() != 60 {
+		t.Errorf("Expected output quality 60, got %d", capped.SourceQuality())
+	}
+
+	// Lower quality is kept.
+	lower := source.Then(Encode("jpeg", &EncoderOption{Quality: 40, MatchSourceQuality: true})).Then(Decode())
+	if lower.SourceQuality() != 40 {
+		t.Errorf("Expected output quality 40, got %d", lower.SourceQuality())
+	}
+
+	// PNG source has no quality.
+	if im.Then(Decode()).SourceQuality() != 0 {
+		t.Errorf("Expected no source quality for PNG")
+	}
+}
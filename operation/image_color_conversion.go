@@ -0,0 +1,81 @@
+package operation
+
+import (
+	icc "imagecore/icc"
+)
+
+// Convert image pixels from source profile to destination profile.
+//
+// Both profiles are looked up by name from predefined profiles. If `src_profile_name` is empty,
+// the profile attached to `CurrentProcessingImage.IccProfile` is used, or sRGB if there is none.
+// After conversion, the destination profile is attached to the image, so it can be embedded later.
+func ConvertProfile(src_profile_name string, dst_profile_name string, intent icc.RenderingIntent) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		var err error
+
+		// Get source profile.
+		src_profile := currentImage.IccProfile
+		if src_profile_name != "" || src_profile == nil {
+			if src_profile_name == "" {
+				src_profile_name = "sRGB"
+			}
+			src_profile, err = icc.GetProfile(src_profile_name)
+			if err != nil {
+				// Change the error state.
+				currentImage.errorState = err
+				// Return error.
+				return currentImage, err
+			}
+		}
+
+		// Get destination profile.
+		dst_profile, err := icc.GetProfile(dst_profile_name)
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		return convertProfileInternal(currentImage, src_profile, dst_profile, intent)
+	}
+}
+
+// Convert image pixels between two raw profiles.
+//
+// This allows converting with profiles not in the predefined list, e.g. parsed from uploads.
+func ConvertProfileWith(src_profile []byte, dst_profile []byte, intent icc.RenderingIntent) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+		return convertProfileInternal(currentImage, src_profile, dst_profile, intent)
+	}
+}
+
+// Convert image pixels between two raw profiles.
+// NOTE: This is an internal function, and should not be used directly.
+func convertProfileInternal(currentImage CurrentProcessingImage, src_profile []byte, dst_profile []byte, intent icc.RenderingIntent) (CurrentProcessingImage, error) {
+
+	// Input should not be binary data.
+	if currentImage.IsBinary() {
+		// Change the error state.
+		currentImage.errorState = ErrOperationNotSupportInBinary
+		// Return error.
+		return currentImage, ErrOperationNotSupportInBinary
+	}
+
+	// Build transform.
+	transform, err := icc.NewTransformFromBytes(src_profile, dst_profile, intent)
+	if err != nil {
+		// Change the error state.
+		currentImage.errorState = err
+		// Return error.
+		return currentImage, err
+	}
+
+	// Convert pixels and attach destination profile.
+	currentImage.Image = transform.ConvertImage(currentImage.Image)
+	currentImage.IccProfile = dst_profile
+	return currentImage, nil
+}
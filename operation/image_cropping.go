@@ -195,6 +195,8 @@ func Crop(crop_width int, crop_height int, alignment_method string) Operation {
 			return currentImage, err
 		}
 
-		return CurrentProcessingImage{Image: cropped_image, isBinaryData: false}, nil
+		// Keep attached metadata.
+		currentImage.Image = cropped_image
+		return currentImage, nil
 	}
 }
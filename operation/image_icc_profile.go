@@ -6,7 +6,28 @@ import (
 	image_parser "imagecore/image_parser"
 )
 
+// Embed predefined ICC profile into binary image.
 func EmbedProfile(profile_name string) Operation {
+	return EmbedProfileFromRegistry(icc.DefaultRegistry, profile_name)
+}
+
+// Embed ICC profile from given registry into binary image.
+//
+// This allows embedding user-supplied profiles, e.g. print profiles registered from files.
+func EmbedProfileFromRegistry(registry *icc.Registry, profile_name string) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+		return editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			return registry.EmbedIccProfile(profile_name, parsed_image)
+		})
+	}
+
+}
+
+// Extract embedded ICC profile and attach it to `CurrentProcessingImage.IccProfile`.
+//
+// The image data is not modified. `IccProfile` is set to nil if image has no embedded profile.
+func ExtractProfile() Operation {
 
 	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
 
@@ -30,7 +51,7 @@ func EmbedProfile(profile_name string) Operation {
 			return currentImage, err
 		}
 
-		err = icc.EmbedIccProfile(profile_name, parsed_image)
+		profile, err := icc.ExtractIccProfile(parsed_image)
 		if err != nil {
 			// Change the error state.
 			currentImage.errorState = err
@@ -38,17 +59,7 @@ func EmbedProfile(profile_name string) Operation {
 			return currentImage, err
 		}
 
-		// Create a buffer to hold the image data.
-		buf := new(bytes.Buffer)
-		_, err = parsed_image.WriteTo(buf)
-		if err != nil {
-			// Change the error state.
-			currentImage.errorState = err
-			// Return error.
-			return currentImage, err
-		}
-
-		return CurrentProcessingImage{ImageData: buf.Bytes(), isBinaryData: true}, nil
+		currentImage.IccProfile = profile
+		return currentImage, nil
 	}
-
 }
@@ -0,0 +1,106 @@
+package operation
+
+import (
+	icc "imagecore/icc"
+	"testing"
+)
+
+func TestEmbedAndExtractProfile(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+	test_jpg_relative_path := "./test_resources/test_ayaya.jpg"
+
+	for _, path := range []string{test_png_relative_path, test_jpg_relative_path} {
+
+		im, err := CreateImageFromFile(path)
+		if err != nil {
+			t.Fatalf("Error creating image from file: %v", err)
+		}
+
+		// Embed profile, then extract it back.
+		im_embedded := im.Then(EmbedProfile("Display P3")).Then(ExtractProfile())
+		if im_embedded.LastError() != nil {
+			t.Fatalf("Expected no error, got: %v", im_embedded.LastError())
+		}
+
+		if len(im_embedded.IccProfile) == 0 {
+			t.Errorf("Expected profile to be extracted from %v", path)
+		}
+
+		// Profile should be carried over decoding.
+		im_decoded := im_embedded.Then(Decode())
+		if im_decoded.LastError() != nil {
+			t.Fatalf("Expected no error, got: %v", im_decoded.LastError())
+		}
+		if len(im_decoded.IccProfile) != len(im_embedded.IccProfile) {
+			t.Errorf("Expected profile to be kept after decoding")
+		}
+	}
+}
+
+func TestExtractProfileWithoutProfile(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, _ := CreateImageFromFile(test_png_relative_path)
+
+	im_extracted := im.Then(ExtractProfile())
+	if im_extracted.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im_extracted.LastError())
+	}
+	if im_extracted.IccProfile != nil {
+		t.Errorf("Expected no profile, got %d bytes", len(im_extracted.IccProfile))
+	}
+}
+
+func TestConvertProfile(t *testing.T) {
+	test_jpg_relative_path := "./test_resources/test_ayaya.jpg"
+
+	im, _ := CreateImageFromFile(test_jpg_relative_path)
+
+	// Binary data should be rejected.
+	im_converted := im.Then(ConvertProfile("", "sRGB", icc.IntentPerceptual))
+	if im_converted.LastError() != ErrOperationNotSupportInBinary {
+		t.Errorf("Expected ErrOperationNotSupportInBinary, got: %v", im_converted.LastError())
+	}
+
+	im_converted = im.
+		Then(Decode()).
+		Then(ConvertProfile("Display P3", "sRGB", icc.IntentPerceptual)).
+		Then(Encode("png", nil)).
+		Then(EmbedProfile("sRGB"))
+	if im_converted.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im_converted.LastError())
+	}
+
+	// Unknown profile.
+	im_converted = im.Then(Decode()).Then(ConvertProfile("", "Unknown", icc.IntentPerceptual))
+	if im_converted.LastError() == nil {
+		t.Errorf("Expected error for unknown profile")
+	}
+}
+
+func TestEmbedProfileFromRegistry(t *testing.T) {
+	test_jpg_relative_path := "./test_resources/test_ayaya.jpg"
+
+	raw_profile, _ := icc.GetProfile("ROMM RGB")
+
+	registry := icc.NewRegistry()
+	err := registry.Register("Custom", raw_profile)
+	if err != nil {
+		t.Fatalf("Failed to register profile: %v", err)
+	}
+
+	im, _ := CreateImageFromFile(test_jpg_relative_path)
+	im_embedded := im.Then(EmbedProfileFromRegistry(registry, "custom")).Then(ExtractProfile())
+	if im_embedded.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im_embedded.LastError())
+	}
+	if len(im_embedded.IccProfile) != len(raw_profile) {
+		t.Errorf("Expected registered profile to be embedded")
+	}
+
+	// Predefined profiles are not in custom registry.
+	im_embedded = im.Then(EmbedProfileFromRegistry(registry, "sRGB"))
+	if im_embedded.LastError() != icc.ErrProfileNotFound {
+		t.Errorf("Expected ErrProfileNotFound, got: %v", im_embedded.LastError())
+	}
+}
@@ -0,0 +1,173 @@
+package operation
+
+import (
+	"image"
+	image_parser "imagecore/image_parser"
+	jpeg_parser "imagecore/image_parser/jpeg"
+)
+
+// Transform which displays image of given EXIF orientation correctly.
+var orientationTransform = map[int]jpeg_parser.Transform{
+	OrientationNormal:     jpeg_parser.TransformNone,
+	OrientationFlipH:      jpeg_parser.TransformFlipH,
+	OrientationRotate180:  jpeg_parser.TransformRotate180,
+	OrientationFlipV:      jpeg_parser.TransformFlipV,
+	OrientationTranspose:  jpeg_parser.TransformTranspose,
+	OrientationRotate90:   jpeg_parser.TransformRotate90,
+	OrientationTransverse: jpeg_parser.TransformTransverse,
+	OrientationRotate270:  jpeg_parser.TransformRotate270,
+}
+
+// Apply lossless transform to binary JPEG image, in the DCT domain without re-encoding pixels.
+//
+// Partial MCUs at edges which would be moved are dropped if `trim` is set, otherwise `jpeg_parser.ErrPartialMcu` is returned.
+// Only baseline JPEG is supported, progressive image returns `jpeg_parser.ErrUnsupportedCoding`.
+// EXIF dimensions are updated, the EXIF orientation is kept.
+func LosslessTransform(transform jpeg_parser.Transform, trim bool) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+		return losslessTransformInternal(currentImage, transform, trim, false)
+	}
+}
+
+// Rotate and flip binary JPEG image losslessly according to its EXIF orientation, then reset the orientation to normal.
+//
+// See `LosslessTransform` for the limitations.
+func LosslessAutoOrient(trim bool) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Input image should in binary format.
+		if !currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInImage
+			// Return error.
+			return currentImage, ErrOperationNotSupportInImage
+		}
+
+		orientation := readOrientation(readMetadata(currentImage.ImageData, image_parser.Metadata{}).Exif)
+		return losslessTransformInternal(currentImage, orientationTransform[orientation], trim, true)
+	}
+}
+
+// Apply lossless transform, and update EXIF dimensions. EXIF orientation is reset to normal if `reset_orientation` is set.
+// NOTE: This is an internal function, and should not be used directly.
+func losslessTransformInternal(currentImage CurrentProcessingImage, transform jpeg_parser.Transform, trim bool, reset_orientation bool) (CurrentProcessingImage, error) {
+
+	var raw_exif []byte
+
+	currentImage, err := editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+
+		parsed_jpeg, ok := parsed_image.(*jpeg_parser.JpegImage)
+		if !ok {
+			return ErrOperationNotSupportInFormat
+		}
+
+		err := parsed_jpeg.Transform(transform, trim)
+		if err != nil {
+			return err
+		}
+
+		raw_exif, err = parsed_jpeg.ExtractExif()
+		if err != nil || raw_exif == nil {
+			return err
+		}
+
+		// Without transform, EXIF is rewritten only if the orientation has to be reset.
+		if transform == jpeg_parser.TransformNone && (!reset_orientation || readOrientation(raw_exif) == OrientationNormal) {
+			raw_exif = nil
+			return nil
+		}
+
+		frame, err := parsed_jpeg.FrameHeader()
+		if err != nil {
+			return err
+		}
+
+		bounds := image.Rect(0, 0, int(frame.Width), int(frame.Height))
+		raw_exif, err = updateExif(raw_exif, bounds, OrientationNormal, &EncoderOption{KeepExifOrientation: !reset_orientation})
+		if err != nil {
+			return err
+		}
+		return parsed_jpeg.EmbedExif(raw_exif)
+	})
+	if err != nil {
+		return currentImage, err
+	}
+
+	if raw_exif != nil {
+		currentImage.Exif = raw_exif
+	}
+	if reset_orientation {
+		currentImage.orientation = OrientationNormal
+	}
+	return currentImage, nil
+}
+
+// Crop binary JPEG image losslessly, in the DCT domain without re-encoding pixels.
+//
+// The cropping area is calculated the same way as `Crop`, then its top-left corner is snapped to the MCU grid,
+// so the output can be up to one MCU (8 or 16 pixels) larger than requested. EXIF dimensions are updated.
+func LosslessCrop(crop_width int, crop_height int, alignment_method string) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Get the alignment method by name.
+		alignment, err := GetAlignmentMethodByName(alignment_method)
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		var raw_exif []byte
+
+		currentImage, err = editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+
+			parsed_jpeg, ok := parsed_image.(*jpeg_parser.JpegImage)
+			if !ok {
+				return ErrOperationNotSupportInFormat
+			}
+
+			frame, err := parsed_jpeg.FrameHeader()
+			if err != nil {
+				return err
+			}
+
+			// Calculate the cropping area boundary.
+			original_image_boundary := image.Rect(0, 0, int(frame.Width), int(frame.Height))
+			crop_boundary := alignment(original_image_boundary, crop_width, crop_height)
+			if crop_boundary.Empty() {
+				return ErrInvalidCropBoundary
+			}
+			if !crop_boundary.In(original_image_boundary) {
+				return ErrCroppingAreaOutOfBound
+			}
+
+			crop_boundary, err = parsed_jpeg.Crop(crop_boundary)
+			if err != nil {
+				return err
+			}
+
+			raw_exif, err = parsed_jpeg.ExtractExif()
+			if err != nil || raw_exif == nil {
+				return err
+			}
+
+			raw_exif, err = updateExif(raw_exif, crop_boundary, OrientationNormal, &EncoderOption{KeepExifOrientation: true})
+			if err != nil {
+				return err
+			}
+			return parsed_jpeg.EmbedExif(raw_exif)
+		})
+		if err != nil {
+			return currentImage, err
+		}
+
+		if raw_exif != nil {
+			currentImage.Exif = raw_exif
+		}
+		return currentImage, nil
+	}
+}
@@ -0,0 +1,150 @@
+package operation
+
+import (
+	"bytes"
+	exif "imagecore/exif"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	"os"
+	"testing"
+)
+
+func TestLosslessAutoOrient(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	expected := CreateImageFromBinary(raw_bytes).Then(Decode()).Then(AutoOrient())
+	if expected.LastError() != nil {
+		t.Fatalf("Failed to decode image: %v", expected.LastError())
+	}
+
+	im := CreateImageFromBinary(raw_bytes).Then(LosslessAutoOrient(true))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if im.Orientation() != OrientationNormal {
+		t.Errorf("Expected normal orientation, got %d", im.Orientation())
+	}
+
+	decoded := im.Then(Decode())
+	if decoded.LastError() != nil {
+		t.Fatalf("Failed to decode transformed image: %v", decoded.LastError())
+	}
+	if decoded.Orientation() != OrientationNormal {
+		t.Errorf("Expected EXIF orientation to be reset, got %d", decoded.Orientation())
+	}
+
+	// Trimming may drop partial MCUs at the bottom of source.
+	got, want := decoded.Image.Bounds(), expected.Image.Bounds()
+	if got.Dy() != want.Dy() || got.Dx() > want.Dx() || got.Dx() < want.Dx()-16 {
+		t.Errorf("Expected about %v, got %v", want, got)
+	}
+
+	parsed_exif, err := exif.Parse(decoded.Exif)
+	if err != nil {
+		t.Fatalf("Failed to parse EXIF: %v", err)
+	}
+	if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagCopyright); !ok {
+		t.Errorf("Expected EXIF to be kept")
+	}
+}
+
+func TestLosslessAutoOrientNormal(t *testing.T) {
+	test_jpg_relative_path := "./test_resources/test_ayaya.jpg"
+
+	raw_bytes, err := os.ReadFile(test_jpg_relative_path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	// Progressive image with normal orientation.
+	parsed_image := new(jpeg_parser.JpegImage)
+	parsed_image.ReadFrom(bytes.NewReader(raw_bytes))
+	for _, elem := range parsed_image.Segments {
+		if seg, ok := elem.(*jpeg_parser.JpegGeneralSegment); ok && seg.SegmentType == 0xC0 {
+			seg.SegmentType = 0xC2
+		}
+	}
+	exif_data := exif.New(nil)
+	exif_data.SetOrientation(OrientationNormal)
+	raw_exif, _ := exif_data.Bytes()
+	parsed_image.EmbedExif(raw_exif)
+	buf := new(bytes.Buffer)
+	parsed_image.WriteTo(buf)
+	progressive := buf.Bytes()
+
+	for _, source := range [][]byte{raw_bytes, progressive} {
+		im := CreateImageFromBinary(source).Then(LosslessAutoOrient(false))
+		if im.LastError() != nil {
+			t.Fatalf("Expected no error, got: %v", im.LastError())
+		}
+		if !bytes.Equal(im.ImageData, source) {
+			t.Errorf("Expected image to be unchanged")
+		}
+
+		im = CreateImageFromBinary(source).Then(LosslessTransform(jpeg_parser.TransformNone, false))
+		if im.LastError() != nil || !bytes.Equal(im.ImageData, source) {
+			t.Errorf("Expected image to be unchanged, got error %v", im.LastError())
+		}
+	}
+}
+
+func TestLosslessTransform(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	// Transpose twice gives back the same pixels.
+	im := CreateImageFromBinary(raw_bytes).
+		Then(LosslessTransform(jpeg_parser.TransformTranspose, false)).
+		Then(LosslessTransform(jpeg_parser.TransformTranspose, false))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	original := CreateImageFromBinary(raw_bytes).Then(Decode())
+	decoded := im.Then(Decode())
+	if decoded.LastError() != nil {
+		t.Fatalf("Failed to decode transformed image: %v", decoded.LastError())
+	}
+	if decoded.Image.Bounds() != original.Image.Bounds() {
+		t.Errorf("Expected %v, got %v", original.Image.Bounds(), decoded.Image.Bounds())
+	}
+	if decoded.Orientation() != OrientationRotate90 {
+		t.Errorf("Expected EXIF orientation to be kept, got %d", decoded.Orientation())
+	}
+
+	// PNG is not supported.
+	png, _ := CreateImageFromFile("./test_resources/test_ayaya.png")
+	png = png.Then(LosslessTransform(jpeg_parser.TransformFlipH, true))
+	if png.LastError() != ErrOperationNotSupportInFormat {
+		t.Errorf("Expected ErrOperationNotSupportInFormat, got: %v", png.LastError())
+	}
+}
+
+func TestLosslessCrop(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+	original := CreateImageFromBinary(raw_bytes).Then(Decode())
+	bounds := original.Image.Bounds()
+
+	im := CreateImageFromBinary(raw_bytes).Then(LosslessCrop(bounds.Dx()/2, bounds.Dy()/2, CropAlignmentBottomRight))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	decoded := im.Then(Decode())
+	if decoded.LastError() != nil {
+		t.Fatalf("Failed to decode cropped image: %v", decoded.LastError())
+	}
+	got := decoded.Image.Bounds()
+	if got.Dx() < bounds.Dx()/2 || got.Dx() > bounds.Dx()/2+16 || got.Dy() < bounds.Dy()/2 || got.Dy() > bounds.Dy()/2+16 {
+		t.Errorf("Expected about %dx%d, got %v", bounds.Dx()/2, bounds.Dy()/2, got)
+	}
+	if decoded.Orientation() != OrientationRotate90 {
+		t.Errorf("Expected EXIF orientation to be kept, got %d", decoded.Orientation())
+	}
+
+	im = CreateImageFromBinary(raw_bytes).Then(LosslessCrop(bounds.Dx()+1, 10, CropAlignmentTopLeft))
+	if im.LastError() != ErrCroppingAreaOutOfBound {
+		t.Errorf("Expected ErrCroppingAreaOutOfBound, got: %v", im.LastError())
+	}
+}
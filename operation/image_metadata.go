@@ -0,0 +1,248 @@
+package operation
+
+import (
+	"bytes"
+	"image"
+	exif "imagecore/exif"
+	image_parser "imagecore/image_parser"
+)
+
+// Options for `StripMetadata`.
+type StripOption struct {
+	KeepIcc         bool // Keep embedded ICC profile.
+	KeepOrientation bool // Keep EXIF orientation tag.
+	KeepCopyright   bool // Keep EXIF copyright tag.
+	KeepArtist      bool // Keep EXIF artist tag.
+}
+
+// Build minimal EXIF data with the tags allowed by strip option.
+//
+// Returns nil if there is nothing to keep.
+func buildAllowedExif(raw_exif []byte, opt *StripOption) ([]byte, error) {
+
+	if raw_exif == nil || (!opt.KeepOrientation && !opt.KeepCopyright && !opt.KeepArtist) {
+		return nil, nil
+	}
+
+	// Corrupted EXIF has no tags worth keeping, it is stripped as a whole.
+	parsed_exif, err := exif.Parse(raw_exif)
+	if err != nil {
+		return nil, nil
+	}
+
+	// Copy allowed tags to new EXIF data.
+	allowed_tags := []uint16{}
+	if opt.KeepOrientation {
+		allowed_tags = append(allowed_tags, exif.TagOrientation)
+	}
+	if opt.KeepCopyright {
+		allowed_tags = append(allowed_tags, exif.TagCopyright)
+	}
+	if opt.KeepArtist {
+		allowed_tags = append(allowed_tags, exif.TagArtist)
+	}
+
+	stripped_exif := exif.New(parsed_exif.ByteOrder)
+	for _, id := range allowed_tags {
+		if tag, ok := parsed_exif.Get(exif.Ifd0, id); ok {
+			stripped_exif.Set(exif.Ifd0, tag)
+		}
+	}
+
+	if _, ok := stripped_exif.Ifds[exif.Ifd0]; !ok { // No allowed tags found.
+		return nil, nil
+	}
+
+	return stripped_exif.Bytes()
+}
+
+// Remove metadata from binary image without re-encoding.
+//
+// EXIF, XMP, IPTC, comments, textual chunks and trailing data are removed.
+// Use `opt` to keep ICC profile, orientation, copyright or artist, nil means removing everything.
+func StripMetadata(opt *StripOption) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		if opt == nil {
+			opt = new(StripOption)
+		}
+
+		// Input image should in binary format.
+		if !currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInImage
+			// Return error.
+			return currentImage, ErrOperationNotSupportInImage
+		}
+
+		// Create reader from binary data.
+		r := bytes.NewReader(currentImage.ImageData)
+
+		// Parse binary image to segments.
+		parsed_image, err := image_parser.Parse(r)
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		// Collect allowed EXIF tags before stripping, unreadable EXIF is stripped anyway.
+		raw_exif, err := parsed_image.ExtractExif()
+		if err != nil {
+			raw_exif = nil
+		}
+		allowed_exif, err := buildAllowedExif(raw_exif, opt)
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		parsed_image.StripMetadata(opt.KeepIcc)
+
+		// Put allowed tags back.
+		if allowed_exif != nil {
+			err = parsed_image.EmbedExif(allowed_exif)
+			if err != nil {
+				// Change the error state.
+				currentImage.errorState = err
+				// Return error.
+				return currentImage, err
+			}
+		}
+
+		// Create a buffer to hold the image data.
+		buf := new(bytes.Buffer)
+		_, err = parsed_image.WriteTo(buf)
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		currentImage.ImageData = buf.Bytes()
+		return currentImage, nil
+	}
+}
+
+// Read metadata bundle from binary image.
+//
+// Parts missing in the image fall back to the already attached ones.
+// Metadata is optional, so parse errors are ignored and attached metadata is returned.
+func readMetadata(data []byte, attached image_parser.Metadata) image_parser.Metadata {
+
+	// Parse binary image to segments.
+	parsed_image, err := image_parser.Parse(bytes.NewReader(data))
+	if err != nil {
+		return attached
+	}
+
+	metadata, err := image_parser.ExtractMetadata(parsed_image)
+	if err != nil {
+		return attached
+	}
+
+	if metadata.IccProfile == nil {
+		metadata.IccProfile = attached.IccProfile
+	}
+	if metadata.Exif == nil {
+		metadata.Exif = attached.Exif
+	}
+	if metadata.Xmp == nil {
+		metadata.Xmp = attached.Xmp
+	}
+	return metadata
+}
+
+// Update EXIF dimensions and orientation to match the output image.
+//
+// EXIF data which can't be parsed is returned as is.
+func updateExif(raw_exif []byte, bounds image.Rectangle, orientation int, opt *EncoderOption) ([]byte, error) {
+
+	parsed_exif, err := exif.Parse(raw_exif)
+	if err != nil {
+		return raw_exif, nil
+	}
+
+	if !opt.KeepExifDimensions {
+		// Only update existing tags.
+		dimensions := []struct {
+			ifd   exif.IfdType
+			id    uint16
+			value int
+		}{
+			{exif.Ifd0, exif.TagImageWidth, bounds.Dx()},
+			{exif.Ifd0, exif.TagImageLength, bounds.Dy()},
+			{exif.ExifIfd, exif.TagPixelXDimension, bounds.Dx()},
+			{exif.ExifIfd, exif.TagPixelYDimension, bounds.Dy()},
+		}
+		for _, dimension := range dimensions {
+			if _, ok := parsed_exif.Get(dimension.ifd, dimension.id); ok {
+				parsed_exif.Set(dimension.ifd, exif.NewLongTag(dimension.id, uint32(dimension.value)))
+			}
+		}
+	}
+
+	if !opt.KeepExifOrientation {
+		if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagOrientation); ok || orientation != OrientationNormal {
+			parsed_exif.SetOrientation(orientation)
+		}
+	}
+
+	return parsed_exif.Bytes()
+}
+
+// Write metadata bundle of current image into encoded binary image.
+// NOTE: This is an internal function, and should not be used directly.
+func writeMetadata(encoded []byte, currentImage CurrentProcessingImage, opt *EncoderOption) ([]byte, error) {
+
+	metadata := currentImage.Metadata
+	if opt.DropIcc {
+		metadata.IccProfile = nil
+	}
+	if opt.DropExif {
+		metadata.Exif = nil
+	}
+	if opt.DropXmp {
+		metadata.Xmp = nil
+	}
+
+	// Nothing to write, return a copy of encoded data.
+	if metadata.IsEmpty() {
+		binary_content := make([]byte, len(encoded))
+		copy(binary_content, encoded)
+		return binary_content, nil
+	}
+
+	var err error
+	if metadata.Exif != nil {
+		metadata.Exif, err = updateExif(metadata.Exif, currentImage.Image.Bounds(), currentImage.Orientation(), opt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse encoded image to segments.
+	parsed_image, err := image_parser.Parse(bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	err = image_parser.EmbedMetadata(parsed_image, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a buffer to hold the image data.
+	buf := new(bytes.Buffer)
+	_, err = parsed_image.WriteTo(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,233 @@
+package operation
+
+import (
+	"bytes"
+	"errors"
+	exif "imagecore/exif"
+	image_parser "imagecore/image_parser"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	png_parser "imagecore/image_parser/png"
+)
+
+// Define errors.
+var (
+	ErrOperationNotSupportInFormat = errors.New("operation not supported in this image format")
+)
+
+// Parse binary image, apply edit to the parsed segments, and write it back.
+//
+// Only metadata segments are touched, entropy-coded data is copied as is, so the edit is lossless.
+// NOTE: This is an internal function, and should not be used directly.
+func editParsedImage(currentImage CurrentProcessingImage, edit func(parsed_image image_parser.ParserdImage) error) (CurrentProcessingImage, error) {
+
+	// Input image should in binary format.
+	if !currentImage.IsBinary() {
+		// Change the error state.
+		currentImage.errorState = ErrOperationNotSupportInImage
+		// Return error.
+		return currentImage, ErrOperationNotSupportInImage
+	}
+
+	// Parse binary image to segments.
+	parsed_image, err := image_parser.Parse(bytes.NewReader(currentImage.ImageData))
+	if err != nil {
+		// Change the error state.
+		currentImage.errorState = err
+		// Return error.
+		return currentImage, err
+	}
+
+	// Chunk order problems of the source PNG are tolerated, the edit must not add new ones.
+	var order_before *png_parser.ValidationReport
+	if parsed_png, ok := parsed_image.(*png_parser.PngImage); ok {
+		order_before = parsed_png.ValidateChunkOrder()
+	}
+
+	err = edit(parsed_image)
+	if err != nil {
+		// Change the error state.
+		currentImage.errorState = err
+		// Return error.
+		return currentImage, err
+	}
+
+	// Inserted PNG chunks must follow the chunk ordering rules.
+	if parsed_png, ok := parsed_image.(*png_parser.PngImage); ok {
+		order_after, _ := parsed_png.Canonicalize()
+		if len(order_after.NewIssues(order_before)) > 0 {
+			err = png_parser.ErrInvalidChunkOrder
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+	}
+
+	// Create a buffer to hold the image data.
+	buf := new(bytes.Buffer)
+	_, err = parsed_image.WriteTo(buf)
+	if err != nil {
+		// Change the error state.
+		currentImage.errorState = err
+		// Return error.
+		return currentImage, err
+	}
+
+	currentImage.ImageData = buf.Bytes()
+	return currentImage, nil
+}
+
+// Replace embedded ICC profile of binary image with given raw profile, without re-encoding.
+func SetIccProfile(icc_profile []byte) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		currentImage, err := editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			return parsed_image.EmbedIccProfile(icc_profile)
+		})
+		if err != nil {
+			return currentImage, err
+		}
+
+		currentImage.IccProfile = icc_profile
+		return currentImage, nil
+	}
+}
+
+// Replace XMP packet of binary image, without re-encoding.
+func SetXmp(xmp_data []byte) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		currentImage, err := editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			return parsed_image.EmbedXmp(xmp_data)
+		})
+		if err != nil {
+			return currentImage, err
+		}
+
+		currentImage.Xmp = xmp_data
+		return currentImage, nil
+	}
+}
+
+// Apply edit to EXIF of binary image, a new EXIF structure is created if image has none.
+//
+// NOTE: This is an internal function, and should not be used directly.
+func editExif(currentImage CurrentProcessingImage, edit func(exif_data *exif.Exif)) (CurrentProcessingImage, error) {
+
+	var raw_exif []byte
+
+	currentImage, err := editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+
+		existing_exif, err := parsed_image.ExtractExif()
+		if err != nil {
+			return err
+		}
+
+		exif_data := exif.New(nil)
+		if existing_exif != nil {
+			exif_data, err = exif.Parse(existing_exif)
+			if err != nil {
+				return err
+			}
+		}
+
+		edit(exif_data)
+
+		raw_exif, err = exif_data.Bytes()
+		if err != nil {
+			return err
+		}
+		return parsed_image.EmbedExif(raw_exif)
+	})
+	if err != nil {
+		return currentImage, err
+	}
+
+	currentImage.Exif = raw_exif
+	currentImage.orientation = readOrientation(raw_exif)
+	return currentImage, nil
+}
+
+// Set EXIF tags in given IFD of binary image, without re-encoding.
+//
+// Existing tags with same ID are replaced.
+func SetExifTags(ifd exif.IfdType, tags ...*exif.Tag) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+		return editExif(currentImage, func(exif_data *exif.Exif) {
+			for _, tag := range tags {
+				exif_data.Set(ifd, tag)
+			}
+		})
+	}
+}
+
+// Remove EXIF tags from given IFD of binary image, without re-encoding.
+func RemoveExifTags(ifd exif.IfdType, ids ...uint16) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+		return editExif(currentImage, func(exif_data *exif.Exif) {
+			for _, id := range ids {
+				exif_data.Delete(ifd, id)
+			}
+		})
+	}
+}
+
+// Add COM comment segment to binary JPEG image, without re-encoding.
+func AddComment(comment string) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		return editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			parsed_jpeg, ok := parsed_image.(*jpeg_parser.JpegImage)
+			if !ok {
+				return ErrOperationNotSupportInFormat
+			}
+			return parsed_jpeg.AddComment([]byte(comment))
+		})
+	}
+}
+
+// Set textual chunks of binary PNG image, without re-encoding.
+//
+// Existing chunks with same keyword are replaced.
+func SetPngText(chunks ...png_parser.TextChunk) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		return editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			parsed_png, ok := parsed_image.(*png_parser.PngImage)
+			if !ok {
+				return ErrOperationNotSupportInFormat
+			}
+			for _, chunk := range chunks {
+				err := parsed_png.SetText(&chunk)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// Remove textual chunks with given keywords from binary PNG image, without re-encoding.
+func RemovePngText(keywords ...string) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		return editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			parsed_png, ok := parsed_image.(*png_parser.PngImage)
+			if !ok {
+				return ErrOperationNotSupportInFormat
+			}
+			for _, keyword := range keywords {
+				parsed_png.DeleteText(keyword)
+			}
+			return nil
+		})
+	}
+}
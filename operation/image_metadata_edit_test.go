@@ -0,0 +1,204 @@
+package operation
+
+import (
+	"bytes"
+	exif "imagecore/exif"
+	icc "imagecore/icc"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	png_parser "imagecore/image_parser/png"
+	"os"
+	"testing"
+)
+
+// Collect raw bytes of SOS and entropy-coded segments.
+func scanBytes(t *testing.T, raw_bytes []byte) []byte {
+	parsed_image := new(jpeg_parser.JpegImage)
+	_, err := parsed_image.ReadFrom(bytes.NewReader(raw_bytes))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	buf := new(bytes.Buffer)
+	in_scan := false
+	for _, elem := range parsed_image.Segments {
+		switch seg := elem.(type) {
+		case *jpeg_parser.JpegGeneralSegment:
+			in_scan = seg.SegmentType == 0xDA
+			if in_scan {
+				seg.WriteTo(buf)
+			}
+		default:
+			if in_scan {
+				elem.WriteTo(buf)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestLosslessMetadataEdit(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+	display_p3, err := icc.GetProfile("DisplayP3")
+	if err != nil {
+		t.Fatalf("Failed to load profile: %v", err)
+	}
+	xmp := []byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"></x:xmpmeta>")
+
+	im := CreateImageFromBinary(raw_bytes).
+		Then(SetIccProfile(display_p3)).
+		Then(SetExifTags(exif.Ifd0, exif.NewAsciiTag(exif.TagArtist, "Test Artist"))).
+		Then(RemoveExifTags(exif.GpsIfd, exif.TagGpsLatitude, exif.TagGpsLatitudeRef)).
+		Then(AddComment("edited")).
+		Then(SetXmp(xmp))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	// Pixel data must be byte-identical.
+	if !bytes.Equal(scanBytes(t, raw_bytes), scanBytes(t, im.ImageData)) {
+		t.Errorf("Expected entropy-coded data to be unchanged")
+	}
+
+	if !bytes.Equal(im.IccProfile, display_p3) || !bytes.Equal(im.Xmp, xmp) {
+		t.Errorf("Expected attached metadata to be updated")
+	}
+
+	parsed_image := new(jpeg_parser.JpegImage)
+	parsed_image.ReadFrom(bytes.NewReader(im.ImageData))
+
+	embedded_icc, _ := parsed_image.ExtractIccProfile()
+	if !bytes.Equal(embedded_icc, display_p3) {
+		t.Errorf("Expected ICC profile to be replaced")
+	}
+
+	embedded_xmp, _ := parsed_image.ExtractXmp()
+	if !bytes.Equal(embedded_xmp, xmp) {
+		t.Errorf("Expected XMP to be replaced")
+	}
+
+	comments := parsed_image.Comments()
+	if len(comments) == 0 || string(comments[len(comments)-1]) != "edited" {
+		t.Errorf("Expected comment to be added, got %q", comments)
+	}
+
+	raw_exif, _ := parsed_image.ExtractExif()
+	parsed_exif, err := exif.Parse(raw_exif)
+	if err != nil {
+		t.Fatalf("Failed to parse EXIF: %v", err)
+	}
+	if tag, ok := parsed_exif.Get(exif.Ifd0, exif.TagArtist); !ok || tag.Value != "Test Artist" {
+		t.Errorf("Expected artist tag to be set")
+	}
+	if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagCopyright); !ok {
+		t.Errorf("Expected copyright tag to be kept")
+	}
+	if _, ok := parsed_exif.Get(exif.GpsIfd, exif.TagGpsLatitude); ok {
+		t.Errorf("Expected GPS latitude to be removed")
+	}
+	if im.Orientation() != OrientationRotate90 {
+		t.Errorf("Expected orientation to be kept, got %d", im.Orientation())
+	}
+}
+
+func TestAddEmptyComment(t *testing.T) {
+
+	im := CreateImageFromBinary(createJpegWithMetadata(t)).Then(AddComment(""))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	parsed_image := new(jpeg_parser.JpegImage)
+	if _, err := parsed_image.ReadFrom(bytes.NewReader(im.ImageData)); err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+	comments := parsed_image.Comments()
+	if len(comments) != 2 || len(comments[1]) != 0 {
+		t.Errorf("Expected empty comment appended, got %q", comments)
+	}
+	if report := jpeg_parser.Validate(im.ImageData); !report.Valid() {
+		t.Errorf("Expected valid image, got %v", report.Issues)
+	}
+	if decoded := im.Then(Decode()); decoded.LastError() != nil {
+		t.Errorf("Failed to decode image: %v", decoded.LastError())
+	}
+}
+
+func TestAddCommentPng(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, _ := CreateImageFromFile(test_png_relative_path)
+	im = im.Then(AddComment("edited"))
+	if im.LastError() != ErrOperationNotSupportInFormat {
+		t.Errorf("Expected ErrOperationNotSupportInFormat, got: %v", im.LastError())
+	}
+}
+
+func TestPngTextEdit(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, err := CreateImageFromFile(test_png_relative_path)
+	if err != nil {
+		t.Fatalf("Error creating image from file: %v", err)
+	}
+	im = im.Then(SetPngText(
+		png_parser.TextChunk{Type: png_parser.TextTypeText, Keyword: "Source", Text: "https://example.com/ayaya.png"},
+		png_parser.TextChunk{Type: png_parser.TextTypeInternationalTxt, Keyword: "Copyright", Text: "CC BY 4.0", Compressed: true, LanguageTag: "en"},
+		png_parser.TextChunk{Type: png_parser.TextTypeCompressedText, Keyword: "Software", Text: "imagecore"},
+	)).Then(RemovePngText("Software"))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	parsed_image := new(png_parser.PngImage)
+	parsed_image.ReadFrom(bytes.NewReader(im.ImageData))
+
+	source, err := parsed_image.GetText("Source")
+	if err != nil || source == nil || source.Text != "https://example.com/ayaya.png" {
+		t.Errorf("Expected source text to be set, got %+v, %v", source, err)
+	}
+	license, err := parsed_image.GetText("Copyright")
+	if err != nil || license == nil || license.Text != "CC BY 4.0" || license.LanguageTag != "en" {
+		t.Errorf("Expected license text to be set, got %+v, %v", license, err)
+	}
+	if software, _ := parsed_image.GetText("Software"); software != nil {
+		t.Errorf("Expected software text to be removed")
+	}
+
+	// Invalid keyword should fail.
+	im = im.Then(SetPngText(png_parser.TextChunk{Type: png_parser.TextTypeText, Keyword: " bad"}))
+	if im.LastError() != png_parser.ErrInvalidKeyword {
+		t.Errorf("Expected ErrInvalidKeyword, got: %v", im.LastError())
+	}
+}
+
+func TestPngEditToleratesSourceChunkOrder(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	raw_bytes, err := os.ReadFile(test_png_relative_path)
+	if err != nil {
+		t.Fatalf("Error reading file: %v", err)
+	}
+
+	// Duplicate tIME and private critical chunk in source image.
+	parsed_image := new(png_parser.PngImage)
+	parsed_image.ReadFrom(bytes.NewReader(raw_bytes))
+	parsed_image.InsertSegmentBefore(png_parser.NewGeneralSegment("tIME", []byte{7, 232, 1, 1, 0, 0, 0}), "IEND")
+	parsed_image.InsertSegmentBefore(png_parser.NewGeneralSegment("tIME", []byte{7, 232, 1, 2, 0, 0, 0}), "IEND")
+	parsed_image.InsertSegmentBefore(png_parser.NewGeneralSegment("PrVT", []byte{0}), "IDAT")
+	buf := new(bytes.Buffer)
+	parsed_image.WriteTo(buf)
+
+	im := CreateImageFromBinary(buf.Bytes()).
+		Then(SetPngText(png_parser.TextChunk{Type: png_parser.TextTypeText, Keyword: "Source", Text: "test"})).
+		Then(SetXmp([]byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"></x:xmpmeta>")))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	parsed_image = new(png_parser.PngImage)
+	parsed_image.ReadFrom(bytes.NewReader(im.ImageData))
+	if text, _ := parsed_image.GetText("Source"); text == nil {
+		t.Errorf("Expected text chunk to be set")
+	}
+}
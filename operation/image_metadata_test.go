@@ -0,0 +1,257 @@
+package operation
+
+import (
+	"bytes"
+	exif "imagecore/exif"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	"os"
+	"testing"
+)
+
+// Create JPEG with GPS, orientation, copyright and trailing data from test image.
+func createJpegWithMetadata(t *testing.T) []byte {
+	test_jpg_relative_path := "./test_resources/test_ayaya.jpg"
+
+	raw_bytes, err := os.ReadFile(test_jpg_relative_path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	parsed_image := new(jpeg_parser.JpegImage)
+	_, err = parsed_image.ReadFrom(bytes.NewReader(raw_bytes))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+
+	exif_data := exif.New(nil)
+	exif_data.SetOrientation(OrientationRotate90)
+	exif_data.Set(exif.Ifd0, exif.NewAsciiTag(exif.TagCopyright, "Test Copyright"))
+	exif_data.Set(exif.Ifd0, exif.NewAsciiTag(exif.TagMake, "Test Camera"))
+	exif_data.Set(exif.GpsIfd, exif.NewAsciiTag(exif.TagGpsLatitudeRef, "N"))
+	exif_data.Set(exif.GpsIfd, exif.NewRationalTag(exif.TagGpsLatitude, exif.Rational{Numerator: 25, Denominator: 1}))
+	raw_exif, err := exif_data.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to serialize EXIF: %v", err)
+	}
+	parsed_image.EmbedExif(raw_exif)
+	parsed_image.AppendAppSegment(13, []byte("Photoshop 3.0\x00"))
+
+	buf := bytes.NewBuffer([]byte{})
+	parsed_image.WriteTo(buf)
+	buf.WriteString("trailing data")
+	return buf.Bytes()
+}
+
+func TestStripMetadataJpeg(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	// Strip everything.
+	im := CreateImageFromBinary(raw_bytes).Then(EmbedProfile("sRGB")).Then(StripMetadata(nil))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	for _, marker := range []string{"Exif\x00\x00", "Photoshop", "SYSTEMAX", "ICC_PROFILE", "trailing data"} {
+		if bytes.Contains(im.ImageData, []byte(marker)) {
+			t.Errorf("Expected %q to be removed", marker)
+		}
+	}
+	if im.Then(Decode()).LastError() != nil {
+		t.Errorf("Failed to decode stripped image: %v", im.Then(Decode()).LastError())
+	}
+
+	// Keep orientation, copyright and ICC profile.
+	im = CreateImageFromBinary(raw_bytes).
+		Then(EmbedProfile("sRGB")).
+		Then(StripMetadata(&StripOption{KeepIcc: true, KeepOrientation: true, KeepCopyright: true}))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if !bytes.Contains(im.ImageData, []byte("ICC_PROFILE")) {
+		t.Errorf("Expected ICC profile to be kept")
+	}
+
+	parsed_image := new(jpeg_parser.JpegImage)
+	parsed_image.ReadFrom(bytes.NewReader(im.ImageData))
+	raw_exif, _ := parsed_image.ExtractExif()
+	parsed_exif, err := exif.Parse(raw_exif)
+	if err != nil {
+		t.Fatalf("Failed to parse stripped EXIF: %v", err)
+	}
+	if parsed_exif.Orientation() != OrientationRotate90 {
+		t.Errorf("Expected orientation to be kept")
+	}
+	if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagCopyright); !ok {
+		t.Errorf("Expected copyright to be kept")
+	}
+	if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagMake); ok {
+		t.Errorf("Expected camera make to be removed")
+	}
+	if _, ok := parsed_exif.Ifds[exif.GpsIfd]; ok {
+		t.Errorf("Expected GPS to be removed")
+	}
+}
+
+func TestStripMetadataKeepArtist(t *testing.T) {
+
+	raw_bytes := CreateImageFromBinary(createJpegWithMetadata(t)).
+		Then(SetExifTags(exif.Ifd0, exif.NewAsciiTag(exif.TagArtist, "Test Artist"))).ImageData
+
+	cases := map[StripOption][2]bool{ // Expected copyright and artist to be kept.
+		{KeepCopyright: true}:                   {true, false},
+		{KeepArtist: true}:                      {false, true},
+		{KeepCopyright: true, KeepArtist: true}: {true, true},
+	}
+
+	for opt, expected := range cases {
+		im := CreateImageFromBinary(raw_bytes).Then(StripMetadata(&opt))
+		if im.LastError() != nil {
+			t.Fatalf("[%+v] Expected no error, got: %v", opt, im.LastError())
+		}
+
+		parsed_image := new(jpeg_parser.JpegImage)
+		parsed_image.ReadFrom(bytes.NewReader(im.ImageData))
+		raw_exif, _ := parsed_image.ExtractExif()
+		parsed_exif, err := exif.Parse(raw_exif)
+		if err != nil {
+			t.Fatalf("[%+v] Failed to parse stripped EXIF: %v", opt, err)
+		}
+		if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagCopyright); ok != expected[0] {
+			t.Errorf("[%+v] Expected copyright kept to be %v", opt, expected[0])
+		}
+		if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagArtist); ok != expected[1] {
+			t.Errorf("[%+v] Expected artist kept to be %v", opt, expected[1])
+		}
+	}
+}
+
+func TestStripMetadataCorruptedExif(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	// Truncate EXIF in APP1 segment.
+	parsed_image := new(jpeg_parser.JpegImage)
+	parsed_image.ReadFrom(bytes.NewReader(raw_bytes))
+	raw_exif, _ := parsed_image.ExtractExif()
+	parsed_image.EmbedExif(raw_exif[:12])
+	buf := new(bytes.Buffer)
+	parsed_image.WriteTo(buf)
+	if _, err := exif.Parse(raw_exif[:12]); err == nil {
+		t.Fatalf("Expected truncated EXIF to be unreadable")
+	}
+
+	for _, opt := range []*StripOption{nil, {KeepOrientation: true, KeepCopyright: true}} {
+		im := CreateImageFromBinary(buf.Bytes()).Then(StripMetadata(opt))
+		if im.LastError() != nil {
+			t.Fatalf("Expected no error, got: %v", im.LastError())
+		}
+		for _, marker := range []string{"Exif\x00\x00", "Photoshop", "trailing data"} {
+			if bytes.Contains(im.ImageData, []byte(marker)) {
+				t.Errorf("Expected %q to be removed", marker)
+			}
+		}
+	}
+}
+
+func TestStripMetadataPng(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, _ := CreateImageFromFile(test_png_relative_path)
+	if !bytes.Contains(im.ImageData, []byte("XML:com.adobe.xmp")) {
+		t.Fatalf("Expected test image to contain XMP")
+	}
+
+	im = im.Then(StripMetadata(nil))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if bytes.Contains(im.ImageData, []byte("iTXt")) {
+		t.Errorf("Expected XMP to be removed")
+	}
+	if !bytes.Contains(im.ImageData, []byte("pHYs")) {
+		t.Errorf("Expected pHYs to be kept")
+	}
+	if im.Then(Decode()).LastError() != nil {
+		t.Errorf("Failed to decode stripped image")
+	}
+}
+
+func TestPreserveMetadata(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	im := CreateImageFromBinary(raw_bytes).Then(EmbedProfile("Display P3")).Then(Decode())
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if im.IccProfile == nil || im.Exif == nil {
+		t.Fatalf("Expected metadata to be captured when decoding")
+	}
+
+	// Resize, rotate and encode to both formats.
+	for _, format := range []string{"jpeg", "png"} {
+		im_encoded := im.
+			Then(ResizeImageByWidth("nearestneighbor", 14)).
+			Then(AutoOrient()).
+			Then(Encode(format, nil))
+		if im_encoded.LastError() != nil {
+			t.Fatalf("[%v] Expected no error, got: %v", format, im_encoded.LastError())
+		}
+
+		// Read back.
+		im_decoded := im_encoded.Then(Decode())
+		if im_decoded.LastError() != nil {
+			t.Fatalf("[%v] Failed to decode: %v", format, im_decoded.LastError())
+		}
+		if !bytes.Equal(im_decoded.IccProfile, im.IccProfile) {
+			t.Errorf("[%v] Expected ICC profile to be preserved", format)
+		}
+
+		parsed_exif, err := exif.Parse(im_decoded.Exif)
+		if err != nil {
+			t.Fatalf("[%v] Failed to parse EXIF: %v", format, err)
+		}
+		if parsed_exif.Orientation() != OrientationNormal {
+			t.Errorf("[%v] Expected orientation to be reset, got %d", format, parsed_exif.Orientation())
+		}
+		if _, ok := parsed_exif.Get(exif.Ifd0, exif.TagCopyright); !ok {
+			t.Errorf("[%v] Expected copyright to be preserved", format)
+		}
+	}
+
+	// Drop selected parts.
+	im_encoded := im.Then(Encode("jpeg", &EncoderOption{DropExif: true, DropIcc: true}))
+	if bytes.Contains(im_encoded.ImageData, []byte("Exif\x00\x00")) || bytes.Contains(im_encoded.ImageData, []byte("ICC_PROFILE")) {
+		t.Errorf("Expected EXIF and ICC profile to be dropped")
+	}
+}
+
+func TestPreserveXmpAndDimensions(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, _ := CreateImageFromFile(test_png_relative_path)
+	im = im.Then(Decode())
+	if im.Xmp == nil {
+		t.Fatalf("Expected XMP to be captured when decoding")
+	}
+
+	// Attach EXIF with dimensions.
+	exif_data := exif.New(nil)
+	exif_data.Set(exif.ExifIfd, exif.NewLongTag(exif.TagPixelXDimension, 28))
+	exif_data.Set(exif.ExifIfd, exif.NewLongTag(exif.TagPixelYDimension, 28))
+	im.Exif, _ = exif_data.Bytes()
+
+	im_encoded := im.Then(ResizeImageByWidth("nearestneighbor", 7)).Then(Encode("jpeg", nil)).Then(Decode())
+	if im_encoded.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im_encoded.LastError())
+	}
+	if !bytes.Equal(im_encoded.Xmp, im.Xmp) {
+		t.Errorf("Expected XMP to be preserved")
+	}
+
+	parsed_exif, _ := exif.Parse(im_encoded.Exif)
+	tag, _ := parsed_exif.Get(exif.ExifIfd, exif.TagPixelXDimension)
+	if v, _ := tag.Int(0); v != 7 {
+		t.Errorf("Expected EXIF width to be updated, got %d", v)
+	}
+}
@@ -0,0 +1,113 @@
+package operation
+
+import (
+	"image"
+	exif "imagecore/exif"
+
+	"golang.org/x/image/draw"
+)
+
+// EXIF orientation values.
+const (
+	OrientationNormal     = 1 // No transform.
+	OrientationFlipH      = 2 // Mirror horizontally.
+	OrientationRotate180  = 3 // Rotate 180 degrees.
+	OrientationFlipV      = 4 // Mirror vertically.
+	OrientationTranspose  = 5 // Mirror along top-left to bottom-right diagonal.
+	OrientationRotate90   = 6 // Rotate 90 degrees clockwise to display.
+	OrientationTransverse = 7 // Mirror along top-right to bottom-left diagonal.
+	OrientationRotate270  = 8 // Rotate 270 degrees clockwise to display.
+)
+
+// Read orientation from raw EXIF data.
+//
+// Returns `OrientationNormal` if there is no EXIF data, or it can't be parsed.
+func readOrientation(exif_data []byte) int {
+
+	if exif_data == nil {
+		return OrientationNormal
+	}
+
+	parsed_exif, err := exif.Parse(exif_data)
+	if err != nil {
+		return OrientationNormal
+	}
+
+	return parsed_exif.Orientation()
+}
+
+// Apply orientation transform to image, so it can be displayed without EXIF orientation.
+//
+// This creates a new image, and copy the pixels in transformed order.
+// NOTE: This is an internal function, and should not be used directly.
+func orientImageInternal(in image.Image, orientation int) image.Image {
+
+	if orientation <= OrientationNormal || orientation > OrientationRotate270 {
+		return in
+	}
+
+	// Convert input to RGBA with boundary origin at (0, 0).
+	bounds := in.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Rect, in, bounds.Min, draw.Src)
+
+	// Orientations 5~8 swap width and height.
+	out_w, out_h := w, h
+	if orientation >= OrientationTranspose {
+		out_w, out_h = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, out_w, out_h))
+
+	for y := 0; y < out_h; y++ {
+		for x := 0; x < out_w; x++ {
+
+			// Calculate source pixel of output pixel.
+			var src_x, src_y int
+			switch orientation {
+			case OrientationFlipH:
+				src_x, src_y = w-1-x, y
+			case OrientationRotate180:
+				src_x, src_y = w-1-x, h-1-y
+			case OrientationFlipV:
+				src_x, src_y = x, h-1-y
+			case OrientationTranspose:
+				src_x, src_y = y, x
+			case OrientationRotate90:
+				src_x, src_y = y, h-1-x
+			case OrientationTransverse:
+				src_x, src_y = w-1-y, h-1-x
+			case OrientationRotate270:
+				src_x, src_y = w-1-y, x
+			}
+
+			// Copy pixel.
+			src_offset := src.PixOffset(src_x, src_y)
+			out_offset := out.PixOffset(x, y)
+			copy(out.Pix[out_offset:out_offset+4], src.Pix[src_offset:src_offset+4])
+		}
+	}
+
+	return out
+}
+
+// Rotate and flip image according to the EXIF orientation read by `Decode`.
+//
+// The orientation is reset to normal afterwards, so it won't be applied twice.
+func AutoOrient() Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Input should not be binary data.
+		if currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInBinary
+			// Return error.
+			return currentImage, ErrOperationNotSupportInBinary
+		}
+
+		currentImage.Image = orientImageInternal(currentImage.Image, currentImage.Orientation())
+		currentImage.orientation = OrientationNormal
+		return currentImage, nil
+	}
+}
@@ -0,0 +1,104 @@
+package operation
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	exif "imagecore/exif"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	"os"
+	"testing"
+)
+
+func TestOrientImageInternal(t *testing.T) {
+
+	// 3x2 image, every pixel has distinct colour.
+	//   A B C
+	//   D E F
+	in := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i := 0; i < 6; i++ {
+		in.Set(i%3, i/3, color.RGBA{R: uint8('A' + i), A: 255})
+	}
+
+	expected := map[int][]string{
+		OrientationNormal:     {"ABC", "DEF"},
+		OrientationFlipH:      {"CBA", "FED"},
+		OrientationRotate180:  {"FED", "CBA"},
+		OrientationFlipV:      {"DEF", "ABC"},
+		OrientationTranspose:  {"AD", "BE", "CF"},
+		OrientationRotate90:   {"DA", "EB", "FC"},
+		OrientationTransverse: {"FC", "EB", "DA"},
+		OrientationRotate270:  {"CF", "BE", "AD"},
+	}
+
+	for orientation, rows := range expected {
+		out := orientImageInternal(in, orientation)
+
+		if out.Bounds().Dx() != len(rows[0]) || out.Bounds().Dy() != len(rows) {
+			t.Errorf("[%d] Unexpected bounds: %v", orientation, out.Bounds())
+			continue
+		}
+
+		for y, row := range rows {
+			for x := range row {
+				r, _, _, _ := out.At(x, y).RGBA()
+				if byte(r>>8) != row[x] {
+					t.Errorf("[%d] Expected %c at (%d, %d), got %c", orientation, row[x], x, y, byte(r>>8))
+				}
+			}
+		}
+	}
+}
+
+func TestAutoOrient(t *testing.T) {
+	test_jpg_relative_path := "./test_resources/test_ayaya.jpg"
+
+	raw_bytes, err := os.ReadFile(test_jpg_relative_path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	// Set orientation to 6 (rotate 90 degrees clockwise).
+	parsed_image := new(jpeg_parser.JpegImage)
+	_, err = parsed_image.ReadFrom(bytes.NewReader(raw_bytes))
+	if err != nil {
+		t.Fatalf("Failed to parse image: %v", err)
+	}
+	exif_data := exif.New(nil)
+	exif_data.SetOrientation(OrientationRotate90)
+	raw_exif, _ := exif_data.Bytes()
+	parsed_image.EmbedExif(raw_exif)
+	buf := bytes.NewBuffer([]byte{})
+	parsed_image.WriteTo(buf)
+
+	im := CreateImageFromBinary(buf.Bytes()).Then(Decode())
+	if im.Orientation() != OrientationRotate90 {
+		t.Fatalf("Expected orientation 6, got %d", im.Orientation())
+	}
+
+	// Orientation should be kept through resizing.
+	im = im.Then(ResizeImageByFactor("nearestneighbor", 1))
+	if im.Orientation() != OrientationRotate90 {
+		t.Fatalf("Expected orientation to be kept after resizing, got %d", im.Orientation())
+	}
+
+	im_oriented := im.Then(AutoOrient())
+	if im_oriented.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im_oriented.LastError())
+	}
+	if im_oriented.Orientation() != OrientationNormal {
+		t.Errorf("Expected orientation to be reset, got %d", im_oriented.Orientation())
+	}
+
+	// Top-left pixel should come from bottom-left of the original image.
+	original_bounds := im.Image.Bounds()
+	if im_oriented.Image.At(0, 0) != im.Image.At(0, original_bounds.Max.Y-1) {
+		t.Errorf("Unexpected top-left pixel after rotation")
+	}
+
+	// Binary input is not supported.
+	im_binary := CreateImageFromBinary(raw_bytes).Then(AutoOrient())
+	if im_binary.LastError() != ErrOperationNotSupportInBinary {
+		t.Errorf("Expected ErrOperationNotSupportInBinary, got: %v", im_binary.LastError())
+	}
+}
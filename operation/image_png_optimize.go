@@ -0,0 +1,25 @@
+package operation
+
+import (
+	image_parser "imagecore/image_parser"
+	png_parser "imagecore/image_parser/png"
+)
+
+// Recompress binary PNG image losslessly, keeping the smallest encoding.
+//
+// Color type and bit depth are reduced when pixels allow it, and multiple row filters and zlib levels are tried.
+// Metadata chunks are kept, except those depending on color type. Image is left unchanged if it can't be made smaller.
+func OptimizePNG() Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		return editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			parsed_png, ok := parsed_image.(*png_parser.PngImage)
+			if !ok {
+				return ErrOperationNotSupportInFormat
+			}
+			_, err := parsed_png.Optimize()
+			return err
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package operation
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	png_parser "imagecore/image_parser/png"
+	"testing"
+)
+
+func TestOptimizePNG(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	original, err := CreateImageFromFile(test_png_relative_path)
+	if err != nil {
+		t.Fatalf("Error creating image from file: %v", err)
+	}
+
+	with_text := original.Then(SetPngText(png_parser.TextChunk{Type: png_parser.TextTypeText, Keyword: "Source", Text: "test"}))
+	im := with_text.Then(OptimizePNG())
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if len(im.ImageData) > len(with_text.ImageData) {
+		t.Errorf("Expected image not to grow, got %d > %d", len(im.ImageData), len(with_text.ImageData))
+	}
+
+	// Pixels must be unchanged.
+	expected, _ := png.Decode(bytes.NewReader(original.ImageData))
+	optimized, err := png.Decode(bytes.NewReader(im.ImageData))
+	if err != nil {
+		t.Fatalf("Failed to decode optimized image: %v", err)
+	}
+	bounds := expected.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Compare 8-bit samples without going through premultiplied color.
+			if color.NRGBAModel.Convert(expected.At(x, y)) != color.NRGBAModel.Convert(optimized.At(x, y)) {
+				t.Fatalf("Pixel (%d, %d) mismatch", x, y)
+			}
+		}
+	}
+
+	parsed_image := new(png_parser.PngImage)
+	parsed_image.ReadFrom(bytes.NewReader(im.ImageData))
+	if text, _ := parsed_image.GetText("Source"); text == nil {
+		t.Errorf("Expected text chunk to be kept")
+	}
+
+	// JPEG is not supported.
+	im, _ = CreateImageFromFile("./test_resources/test_ayaya.jpg")
+	im = im.Then(OptimizePNG())
+	if im.LastError() != ErrOperationNotSupportInFormat {
+		t.Errorf("Expected ErrOperationNotSupportInFormat, got: %v", im.LastError())
+	}
+}
+
+func TestEncodePngCompressionLevel(t *testing.T) {
+	test_png_relative_path := "./test_resources/test_ayaya.png"
+
+	im, err := CreateImageFromFile(test_png_relative_path)
+	if err != nil {
+		t.Fatalf("Error creating image from file: %v", err)
+	}
+	im = im.Then(Decode())
+
+	fast := im.Then(Encode("png", &EncoderOption{CompressionLevel: png.NoCompression}))
+	best := im.Then(Encode("png", &EncoderOption{CompressionLevel: png.BestCompression}))
+	if fast.LastError() != nil || best.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v, %v", fast.LastError(), best.LastError())
+	}
+	if len(best.ImageData) >= len(fast.ImageData) {
+		t.Errorf("Expected best compression to be smaller, got %d >= %d", len(best.ImageData), len(fast.ImageData))
+	}
+}
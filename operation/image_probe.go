@@ -0,0 +1,72 @@
+package operation
+
+import (
+	"bytes"
+	"errors"
+	image_parser "imagecore/image_parser"
+)
+
+// Define errors.
+var (
+	ErrImageTooLarge = errors.New("image dimensions exceed the limit")
+)
+
+// Read image properties from headers and attach them to `CurrentProcessingImage.Info`.
+//
+// Pixels are not decoded, so this is cheap enough to run before `Decode` on untrusted input.
+// The image format and orientation are captured as well.
+func ProbeImage() Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Input image should in binary format.
+		if !currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInImage
+			// Return error.
+			return currentImage, ErrOperationNotSupportInImage
+		}
+
+		info, err := image_parser.Probe(bytes.NewReader(currentImage.ImageData))
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		currentImage.Info = info
+		currentImage.imageFormat = info.Format
+		currentImage.orientation = info.Orientation
+		return currentImage, nil
+	}
+}
+
+// Reject image larger than given limits, without decoding pixels.
+//
+// A limit of 0 means unlimited. The image is probed first if `Info` is not present.
+func LimitImageSize(max_width int, max_height int, max_pixels int) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		if currentImage.Info == nil {
+			var err error
+			currentImage, err = ProbeImage()(currentImage)
+			if err != nil {
+				return currentImage, err
+			}
+		}
+
+		info := currentImage.Info
+		if (max_width > 0 && info.Width > max_width) ||
+			(max_height > 0 && info.Height > max_height) ||
+			(max_pixels > 0 && info.Pixels() > max_pixels) {
+			// Change the error state.
+			currentImage.errorState = ErrImageTooLarge
+			// Return error.
+			return currentImage, ErrImageTooLarge
+		}
+
+		return currentImage, nil
+	}
+}
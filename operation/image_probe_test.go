@@ -0,0 +1,151 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	image_parser "imagecore/image_parser"
+	png_parser "imagecore/image_parser/png"
+	"io"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// Reader counting consumed bytes.
+type countingReader struct {
+	r *bytes.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestProbeJpeg(t *testing.T) {
+
+	im := CreateImageFromBinary(createJpegWithMetadata(t)).Then(EmbedProfile("sRGB")).Then(ProbeImage())
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	decoded := im.Then(Decode())
+	if decoded.LastError() != nil {
+		t.Fatalf("Failed to decode image: %v", decoded.LastError())
+	}
+	bounds := decoded.Image.Bounds()
+
+	// Probe result is kept by decoding, and reset by encoding.
+	if decoded.Info != im.Info {
+		t.Errorf("Expected probe result to be kept after decoding")
+	}
+	if encoded := decoded.Then(Encode("png", nil)); encoded.Info != nil {
+		t.Errorf("Expected probe result to be reset after encoding")
+	}
+
+	info := im.Info
+	if info.Format != "jpeg" || im.ImageFormat() != "jpeg" {
+		t.Errorf("Expected jpeg format, got %q", info.Format)
+	}
+	if info.Width != bounds.Dx() || info.Height != bounds.Dy() {
+		t.Errorf("Expected %dx%d, got %dx%d", bounds.Dx(), bounds.Dy(), info.Width, info.Height)
+	}
+	if info.BitDepth != 8 || info.ColorType != "YCbCr" || info.Components != 3 {
+		t.Errorf("Unexpected color properties: %+v", info)
+	}
+	if !info.HasIcc || info.HasAlpha || info.Progressive {
+		t.Errorf("Unexpected flags: %+v", info)
+	}
+	if info.Orientation != OrientationRotate90 || im.Orientation() != OrientationRotate90 {
+		t.Errorf("Expected orientation %d, got %d", OrientationRotate90, info.Orientation)
+	}
+}
+
+func TestProbePngReadsHeaderOnly(t *testing.T) {
+
+	// Noise doesn't compress, so image data is much larger than read-ahead buffer.
+	img := image.NewNRGBA(image.Rect(0, 0, 256, 128))
+	rng := rand.New(rand.NewSource(1))
+	rng.Read(img.Pix)
+	img.Set(0, 0, color.NRGBA{})
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	rd := &countingReader{r: bytes.NewReader(buf.Bytes())}
+	info, err := image_parser.Probe(rd)
+	if err != nil {
+		t.Fatalf("Failed to probe image: %v", err)
+	}
+
+	if info.Format != "png" || info.Width != 256 || info.Height != 128 {
+		t.Errorf("Unexpected dimensions: %+v", info)
+	}
+	if info.ColorType != "RGBA" || info.Components != 4 || !info.HasAlpha || info.BitDepth != 8 {
+		t.Errorf("Unexpected color properties: %+v", info)
+	}
+	if info.HasIcc || info.Interlaced || info.Orientation != 1 {
+		t.Errorf("Unexpected flags: %+v", info)
+	}
+	if rd.n >= buf.Len()/2 {
+		t.Errorf("Expected probe to read only the header, read %d of %d bytes", rd.n, buf.Len())
+	}
+}
+
+func TestProbePngHugeChunkLength(t *testing.T) {
+
+	// Chunk with length 0xC0000000 after IHDR, only a few bytes follow.
+	chunk := func(chunk_type string, length uint32, data []byte) []byte {
+		head := binary.BigEndian.AppendUint32(nil, length)
+		return append(append(head, chunk_type...), data...)
+	}
+	ihdr := png_parser.ImageHeader{Width: 16, Height: 16, BitDepth: 8, ColorType: png_parser.ColorTypeRGB}
+	buf := bytes.NewBuffer(bytes.Clone(PNG_HEADER))
+	png_parser.NewGeneralSegment("IHDR", ihdr.Bytes()).WriteTo(buf)
+	header := buf.Bytes()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	_, err := image_parser.Probe(bytes.NewReader(append(bytes.Clone(header), chunk("tEXt", 0xC0000000, make([]byte, 64))...)))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for skipped chunk, got %v", err)
+	}
+	_, err = image_parser.Probe(bytes.NewReader(append(bytes.Clone(header), chunk("iCCP", 0xC0000000, make([]byte, 64))...)))
+	if err != png_parser.ErrChunkTooLarge {
+		t.Errorf("Expected ErrChunkTooLarge for oversized chunk, got %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("Expected probe to allocate little memory, allocated %d bytes", allocated)
+	}
+}
+
+func TestLimitImageSize(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	im := CreateImageFromBinary(raw_bytes).Then(LimitImageSize(100000, 100000, 0))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+
+	im = CreateImageFromBinary(raw_bytes).Then(LimitImageSize(0, 0, 16)).Then(Decode())
+	if im.LastError() != ErrImageTooLarge {
+		t.Errorf("Expected ErrImageTooLarge, got: %v", im.LastError())
+	}
+	if im.Image != nil {
+		t.Errorf("Expected image not to be decoded")
+	}
+
+	im = CreateImageFromBinary([]byte("not an image")).Then(ProbeImage())
+	if im.LastError() != image_parser.ErrUnsupportedFileType {
+		t.Errorf("Expected ErrUnsupportedFileType, got: %v", im.LastError())
+	}
+}
@@ -74,7 +74,10 @@ func ResizeImageByWidth(algo string, x int) Operation {
 		factor := float32(currentImage.Image.Bounds().Max.X) / float32(x)
 		boundary := createResizeBoundryByFactor(currentImage.Image.Bounds(), factor)
 		resizedImage := resizeImageInternal(currentImage.Image, algo, boundary)
-		return CurrentProcessingImage{Image: resizedImage, isBinaryData: false}, nil
+
+		// Keep attached metadata.
+		currentImage.Image = resizedImage
+		return currentImage, nil
 	}
 }
 
@@ -97,7 +100,10 @@ func ResizeImageByHeight(algo string, y int) Operation {
 		factor := float32(currentImage.Image.Bounds().Max.Y) / float32(y)
 		boundary := createResizeBoundryByFactor(currentImage.Image.Bounds(), factor)
 		resizedImage := resizeImageInternal(currentImage.Image, algo, boundary)
-		return CurrentProcessingImage{Image: resizedImage, isBinaryData: false}, nil
+
+		// Keep attached metadata.
+		currentImage.Image = resizedImage
+		return currentImage, nil
 	}
 }
 
@@ -119,6 +125,9 @@ func ResizeImageByFactor(algo string, factor float32) Operation {
 		// Do resize on `image.Image` instance.
 		boundary := createResizeBoundryByFactor(currentImage.Image.Bounds(), factor)
 		resizedImage := resizeImageInternal(currentImage.Image, algo, boundary)
-		return CurrentProcessingImage{Image: resizedImage, isBinaryData: false}, nil
+
+		// Keep attached metadata.
+		currentImage.Image = resizedImage
+		return currentImage, nil
 	}
 }
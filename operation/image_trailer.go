@@ -0,0 +1,64 @@
+package operation
+
+import (
+	"bytes"
+	image_parser "imagecore/image_parser"
+	jpeg_parser "imagecore/image_parser/jpeg"
+
+	"golang.org/x/exp/slices"
+)
+
+// Remove data appended after EOI of binary JPEG image, e.g. motion photo video or ZIP payload.
+func StripTrailer() Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+		return editParsedImage(currentImage, func(parsed_image image_parser.ParserdImage) error {
+			parsed_jpeg, ok := parsed_image.(*jpeg_parser.JpegImage)
+			if !ok {
+				return ErrOperationNotSupportInFormat
+			}
+			parsed_jpeg.StripTrailer()
+			return nil
+		})
+	}
+}
+
+// Fail the chain with `ErrTrailingData` if binary JPEG image has data after EOI of kind not in `allowed`.
+//
+// Images without trailer always pass, non-JPEG images are passed through untouched.
+func RejectTrailer(allowed ...jpeg_parser.TrailerKind) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Input image should in binary format.
+		if !currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInImage
+			// Return error.
+			return currentImage, ErrOperationNotSupportInImage
+		}
+
+		if !bytes.HasPrefix(currentImage.ImageData, JPEG_HEADER) {
+			return currentImage, nil
+		}
+
+		parsed_jpeg := new(jpeg_parser.JpegImage)
+		_, err := parsed_jpeg.ReadFrom(bytes.NewReader(currentImage.ImageData))
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		kind := parsed_jpeg.Trailer().Kind
+		if kind != jpeg_parser.TrailerNone && !slices.Contains(allowed, kind) {
+			// Change the error state.
+			currentImage.errorState = ErrTrailingData
+			// Return error.
+			return currentImage, ErrTrailingData
+		}
+
+		return currentImage, nil
+	}
+}
@@ -0,0 +1,53 @@
+package operation
+
+import (
+	"bytes"
+	jpeg_parser "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestRejectTrailer(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t) // Has "trailing data" appended.
+	video := append(bytes.Clone(raw_bytes[:len(raw_bytes)-len("trailing data")]), []byte("\x00\x00\x00\x18ftypmp42")...)
+
+	im := CreateImageFromBinary(raw_bytes).Then(RejectTrailer(jpeg_parser.TrailerPadding))
+	if im.LastError() != ErrTrailingData {
+		t.Errorf("Expected ErrTrailingData, got: %v", im.LastError())
+	}
+
+	im = CreateImageFromBinary(video).Then(RejectTrailer(jpeg_parser.TrailerVideo))
+	if im.LastError() != nil {
+		t.Errorf("Expected video trailer to be allowed, got: %v", im.LastError())
+	}
+
+	im = CreateImageFromBinary(raw_bytes).Then(StripTrailer()).Then(RejectTrailer())
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if bytes.Contains(im.ImageData, []byte("trailing data")) {
+		t.Errorf("Expected trailer to be stripped")
+	}
+	if im.Then(Decode()).LastError() != nil {
+		t.Errorf("Failed to decode stripped image")
+	}
+}
+
+func TestRejectTrailerNonJpeg(t *testing.T) {
+
+	png_bytes := CreateImageFromBinary(createJpegWithMetadata(t)).Then(Decode()).Then(Encode("png", nil)).ImageData
+	cases := [][]byte{
+		png_bytes,
+		[]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"),
+	}
+
+	for i, raw_bytes := range cases {
+		im := CreateImageFromBinary(raw_bytes).Then(RejectTrailer())
+		if im.LastError() != nil {
+			t.Errorf("[%d] Expected non-JPEG image to pass, got: %v", i, im.LastError())
+		}
+		if !bytes.Equal(im.ImageData, raw_bytes) {
+			t.Errorf("[%d] Expected image to be untouched", i)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package operation
+
+import (
+	"bytes"
+	"errors"
+	jpeg_parser "imagecore/image_parser/jpeg"
+)
+
+// Define errors.
+var (
+	ErrCorruptedImage = errors.New("image structure is corrupted")
+	ErrTrailingData   = errors.New("image has unexpected data after end of image")
+)
+
+// Validate structure of binary JPEG image, and attach the report to `CurrentProcessingImage.Validation`.
+//
+// Returns `ErrCorruptedImage` if any structural problem is found, so bad uploads can be quarantined before `Decode`.
+// Data after EOI returns `ErrTrailingData`, unless `allow_trailing_data` is set.
+// Non-JPEG images are passed through untouched.
+func ValidateStructure(allow_trailing_data bool) Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Input image should in binary format.
+		if !currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInImage
+			// Return error.
+			return currentImage, ErrOperationNotSupportInImage
+		}
+
+		if !bytes.HasPrefix(currentImage.ImageData, JPEG_HEADER) {
+			return currentImage, nil
+		}
+
+		report := jpeg_parser.Validate(currentImage.ImageData)
+		currentImage.Validation = report
+
+		if !report.Valid() {
+			// Change the error state.
+			currentImage.errorState = ErrCorruptedImage
+			// Return error.
+			return currentImage, ErrCorruptedImage
+		}
+
+		if !allow_trailing_data && report.TrailingDataSize > 0 {
+			// Change the error state.
+			currentImage.errorState = ErrTrailingData
+			// Return error.
+			return currentImage, ErrTrailingData
+		}
+
+		return currentImage, nil
+	}
+}
+
+// Repair truncated binary JPEG image best-effort, and attach the report to `CurrentProcessingImage.Repair`.
+//
+// Missing part of a baseline image becomes grey after `Decode`. Images which are not truncated,
+// and non-JPEG images, are passed through untouched.
+func RepairTruncated() Operation {
+
+	return func(currentImage CurrentProcessingImage) (CurrentProcessingImage, error) {
+
+		// Input image should in binary format.
+		if !currentImage.IsBinary() {
+			// Change the error state.
+			currentImage.errorState = ErrOperationNotSupportInImage
+			// Return error.
+			return currentImage, ErrOperationNotSupportInImage
+		}
+
+		if !bytes.HasPrefix(currentImage.ImageData, JPEG_HEADER) {
+			return currentImage, nil
+		}
+
+		repaired, report, err := jpeg_parser.Repair(currentImage.ImageData)
+		currentImage.Repair = report
+		if err != nil {
+			// Change the error state.
+			currentImage.errorState = err
+			// Return error.
+			return currentImage, err
+		}
+
+		currentImage.ImageData = repaired
+		return currentImage, nil
+	}
+}
@@ -0,0 +1,68 @@
+package operation
+
+import (
+	jpeg_parser "imagecore/image_parser/jpeg"
+	"testing"
+)
+
+func TestValidateStructure(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+
+	im := CreateImageFromBinary(raw_bytes).Then(ValidateStructure(true))
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if im.Validation == nil || im.Validation.TrailingDataSize != int64(len("trailing data")) {
+		t.Errorf("Expected trailing data in report, got %+v", im.Validation)
+	}
+
+	im = CreateImageFromBinary(raw_bytes).Then(ValidateStructure(false))
+	if im.LastError() != ErrTrailingData {
+		t.Errorf("Expected ErrTrailingData, got: %v", im.LastError())
+	}
+
+	// Truncated image is rejected before decoding.
+	im = CreateImageFromBinary(raw_bytes[:len(raw_bytes)/2]).Then(ValidateStructure(true)).Then(Decode())
+	if im.LastError() != ErrCorruptedImage {
+		t.Errorf("Expected ErrCorruptedImage, got: %v", im.LastError())
+	}
+	if im.Validation == nil || !im.Validation.Has(jpeg_parser.IssueTruncatedScan) {
+		t.Errorf("Expected truncated scan in report, got %+v", im.Validation)
+	}
+	if im.Image != nil {
+		t.Errorf("Expected image not to be decoded")
+	}
+}
+
+func TestRepairTruncated(t *testing.T) {
+
+	raw_bytes := createJpegWithMetadata(t)
+	truncated := raw_bytes[:len(raw_bytes)*3/4]
+
+	if CreateImageFromBinary(truncated).Then(Decode()).LastError() == nil {
+		t.Fatalf("Expected truncated image to fail decoding")
+	}
+
+	im := CreateImageFromBinary(truncated).Then(RepairTruncated())
+	if im.LastError() != nil {
+		t.Fatalf("Expected no error, got: %v", im.LastError())
+	}
+	if im.Repair == nil || !im.Repair.Truncated || im.Repair.SalvagedMcus == 0 {
+		t.Errorf("Unexpected repair report: %+v", im.Repair)
+	}
+
+	im = im.Then(Decode())
+	if im.LastError() != nil {
+		t.Fatalf("Failed to decode repaired image: %v", im.LastError())
+	}
+	if im.Orientation() != OrientationRotate90 {
+		t.Errorf("Expected metadata to be kept, got orientation %d", im.Orientation())
+	}
+
+	// Untouched image.
+	im = CreateImageFromBinary(raw_bytes).Then(RepairTruncated())
+	if im.LastError() != nil || im.Repair.Truncated || len(im.ImageData) != len(raw_bytes) {
+		t.Errorf("Expected image to be untouched")
+	}
+}
@@ -3,6 +3,8 @@ package operation
 import (
 	"errors"
 	"image"
+	image_parser "imagecore/image_parser"
+	jpeg_parser "imagecore/image_parser/jpeg"
 )
 
 // The CurrentProcessingImage is a struct that holds the current image data.
@@ -14,8 +16,24 @@ type CurrentProcessingImage struct {
 	ImageData    []byte      // The binary data.
 	Image        image.Image // The `image.Image` instance.
 	imageFormat  string      // The image format.
+	orientation  int         // The EXIF orientation, captured when decoding.
+	quality      int         // The estimated JPEG quality of source, captured when decoding.
 	isBinaryData bool        // Flag to track if the image is binary data.
 	errorState   error       // Error state, this is used to track error in the image processing chain.
+
+	// Reports below describe the source binary. They are kept by `Decode`, and reset by `Encode` which creates a new binary.
+
+	// Header properties, captured by `ProbeImage`. Nil if image is not probed.
+	Info *image_parser.ImageInfo
+
+	// JPEG structure validation report, captured by `ValidateStructure`. Nil if image is not validated.
+	Validation *jpeg_parser.ValidationReport
+
+	// JPEG repair report, captured by `RepairTruncated`. Nil if image is not repaired.
+	Repair *jpeg_parser.RepairReport
+
+	// The metadata bundle (ICC profile, EXIF, XMP), captured by `Decode` or `ExtractProfile`.
+	image_parser.Metadata
 }
 
 func (c CurrentProcessingImage) IsBinary() bool {
@@ -30,6 +48,19 @@ func (c CurrentProcessingImage) ImageFormat() string {
 	return c.imageFormat
 }
 
+// Get EXIF orientation (1~8) of the image, normal orientation is returned if unknown.
+func (c CurrentProcessingImage) Orientation() int {
+	if c.orientation == 0 {
+		return 1
+	}
+	return c.orientation
+}
+
+// Get estimated JPEG quality (1~100) of the decoded source, 0 if unknown.
+func (c CurrentProcessingImage) SourceQuality() int {
+	return c.quality
+}
+
 // Define errors.
 var (
 	ErrOperationNotSupportInBinary = errors.New("Operation not supported in binary format, convert to `image.Image` first")